@@ -21,6 +21,8 @@ type System struct {
 	Host                       string             `env:"SYSTEM_HOST" required:"true"`
 	Key                        ed25519.PrivateKey `env:"SYSTEM_KEY" required:"false"`
 	AuthPrivateKey             string             `env:"SYSTEM_PRIVATE_KEY" required:"true"`
+	AuthPrivateKeyID           string             `env:"SYSTEM_PRIVATE_KEY_ID" envDefault:"default"`
+	AuthVerificationKeys       string             `env:"SYSTEM_AUTH_VERIFICATION_KEYS" envDefault:""` // id:seed_hex:expires_rfc3339,... - retired keys still accepted for sessions
 	AuthSessionDuration        time.Duration      `env:"SYSTEM_AUTH_SESSION_DURATION" envDefault:"24h"`
 	ADNLPort                   string             `env:"SYSTEM_ADNL_PORT" envDefault:"16167"`
 	AdminAuthTokens            string             `env:"SYSTEM_ADMIN_AUTH_TOKENS" envDefault:""`
@@ -31,6 +33,29 @@ type System struct {
 	UnpaidFilesLifetimePublic  time.Duration      `env:"SYSTEM_UNPAID_FILES_LIFETIME_PUBLIC" envDefault:"15m"`
 	TotalDiskSpaceAvailable    uint64             `env:"SYSTEM_TOTAL_DISK_SPACE_AVAILABLE" envDefault:"644245094400"` // 600 GB
 	MaxAllowedSpanDays         uint32             `env:"SYSTEM_MAX_ALLOWED_SPAN_DAYS" envDefault:"7"`
+	ProvidersRatesWorkers      int                `env:"SYSTEM_PROVIDERS_RATES_WORKERS" envDefault:"32"`
+	ProvidersRatesDeadline     time.Duration      `env:"SYSTEM_PROVIDERS_RATES_DEADLINE" envDefault:"25s"`
+	ProvidersRatesCacheTTL     time.Duration      `env:"SYSTEM_PROVIDERS_RATES_CACHE_TTL" envDefault:"1m"`
+	ProvidersDeclinesCacheTTL  time.Duration      `env:"SYSTEM_PROVIDERS_DECLINES_CACHE_TTL" envDefault:"15s"`
+	ProvidersQuoteTTL          time.Duration      `env:"SYSTEM_PROVIDERS_QUOTE_TTL" envDefault:"10m"`
+	ProvidersProbeInterval     time.Duration      `env:"SYSTEM_PROVIDERS_PROBE_INTERVAL" envDefault:"1h"`
+	ProvidersProbeBagSize      uint64             `env:"SYSTEM_PROVIDERS_PROBE_BAG_SIZE" envDefault:"1073741824"` // 1 GB reference bag
+	ProofGracePeriod           time.Duration      `env:"SYSTEM_PROOF_GRACE_PERIOD" envDefault:"1h"`
+	ResolveMaxAttempts         int                `env:"SYSTEM_RESOLVE_MAX_ATTEMPTS" envDefault:"10"`
+	NotifyMaxAttempts          int                `env:"SYSTEM_NOTIFY_MAX_ATTEMPTS" envDefault:"10"`
+	DownloadMaxChecks          int                `env:"SYSTEM_DOWNLOAD_MAX_CHECKS" envDefault:"10"`
+	RetryBackoffBase           time.Duration      `env:"SYSTEM_RETRY_BACKOFF_BASE" envDefault:"1m"`
+	RetryBackoffMax            time.Duration      `env:"SYSTEM_RETRY_BACKOFF_MAX" envDefault:"2h"`
+	RetryBackoffJitter         float64            `env:"SYSTEM_RETRY_BACKOFF_JITTER" envDefault:"0.2"`
+	ProviderChecksWorkers      int                `env:"SYSTEM_PROVIDER_CHECKS_WORKERS" envDefault:"16"`
+	ProviderChecksConcurrency  int                `env:"SYSTEM_PROVIDER_CHECKS_CONCURRENCY" envDefault:"2"`  // in flight requests per provider
+	ProviderChecksInterval     time.Duration      `env:"SYSTEM_PROVIDER_CHECKS_INTERVAL" envDefault:"200ms"` // min time between requests to one provider
+	RetentionMinReplicas       int                `env:"SYSTEM_RETENTION_MIN_REPLICAS" envDefault:"1"`       // providers that must fully download a bag before it is removed from the node
+	RetentionMinProofs         int                `env:"SYSTEM_RETENTION_MIN_PROOFS" envDefault:"0"`         // valid storage proofs required before removal
+	RetentionFailedHold        time.Duration      `env:"SYSTEM_RETENTION_FAILED_HOLD" envDefault:"168h"`     // how long a bag that failed replication is kept
+	ReadinessWorkers           string             `env:"SYSTEM_READINESS_WORKERS" envDefault:"CollectContractProvidersToNotify,TriggerProvidersDownload,DownloadChecker,RemoveNotifiedFiles"`
+	ReadinessWindow            time.Duration      `env:"SYSTEM_READINESS_WINDOW" envDefault:"30m"`     // critical workers must succeed within this window
+	ShutdownGracePeriod        time.Duration      `env:"SYSTEM_SHUTDOWN_GRACE_PERIOD" envDefault:"1m"` // time for in flight requests, uploads and worker iterations to finish
 }
 
 type Metrics struct {
@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"crypto/ed25519"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -14,6 +16,8 @@ import (
 	"github.com/xssnick/tonutils-go/adnl/dht"
 	"github.com/xssnick/tonutils-go/liteclient"
 	"github.com/xssnick/tonutils-storage-provider/pkg/transport"
+
+	"mytonstorage-backend/pkg/services/auth"
 )
 
 func connectPostgres(ctx context.Context, config *Config, logger *slog.Logger) (connPool *pgxpool.Pool, err error) {
@@ -85,7 +89,8 @@ func newPostgresConfig(config *Config, logger *slog.Logger) (dbConfig *pgxpool.C
 	return
 }
 
-func newProviderClient(ctx context.Context, configURL, ADNLPort string, privateKey ed25519.PrivateKey) (dc *dht.Client, tc *transport.Client, err error) {
+// newProviderClient returns closeGateways, which closes the provider gateway, then DHT and the shared UDP listener
+func newProviderClient(ctx context.Context, configURL, ADNLPort string, privateKey ed25519.PrivateKey) (dc *dht.Client, tc *transport.Client, closeGateways func(), err error) {
 	lsCfg, err := liteclient.GetConfigFromUrl(ctx, configURL)
 	if err != nil {
 		err = fmt.Errorf("failed to get liteclient config: %w", err)
@@ -126,5 +131,61 @@ func newProviderClient(ctx context.Context, configURL, ADNLPort string, privateK
 
 	tc = transport.NewClient(gateProvider, dc)
 
+	closeGateways = func() {
+		_ = gateProvider.Close()
+		dc.Close()
+		netMgr.Close()
+	}
+
+	return
+}
+
+func newAuthKeyring(config *Config) (keyring *auth.Keyring, err error) {
+	seed, err := hex.DecodeString(config.System.AuthPrivateKey)
+	if err != nil {
+		err = fmt.Errorf("failed to decode private key: %w", err)
+		return
+	}
+
+	if len(seed) != ed25519.SeedSize {
+		err = fmt.Errorf("invalid private key length: expected %d, got %d", ed25519.SeedSize, len(seed))
+		return
+	}
+
+	keyring, err = auth.NewKeyring(config.System.AuthPrivateKeyID, ed25519.NewKeyFromSeed(seed))
+	if err != nil {
+		err = fmt.Errorf("failed to create keyring: %w", err)
+		return
+	}
+
+	if config.System.AuthVerificationKeys == "" {
+		return
+	}
+
+	for _, entry := range strings.Split(config.System.AuthVerificationKeys, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 {
+			err = fmt.Errorf("invalid verification key entry, expected id:seed:expires_at")
+			return
+		}
+
+		vSeed, dErr := hex.DecodeString(parts[1])
+		if dErr != nil || len(vSeed) != ed25519.SeedSize {
+			err = fmt.Errorf("invalid seed for verification key %q", parts[0])
+			return
+		}
+
+		expiresAt, pErr := time.Parse(time.RFC3339, parts[2])
+		if pErr != nil {
+			err = fmt.Errorf("invalid expiration time for verification key %q: %w", parts[0], pErr)
+			return
+		}
+
+		if err = keyring.AddVerificationKey(parts[0], ed25519.NewKeyFromSeed(vSeed), expiresAt); err != nil {
+			err = fmt.Errorf("failed to add verification key: %w", err)
+			return
+		}
+	}
+
 	return
 }
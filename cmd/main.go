@@ -2,13 +2,12 @@ package main
 
 import (
 	"context"
-	"crypto/ed25519"
-	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -24,13 +23,17 @@ import (
 	filesRepository "mytonstorage-backend/pkg/repositories/files"
 	providersRepository "mytonstorage-backend/pkg/repositories/providers"
 	systemRepository "mytonstorage-backend/pkg/repositories/system"
+	workspacesRepository "mytonstorage-backend/pkg/repositories/workspaces"
 	"mytonstorage-backend/pkg/services/auth"
 	contractsService "mytonstorage-backend/pkg/services/contracts"
 	filesService "mytonstorage-backend/pkg/services/files"
+	"mytonstorage-backend/pkg/services/health"
 	providersService "mytonstorage-backend/pkg/services/providers"
+	workspacesService "mytonstorage-backend/pkg/services/workspaces"
 	"mytonstorage-backend/pkg/workers"
 	"mytonstorage-backend/pkg/workers/cleaner"
 	filesworker "mytonstorage-backend/pkg/workers/files"
+	providersworker "mytonstorage-backend/pkg/workers/providers"
 )
 
 func main() {
@@ -114,11 +117,56 @@ func run() (err error) {
 		[]string{"method", "error"},
 	)
 
+	providersRatesDuration := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: config.Metrics.Namespace,
+			Subsystem: config.Metrics.BasicSubsystem,
+			Name:      "providers_rates_duration",
+			Help:      "Provider rates requests duration",
+		},
+		// Providers come from request bodies, per provider latency is kept in the rates history instead
+		[]string{"result"},
+	)
+
+	providersProofChecks := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: config.Metrics.Namespace,
+			Subsystem: config.Metrics.BasicSubsystem,
+			Name:      "providers_proof_checks",
+			Help:      "Storage proofs returned by providers during download checks",
+		},
+		[]string{"provider", "result"},
+	)
+
+	workersLastSuccess := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: config.Metrics.Namespace,
+			Subsystem: config.Metrics.BasicSubsystem,
+			Name:      "workers_last_success",
+			Help:      "Unix time of the last successful worker run",
+		},
+		[]string{"worker"},
+	)
+
+	filesBacklog := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: config.Metrics.Namespace,
+			Subsystem: config.Metrics.BasicSubsystem,
+			Name:      "files_backlog",
+			Help:      "Items waiting in each step of the files pipeline",
+		},
+		[]string{"queue"},
+	)
+
 	prometheus.MustRegister(
 		dbRequestsCount,
 		dbRequestsDuration,
 		workersRunCount,
 		workersRunDuration,
+		providersRatesDuration,
+		providersProofChecks,
+		workersLastSuccess,
+		filesBacklog,
 	)
 
 	// Postgres
@@ -134,10 +182,14 @@ func run() (err error) {
 
 	systemRepo := systemRepository.NewRepository(connPool)
 	systemRepo = systemRepository.NewMetrics(dbRequestsCount, dbRequestsDuration, systemRepo)
+	workersLeader := systemRepository.NewLeader(connPool)
 
 	providerRepo := providersRepository.NewRepository(connPool)
 	providerRepo = providersRepository.NewMetrics(dbRequestsCount, dbRequestsDuration, providerRepo)
 
+	workspacesRepo := workspacesRepository.NewRepository(connPool)
+	workspacesRepo = workspacesRepository.NewMetrics(dbRequestsCount, dbRequestsDuration, workspacesRepo)
+
 	// Clients
 	tonContractsClient, err := tonclient.NewClient(context.Background(), config.TON.ConfigURL, logger)
 	if err != nil {
@@ -145,7 +197,7 @@ func run() (err error) {
 		return
 	}
 
-	_, providerClient, err := newProviderClient(context.Background(), config.TON.ConfigURL, config.System.ADNLPort, config.System.Key)
+	_, providerClient, closeGateways, err := newProviderClient(context.Background(), config.TON.ConfigURL, config.System.ADNLPort, config.System.Key)
 	if err != nil {
 		logger.Error("failed to create provider client", slog.String("error", err.Error()))
 		return
@@ -158,7 +210,7 @@ func run() (err error) {
 	storage := tonstorage.NewClient(config.TONStorage.BaseURL, config.TONStorage.BagsDirForStorage, &creds)
 
 	// Workers
-	cleanerWorker := cleaner.NewWorker(filesRepo, config.System.StoreHistoryDays, logger)
+	cleanerWorker := cleaner.NewWorker(providerRepo, config.System.StoreHistoryDays, logger)
 	cleanerWorker = cleaner.NewMetrics(workersRunCount, workersRunDuration, cleanerWorker)
 
 	filesWorker := filesworker.NewWorker(
@@ -169,20 +221,69 @@ func run() (err error) {
 		tonContractsClient,
 		config.System.UnpaidFilesLifetimePrivate,
 		config.System.PaidFilesLifetime,
+		filesworker.RetryPolicy{
+			ResolveAttempts: config.System.ResolveMaxAttempts,
+			NotifyAttempts:  config.System.NotifyMaxAttempts,
+			DownloadChecks:  config.System.DownloadMaxChecks,
+			BackoffBase:     config.System.RetryBackoffBase,
+			BackoffMax:      config.System.RetryBackoffMax,
+			BackoffJitter:   config.System.RetryBackoffJitter,
+		},
+		filesworker.RetentionPolicy{
+			MinReplicas: config.System.RetentionMinReplicas,
+			MinProofs:   config.System.RetentionMinProofs,
+			FailedHold:  config.System.RetentionFailedHold,
+		},
+		providersProofChecks,
+		filesBacklog,
+		config.System.ProviderChecksWorkers,
+		config.System.ProviderChecksConcurrency,
+		config.System.ProviderChecksInterval,
 		logger,
 	)
 	filesWorker = filesworker.NewMetrics(workersRunCount, workersRunDuration, filesWorker)
 
+	keyring, err := newAuthKeyring(config)
+	if err != nil {
+		logger.Error("failed to init auth keyring", slog.String("error", err.Error()))
+		return
+	}
+
 	// Services
 	providersSvc := providersService.NewService(
 		providerClient,
 		filesRepo,
 		storage,
+		providerRepo,
+		systemRepo,
+		keyring,
 		config.System.MaxAllowedSpanDays,
 		config.System.UnpaidFilesLifetimePrivate,
+		config.System.ProvidersRatesWorkers,
+		config.System.ProvidersRatesDeadline,
+		providersRatesDuration,
+		config.System.ProvidersRatesCacheTTL,
+		config.System.ProvidersDeclinesCacheTTL,
+		config.System.ProvidersQuoteTTL,
 		logger,
 	)
 
+	providersWorker := providersworker.NewWorker(
+		providerRepo,
+		filesRepo,
+		tonContractsClient,
+		providersSvc,
+		providerClient,
+		config.System.ProvidersProbeInterval,
+		config.System.ProvidersProbeBagSize,
+		config.System.ProofGracePeriod,
+		config.System.StoreHistoryDays,
+		config.System.NotifyMaxAttempts,
+		config.System.DownloadMaxChecks,
+		logger,
+	)
+	providersWorker = providersworker.NewMetrics(workersRunCount, workersRunDuration, providersWorker)
+
 	contractsSvc := contractsService.NewService(logger)
 
 	filesSvc := filesService.NewService(
@@ -196,22 +297,13 @@ func run() (err error) {
 	)
 	filesSvc = filesService.NewCacheMiddleware(filesSvc)
 
-	seed, err := hex.DecodeString(config.System.AuthPrivateKey)
-	if err != nil {
-		logger.Error("failed to decode private key", slog.String("error", err.Error()))
-		return fmt.Errorf("failed to decode private key: %w", err)
-	}
+	workspacesSvc := workspacesService.NewService(workspacesRepo, logger)
 
-	if len(seed) != ed25519.SeedSize {
-		logger.Error("invalid private key length", slog.Int("expected", ed25519.SeedSize), slog.Int("got", len(seed)))
-		return fmt.Errorf("invalid private key length: expected %d, got %d", ed25519.SeedSize, len(seed))
-	}
-
-	authSvc := auth.New(verifier, ed25519.NewKeyFromSeed(seed), config.System.Host, logger)
+	authSvc := auth.New(verifier, keyring, workspacesRepo, config.System.Host, logger)
 
 	// Start workers
 	cancelCtx, cancel := context.WithCancel(context.Background())
-	workers := workers.NewWorkers(filesWorker, cleanerWorker, logger)
+	workers := workers.NewWorkers(filesWorker, providersWorker, cleanerWorker, workersLeader, systemRepo, workersLastSuccess, logger)
 	go func() {
 		if wErr := workers.Start(cancelCtx); wErr != nil {
 			logger.Error("failed to start workers", slog.String("error", wErr.Error()))
@@ -220,6 +312,16 @@ func run() (err error) {
 		}
 	}()
 
+	healthSvc := health.NewService(
+		connPool,
+		storage,
+		api,
+		workers,
+		strings.Split(config.System.ReadinessWorkers, ","),
+		config.System.ReadinessWindow,
+		logger,
+	)
+
 	// HTTP Server
 	adminAuthTokens := strings.Split(config.System.AdminAuthTokens, ",")
 	app := fiber.New(fiber.Config{
@@ -244,6 +346,9 @@ func run() (err error) {
 		filesSvc,
 		providersSvc,
 		contractsSvc,
+		workspacesSvc,
+		workers,
+		healthSvc,
 		authSvc,
 		adminAuthTokens,
 		config.Metrics.Namespace,
@@ -263,13 +368,44 @@ func run() (err error) {
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 
 	<-signalChan
-	cancel()
+	logger.Info("shutting down", slog.Duration("grace_period", config.System.ShutdownGracePeriod))
+
+	/*
+		Сначала перестаем принимать новые загрузки, затем в пределах grace period ждем
+		текущие запросы, загрузки и итерации воркеров. Загрузки, которые не успели, удаляются с диска.
+		После этого закрываем ADNL шлюзы и только в конце пул Postgres, которым пользуются все остальные.
+	*/
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), config.System.ShutdownGracePeriod)
+	defer shutdownCancel()
+
+	var drain sync.WaitGroup
+	drain.Add(2)
+	go func() {
+		defer drain.Done()
+		if dErr := filesSvc.DrainUploads(shutdownCtx); dErr != nil {
+			logger.Error("uploads did not finish in time", slog.String("err", dErr.Error()))
+		}
+	}()
+	go func() {
+		defer drain.Done()
+		if wErr := workers.Stop(shutdownCtx); wErr != nil {
+			logger.Error("workers did not stop in time", slog.String("err", wErr.Error()))
+		}
+	}()
 
-	err = app.ShutdownWithTimeout(time.Second * 5)
+	err = app.ShutdownWithContext(shutdownCtx)
 	if err != nil {
 		logger.Error("server shut down error", slog.String("err", err.Error()))
-		return err
 	}
 
+	drain.Wait()
+	cancel()
+
+	closeGateways()
+	client.Stop()
+	connPool.Close()
+
+	logger.Info("shut down completed")
+
 	return err
 }
@@ -21,6 +21,9 @@ CREATE TABLE IF NOT EXISTS system.params
 INSERT INTO system.params (key, value) VALUES ('max_files_count', (5000)::text)
 ON CONFLICT (key) DO NOTHING;
 
+INSERT INTO system.params (key, value) VALUES ('providers_allowlist_only', 'false')
+ON CONFLICT (key) DO NOTHING;
+
 CREATE TABLE IF NOT EXISTS providers.notifications
 (
     provider_pubkey character varying(64) COLLATE pg_catalog."default" NOT NULL,
@@ -32,9 +35,14 @@ CREATE TABLE IF NOT EXISTS providers.notifications
     download_checks smallint NOT NULL DEFAULT 0,
     downloaded bigint NOT NULL DEFAULT 0,
     notified boolean NOT NULL DEFAULT false,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    downloaded_at timestamp with time zone,
+    next_attempt_at timestamp with time zone NOT NULL DEFAULT now(),
     CONSTRAINT notifications_pkey PRIMARY KEY (provider_pubkey, storage_contract)
 );
 
+CREATE INDEX IF NOT EXISTS notifications_next_attempt_at_idx ON providers.notifications (next_attempt_at);
+
 CREATE TABLE IF NOT EXISTS providers.notifications_history
 (
     provider_pubkey character varying(64) COLLATE pg_catalog."default" NOT NULL,
@@ -45,9 +53,102 @@ CREATE TABLE IF NOT EXISTS providers.notifications_history
     archived_at timestamp without time zone NOT NULL DEFAULT now(),
     download_checks integer DEFAULT 0,
     downloaded bigint DEFAULT 0,
+    notified boolean NOT NULL DEFAULT false,
+    created_at timestamp with time zone,
+    downloaded_at timestamp with time zone,
     CONSTRAINT notifications_history_pkey PRIMARY KEY (provider_pubkey, storage_contract)
 );
 
+CREATE TABLE IF NOT EXISTS providers.registry
+(
+    pubkey character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    first_seen_at timestamp with time zone NOT NULL DEFAULT now(),
+    last_seen_at timestamp with time zone,
+    rate_per_mb_day bigint NOT NULL DEFAULT 0,
+    min_bounty bigint NOT NULL DEFAULT 0,
+    min_span integer NOT NULL DEFAULT 0,
+    max_span integer NOT NULL DEFAULT 0,
+    space_available_mb bigint NOT NULL DEFAULT 0,
+    status character varying(16) COLLATE pg_catalog."default" NOT NULL DEFAULT 'unknown',
+    updated_at timestamp with time zone DEFAULT now(),
+    CONSTRAINT registry_pkey PRIMARY KEY (pubkey)
+);
+
+CREATE TABLE IF NOT EXISTS providers.rates_history
+(
+    pubkey character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    checked_at timestamp with time zone NOT NULL DEFAULT now(),
+    reachable boolean NOT NULL,
+    available boolean NOT NULL DEFAULT false,
+    rate_per_mb_day bigint NOT NULL DEFAULT 0,
+    min_bounty bigint NOT NULL DEFAULT 0,
+    space_available_mb bigint NOT NULL DEFAULT 0,
+    latency_ms integer NOT NULL DEFAULT 0,
+    CONSTRAINT rates_history_pkey PRIMARY KEY (pubkey, checked_at)
+);
+
+CREATE INDEX IF NOT EXISTS rates_history_checked_at_idx ON providers.rates_history (checked_at);
+
+CREATE TABLE IF NOT EXISTS providers.proof_audit
+(
+    id bigserial NOT NULL,
+    provider_pubkey character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    storage_contract character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    bagid character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    byte_to_proof bigint NOT NULL,
+    piece bigint NOT NULL,
+    valid boolean NOT NULL,
+    error text COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    checked_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT proof_audit_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS proof_audit_provider_idx ON providers.proof_audit (provider_pubkey, checked_at);
+CREATE INDEX IF NOT EXISTS proof_audit_checked_at_idx ON providers.proof_audit (checked_at);
+CREATE INDEX IF NOT EXISTS proof_audit_bagid_idx ON providers.proof_audit (bagid);
+
+CREATE TABLE IF NOT EXISTS providers.proof_incidents
+(
+    id bigserial NOT NULL,
+    storage_contract character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    provider_pubkey character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    bagid character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    user_address character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    bag_size bigint NOT NULL DEFAULT 0,
+    last_proof_at timestamp with time zone NOT NULL,
+    max_span integer NOT NULL,
+    detected_at timestamp with time zone NOT NULL DEFAULT now(),
+    resolved_at timestamp with time zone,
+    replacement_pubkey character varying(64) COLLATE pg_catalog."default",
+    replacement_tx jsonb,
+    CONSTRAINT proof_incidents_pkey PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS proof_incidents_open_idx ON providers.proof_incidents (storage_contract, provider_pubkey) WHERE resolved_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS providers.reputation
+(
+    pubkey character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    score double precision NOT NULL DEFAULT 0,
+    notify_success_rate double precision,
+    avg_download_seconds bigint,
+    abandoned_rate double precision,
+    proof_lag_seconds bigint,
+    samples integer NOT NULL DEFAULT 0,
+    updated_at timestamp with time zone DEFAULT now(),
+    CONSTRAINT reputation_pkey PRIMARY KEY (pubkey)
+);
+
+CREATE TABLE IF NOT EXISTS providers.policy
+(
+    pubkey character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    status character varying(16) COLLATE pg_catalog."default" NOT NULL,
+    note text COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    created_at timestamp with time zone DEFAULT now(),
+    updated_at timestamp with time zone DEFAULT now(),
+    CONSTRAINT policy_pkey PRIMARY KEY (pubkey)
+);
+
 CREATE TABLE IF NOT EXISTS files.bag_users
 (
     bagid character varying(64) COLLATE pg_catalog."default" NOT NULL,
@@ -56,9 +157,16 @@ CREATE TABLE IF NOT EXISTS files.bag_users
     created_at timestamp with time zone DEFAULT now(),
     updated_at timestamp with time zone DEFAULT now(),
     notify_attempts smallint NOT NULL DEFAULT 0,
+    workspace_id integer,
+    state character varying(32) COLLATE pg_catalog."default" NOT NULL DEFAULT 'uploaded',
+    state_reason text COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    state_updated_at timestamp with time zone DEFAULT now(),
+    next_attempt_at timestamp with time zone NOT NULL DEFAULT now(),
     CONSTRAINT bag_users_pkey PRIMARY KEY (bagid, user_address)
 );
 
+CREATE INDEX IF NOT EXISTS bag_users_state_idx ON files.bag_users (state);
+
 CREATE TABLE IF NOT EXISTS files.bag_users_history
 (
     bagid character varying(64) COLLATE pg_catalog."default" NOT NULL,
@@ -66,9 +174,35 @@ CREATE TABLE IF NOT EXISTS files.bag_users_history
     storage_contract character varying(64) COLLATE pg_catalog."default",
     deleted_at timestamp with time zone DEFAULT now(),
     notify_attempts smallint NOT NULL DEFAULT 0,
+    transferred_to character varying(64) COLLATE pg_catalog."default",
+    state character varying(32) COLLATE pg_catalog."default",
     CONSTRAINT bag_users_history_pkey PRIMARY KEY (bagid, user_address)
 );
 
+CREATE TABLE IF NOT EXISTS files.bag_states_history
+(
+    id bigserial NOT NULL,
+    bagid character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    user_address character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    storage_contract character varying(64) COLLATE pg_catalog."default",
+    from_state character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    to_state character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    reason text COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT bag_states_history_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS bag_states_history_bagid_idx ON files.bag_states_history (bagid, created_at);
+
+CREATE TABLE IF NOT EXISTS files.bag_transfers
+(
+    bagid character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    from_address character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    to_address character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    created_at timestamp with time zone DEFAULT now(),
+    CONSTRAINT bag_transfers_pkey PRIMARY KEY (bagid, from_address)
+);
+
 CREATE TABLE IF NOT EXISTS files.bags
 (
     bagid character varying(64) COLLATE pg_catalog."default" NOT NULL,
@@ -79,6 +213,25 @@ CREATE TABLE IF NOT EXISTS files.bags
     CONSTRAINT bag_pkey PRIMARY KEY (bagid)
 );
 
+CREATE TABLE IF NOT EXISTS files.bag_pins
+(
+    bagid character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    note text COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT bag_pins_pkey PRIMARY KEY (bagid)
+);
+
+CREATE TABLE IF NOT EXISTS files.bag_retention
+(
+    bagid character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    decision character varying(16) COLLATE pg_catalog."default" NOT NULL,
+    reason text COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    replicas integer NOT NULL DEFAULT 0,
+    proofs integer NOT NULL DEFAULT 0,
+    decided_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT bag_retention_pkey PRIMARY KEY (bagid)
+);
+
 CREATE TABLE IF NOT EXISTS files.blacklist
 (
     bagid character varying(64) COLLATE pg_catalog."default" NOT NULL,
@@ -127,6 +280,29 @@ CREATE TABLE IF NOT EXISTS files.reports_archive
     CONSTRAINT reports_archive_bagid_admin_key UNIQUE (bagid, admin)
 );
 
+CREATE TABLE IF NOT EXISTS files.workspaces
+(
+    id SERIAL NOT NULL,
+    name character varying(128) COLLATE pg_catalog."default" NOT NULL,
+    owner_address character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    created_at timestamp with time zone DEFAULT now(),
+    CONSTRAINT workspaces_pkey PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS files.workspace_members
+(
+    workspace_id integer NOT NULL,
+    user_address character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    role character varying(16) COLLATE pg_catalog."default" NOT NULL,
+    invited_by character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    invited_at timestamp with time zone DEFAULT now(),
+    accepted_at timestamp with time zone,
+    CONSTRAINT workspace_members_pkey PRIMARY KEY (workspace_id, user_address),
+    CONSTRAINT workspace_members_workspace_fkey FOREIGN KEY (workspace_id)
+        REFERENCES files.workspaces (id) ON DELETE CASCADE,
+    CONSTRAINT workspace_members_role_check CHECK (role IN ('owner', 'uploader', 'viewer'))
+);
+
 -- TRIGGERS AND FUNCTIONS
 
 CREATE FUNCTION files.log_blacklist_changes()
@@ -183,9 +359,14 @@ CREATE FUNCTION files.bag_users_history_insert()
     VOLATILE NOT LEAKPROOF
 AS $BODY$
 BEGIN
-    INSERT INTO files.bag_users_history (bagid, user_address, storage_contract, deleted_at, notify_attempts)
-    VALUES (OLD.bagid, OLD.user_address, OLD.storage_contract, now(), OLD.notify_attempts)
-    ON CONFLICT (bagid, user_address) DO NOTHING;
+    INSERT INTO files.bag_users_history (bagid, user_address, storage_contract, deleted_at, notify_attempts, state)
+    VALUES (OLD.bagid, OLD.user_address, OLD.storage_contract, now(), OLD.notify_attempts, OLD.state)
+    ON CONFLICT (bagid, user_address) DO UPDATE
+        SET storage_contract = EXCLUDED.storage_contract,
+            deleted_at = EXCLUDED.deleted_at,
+            notify_attempts = EXCLUDED.notify_attempts,
+            state = EXCLUDED.state,
+            transferred_to = NULL;
     RETURN OLD;
 END;
 $BODY$;
@@ -197,8 +378,8 @@ CREATE FUNCTION providers.notifications_history_insert()
     VOLATILE NOT LEAKPROOF
 AS $BODY$
 BEGIN
-    INSERT INTO providers.notifications_history (provider_pubkey, bagid, storage_contract, size, notify_attempts, download_checks, downloaded, archived_at)
-    VALUES (OLD.provider_pubkey, OLD.bagid, OLD.storage_contract, OLD.size, OLD.notify_attempts, OLD.download_checks, OLD.downloaded, now());
+    INSERT INTO providers.notifications_history (provider_pubkey, bagid, storage_contract, size, notify_attempts, download_checks, downloaded, notified, created_at, downloaded_at, archived_at)
+    VALUES (OLD.provider_pubkey, OLD.bagid, OLD.storage_contract, OLD.size, OLD.notify_attempts, OLD.download_checks, OLD.downloaded, OLD.notified, OLD.created_at, OLD.downloaded_at, now());
     RETURN OLD;
 END;
 $BODY$;
@@ -0,0 +1,92 @@
+-- Upgrades a database created before bag lifecycle states were introduced.
+-- db/init.sql only creates missing tables, so existing bag_users rows must get their state here.
+-- The script is idempotent, rows that already have a state are not touched.
+
+BEGIN;
+
+ALTER TABLE files.bag_users ADD COLUMN IF NOT EXISTS state character varying(32) COLLATE pg_catalog."default";
+ALTER TABLE files.bag_users ADD COLUMN IF NOT EXISTS state_reason text COLLATE pg_catalog."default" NOT NULL DEFAULT '';
+ALTER TABLE files.bag_users ADD COLUMN IF NOT EXISTS state_updated_at timestamp with time zone DEFAULT now();
+
+ALTER TABLE files.bag_users_history ADD COLUMN IF NOT EXISTS state character varying(32) COLLATE pg_catalog."default";
+-- Written by the history trigger below, the column comes with bag transfers
+ALTER TABLE files.bag_users_history ADD COLUMN IF NOT EXISTS transferred_to character varying(64) COLLATE pg_catalog."default";
+-- Read by the backfill below, the column comes with provider reputation
+ALTER TABLE providers.notifications_history ADD COLUMN IF NOT EXISTS notified boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS files.bag_states_history
+(
+    id bigserial NOT NULL,
+    bagid character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    user_address character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    storage_contract character varying(64) COLLATE pg_catalog."default",
+    from_state character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    to_state character varying(32) COLLATE pg_catalog."default" NOT NULL,
+    reason text COLLATE pg_catalog."default" NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT bag_states_history_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS bag_states_history_bagid_idx ON files.bag_states_history (bagid, created_at);
+
+-- Unpaid relations have no contract, paid ones are placed by the progress of their provider notifications.
+-- Relations whose notifications were already archived (notify_attempts = -1) are finished and placed by the history.
+WITH n AS (
+    SELECT bagid, storage_contract,
+        bool_or(downloaded > 0 AND downloaded = size) AS replicated,
+        bool_or(notified) AS notified,
+        count(*) AS providers
+    FROM providers.notifications
+    GROUP BY bagid, storage_contract
+), h AS (
+    SELECT bagid, storage_contract,
+        bool_or(downloaded > 0 AND downloaded = size) AS replicated,
+        bool_or(notified) AS notified
+    FROM providers.notifications_history
+    GROUP BY bagid, storage_contract
+)
+UPDATE files.bag_users bu
+SET state = CASE
+        WHEN bu.storage_contract IS NULL THEN 'uploaded'
+        WHEN n.replicated THEN 'replicated'
+        WHEN n.notified THEN 'downloading'
+        WHEN n.providers > 0 THEN 'providers_resolved'
+        WHEN bu.notify_attempts < 0 AND h.replicated THEN 'released'
+        WHEN bu.notify_attempts < 0 AND h.notified THEN 'download_failed'
+        WHEN bu.notify_attempts < 0 THEN 'notify_failed'
+        ELSE 'paid'
+    END,
+    state_reason = 'backfilled by upgrade',
+    state_updated_at = now()
+FROM files.bag_users b
+LEFT JOIN n ON n.bagid = b.bagid AND n.storage_contract = b.storage_contract
+LEFT JOIN h ON h.bagid = b.bagid AND h.storage_contract = b.storage_contract
+WHERE bu.bagid = b.bagid
+    AND bu.user_address = b.user_address
+    AND bu.state IS NULL;
+
+ALTER TABLE files.bag_users ALTER COLUMN state SET DEFAULT 'uploaded';
+ALTER TABLE files.bag_users ALTER COLUMN state SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS bag_users_state_idx ON files.bag_users (state);
+
+CREATE OR REPLACE FUNCTION files.bag_users_history_insert()
+    RETURNS trigger
+    LANGUAGE plpgsql
+    COST 100
+    VOLATILE NOT LEAKPROOF
+AS $BODY$
+BEGIN
+    INSERT INTO files.bag_users_history (bagid, user_address, storage_contract, deleted_at, notify_attempts, state)
+    VALUES (OLD.bagid, OLD.user_address, OLD.storage_contract, now(), OLD.notify_attempts, OLD.state)
+    ON CONFLICT (bagid, user_address) DO UPDATE
+        SET storage_contract = EXCLUDED.storage_contract,
+            deleted_at = EXCLUDED.deleted_at,
+            notify_attempts = EXCLUDED.notify_attempts,
+            state = EXCLUDED.state,
+            transferred_to = NULL;
+    RETURN OLD;
+END;
+$BODY$;
+
+COMMIT;
@@ -0,0 +1,32 @@
+-- Upgrades a database created before team workspaces were introduced.
+-- db/init.sql only creates missing tables, the workspace of a bag relation is a new column of an existing one.
+-- The script is idempotent.
+
+BEGIN;
+
+ALTER TABLE files.bag_users ADD COLUMN IF NOT EXISTS workspace_id integer;
+
+CREATE TABLE IF NOT EXISTS files.workspaces
+(
+    id SERIAL NOT NULL,
+    name character varying(128) COLLATE pg_catalog."default" NOT NULL,
+    owner_address character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    created_at timestamp with time zone DEFAULT now(),
+    CONSTRAINT workspaces_pkey PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS files.workspace_members
+(
+    workspace_id integer NOT NULL,
+    user_address character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    role character varying(16) COLLATE pg_catalog."default" NOT NULL,
+    invited_by character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    invited_at timestamp with time zone DEFAULT now(),
+    accepted_at timestamp with time zone,
+    CONSTRAINT workspace_members_pkey PRIMARY KEY (workspace_id, user_address),
+    CONSTRAINT workspace_members_workspace_fkey FOREIGN KEY (workspace_id)
+        REFERENCES files.workspaces (id) ON DELETE CASCADE,
+    CONSTRAINT workspace_members_role_check CHECK (role IN ('owner', 'uploader', 'viewer'))
+);
+
+COMMIT;
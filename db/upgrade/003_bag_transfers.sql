@@ -0,0 +1,18 @@
+-- Upgrades a database created before bag ownership transfers were introduced.
+-- db/init.sql only creates missing tables, the transfer mark of a history row is a new column of an existing one.
+-- The history trigger that clears the mark is installed by 001_bag_lifecycle.sql. The script is idempotent.
+
+BEGIN;
+
+ALTER TABLE files.bag_users_history ADD COLUMN IF NOT EXISTS transferred_to character varying(64) COLLATE pg_catalog."default";
+
+CREATE TABLE IF NOT EXISTS files.bag_transfers
+(
+    bagid character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    from_address character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    to_address character varying(64) COLLATE pg_catalog."default" NOT NULL,
+    created_at timestamp with time zone DEFAULT now(),
+    CONSTRAINT bag_transfers_pkey PRIMARY KEY (bagid, from_address)
+);
+
+COMMIT;
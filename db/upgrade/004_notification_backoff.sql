@@ -0,0 +1,32 @@
+-- Upgrades a database created before per-item backoff of the notification pipeline was introduced.
+-- db/init.sql only creates missing tables, due times and notification timestamps are new columns of existing ones.
+-- The script is idempotent.
+
+BEGIN;
+
+ALTER TABLE files.bag_users ADD COLUMN IF NOT EXISTS next_attempt_at timestamp with time zone NOT NULL DEFAULT now();
+
+ALTER TABLE providers.notifications ADD COLUMN IF NOT EXISTS created_at timestamp with time zone NOT NULL DEFAULT now();
+ALTER TABLE providers.notifications ADD COLUMN IF NOT EXISTS downloaded_at timestamp with time zone;
+ALTER TABLE providers.notifications ADD COLUMN IF NOT EXISTS next_attempt_at timestamp with time zone NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS notifications_next_attempt_at_idx ON providers.notifications (next_attempt_at);
+
+ALTER TABLE providers.notifications_history ADD COLUMN IF NOT EXISTS notified boolean NOT NULL DEFAULT false;
+ALTER TABLE providers.notifications_history ADD COLUMN IF NOT EXISTS created_at timestamp with time zone;
+ALTER TABLE providers.notifications_history ADD COLUMN IF NOT EXISTS downloaded_at timestamp with time zone;
+
+CREATE OR REPLACE FUNCTION providers.notifications_history_insert()
+    RETURNS trigger
+    LANGUAGE plpgsql
+    COST 100
+    VOLATILE NOT LEAKPROOF
+AS $BODY$
+BEGIN
+    INSERT INTO providers.notifications_history (provider_pubkey, bagid, storage_contract, size, notify_attempts, download_checks, downloaded, notified, created_at, downloaded_at, archived_at)
+    VALUES (OLD.provider_pubkey, OLD.bagid, OLD.storage_contract, OLD.size, OLD.notify_attempts, OLD.download_checks, OLD.downloaded, OLD.notified, OLD.created_at, OLD.downloaded_at, now());
+    RETURN OLD;
+END;
+$BODY$;
+
+COMMIT;
@@ -9,6 +9,7 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/jackc/pgx/v5 v5.7.5
 	github.com/prometheus/client_golang v1.22.0
+	github.com/syndtr/goleveldb v1.0.0
 	github.com/xssnick/tonutils-storage-provider v0.3.10
 )
 
@@ -23,7 +24,6 @@ require (
 	github.com/lithammer/fuzzysearch v1.1.8 // indirect
 	github.com/pterm/pterm v0.12.81 // indirect
 	github.com/rs/zerolog v1.34.0 // indirect
-	github.com/syndtr/goleveldb v1.0.0 // indirect
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
 	github.com/xssnick/raptorq v1.0.0 // indirect
 	golang.org/x/term v0.33.0 // indirect
@@ -56,7 +56,7 @@ require (
 	github.com/xssnick/tonutils-storage v1.1.5
 	golang.org/x/crypto v0.40.0 // indirect
 	golang.org/x/exp v0.0.0-20250911091902-df9299821621
-	golang.org/x/sync v0.17.0 // indirect
+	golang.org/x/sync v0.17.0
 	golang.org/x/sys v0.34.0 // indirect
 	golang.org/x/text v0.27.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
@@ -0,0 +1,17 @@
+package providersclient
+
+import (
+	"context"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-storage-provider/pkg/transport"
+)
+
+// Transport is the part of the storage provider protocol used by the backend.
+// Implemented by transport.Client over ADNL and by Simulator in process.
+type Transport interface {
+	GetStorageRates(ctx context.Context, provider []byte, size uint64) (*transport.StorageRatesResponse, error)
+	RequestStorageInfo(ctx context.Context, provider []byte, contractAddr *address.Address, byteToProof uint64) (*transport.StorageResponse, error)
+}
+
+var _ Transport = (*transport.Client)(nil)
@@ -0,0 +1,287 @@
+package providersclient
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+	"github.com/xssnick/tonutils-storage-provider/pkg/transport"
+)
+
+// SimulatedProvider describes behaviour of a single provider of the simulated network
+type SimulatedProvider struct {
+	RatePerMBDay     uint64 // nanoTON
+	MinBounty        uint64 // nanoTON
+	SpaceAvailableMB uint64
+	MinSpan          uint32
+	MaxSpan          uint32
+	Unavailable      bool
+
+	Latency     time.Duration
+	FailureRate float64 // share of requests that fail as network errors
+
+	ResolveDelay  time.Duration // time spent in "resolving" after the first storage request
+	DownloadSpeed uint64        // bytes per second, zero downloads instantly
+	DownloadLimit float64       // share of the bag the provider ever gets, zero means the whole bag
+
+	// ProofFailureRate is a share of storage responses without proof for a downloaded bag
+	ProofFailureRate float64
+	// InvalidProofRate is a share of proofs replaced with random bytes, such proofs fail verification
+	InvalidProofRate float64
+}
+
+// Bags of simulated contracts are split into pieces of this size, the same as bags created by the node
+const simulatedPieceSize = 128 << 10
+
+// SimulatedBag is the merkle tree of random pieces generated for a contract, proofs of providers are built from it.
+// The node side of a test has to report the same merkle hash and pieces for the bag to verify them.
+type SimulatedBag struct {
+	Size       uint64
+	PieceSize  uint32
+	PiecesNum  uint32
+	MerkleHash []byte
+
+	tree *cell.Cell
+}
+
+// proof builds a merkle proof of the bag tree keeping the branch of the piece, as tonutils-storage does for peers
+func (b *SimulatedBag) proof(piece uint32) ([]byte, error) {
+	sk := cell.CreateProofSkeleton()
+
+	branch := sk
+	for i := bits.Len32(b.PiecesNum-1) - 1; i >= 0; i-- {
+		branch = branch.ProofRef(int(piece>>i) & 1)
+	}
+
+	proof, err := b.tree.CreateProof(sk)
+	if err != nil {
+		return nil, err
+	}
+
+	return proof.ToBOC(), nil
+}
+
+func newSimulatedBag(rnd *rand.Rand, size uint64) *SimulatedBag {
+	piecesNum := uint32(max((size+simulatedPieceSize-1)/simulatedPieceSize, 1))
+
+	// Leaves are piece hashes padded with zero hashes up to a power of two
+	leaves := make([]*cell.Cell, 1<<bits.Len32(piecesNum-1))
+	for i := range leaves {
+		hash := make([]byte, 32)
+		if uint32(i) < piecesNum {
+			rnd.Read(hash)
+		}
+		leaves[i] = cell.BeginCell().MustStoreSlice(hash, 256).EndCell()
+	}
+
+	for len(leaves) > 1 {
+		next := make([]*cell.Cell, len(leaves)/2)
+		for i := range next {
+			next[i] = cell.BeginCell().MustStoreRef(leaves[2*i]).MustStoreRef(leaves[2*i+1]).EndCell()
+		}
+		leaves = next
+	}
+
+	return &SimulatedBag{
+		Size:       size,
+		PieceSize:  simulatedPieceSize,
+		PiecesNum:  piecesNum,
+		MerkleHash: leaves[0].Hash(),
+		tree:       leaves[0],
+	}
+}
+
+type simulatedDownload struct {
+	startedAt time.Time
+}
+
+// Simulator is an in process provider network for running the pipeline without ADNL.
+// Contracts have to be registered with their bag size, providers learn about them on the first storage request
+// the same way real providers do and report progress according to their configuration.
+type Simulator struct {
+	mu        sync.Mutex
+	rnd       *rand.Rand
+	providers map[string]SimulatedProvider
+	contracts map[string]*SimulatedBag
+	downloads map[string]simulatedDownload
+}
+
+func (s *Simulator) AddProvider(pubkey []byte, p SimulatedProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.providers[hex.EncodeToString(pubkey)] = p
+}
+
+// AddRandomProviders adds n providers with prices, latency and reliability spread around typical values
+func (s *Simulator) AddRandomProviders(n int) (pubkeys [][]byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		seed := make([]byte, ed25519.SeedSize)
+		s.rnd.Read(seed)
+		pubkey := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+
+		s.providers[hex.EncodeToString(pubkey)] = SimulatedProvider{
+			RatePerMBDay:     uint64(s.rnd.Int63n(100_000) + 10_000),
+			MinBounty:        uint64(s.rnd.Int63n(50_000_000)),
+			SpaceAvailableMB: uint64(s.rnd.Int63n(1<<20) + 1<<10),
+			MinSpan:          3600,
+			MaxSpan:          uint32(s.rnd.Int63n(7*24*3600-86400) + 86400),
+			Latency:          time.Duration(s.rnd.Int63n(int64(500 * time.Millisecond))),
+			FailureRate:      s.rnd.Float64() * 0.1,
+			ResolveDelay:     time.Duration(s.rnd.Int63n(int64(10 * time.Second))),
+			DownloadSpeed:    uint64(s.rnd.Int63n(50<<20) + 1<<20),
+			ProofFailureRate: s.rnd.Float64() * 0.05,
+			InvalidProofRate: s.rnd.Float64() * 0.02,
+		}
+
+		pubkeys = append(pubkeys, pubkey)
+	}
+
+	return
+}
+
+// AddContract makes the storage contract known to the network and generates the bag stored by it
+func (s *Simulator) AddContract(contractAddr *address.Address, bagSize uint64) *SimulatedBag {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bag := newSimulatedBag(s.rnd, bagSize)
+	s.contracts[hex.EncodeToString(contractAddr.Data())] = bag
+
+	return bag
+}
+
+func (s *Simulator) GetStorageRates(ctx context.Context, provider []byte, size uint64) (*transport.StorageRatesResponse, error) {
+	p, err := s.request(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	available := !p.Unavailable && size>>20 <= p.SpaceAvailableMB
+
+	return &transport.StorageRatesResponse{
+		Available:        available,
+		RatePerMBDay:     new(big.Int).SetUint64(p.RatePerMBDay).Bytes(),
+		MinBounty:        new(big.Int).SetUint64(p.MinBounty).Bytes(),
+		SpaceAvailableMB: p.SpaceAvailableMB,
+		MinSpan:          p.MinSpan,
+		MaxSpan:          p.MaxSpan,
+	}, nil
+}
+
+func (s *Simulator) RequestStorageInfo(ctx context.Context, provider []byte, contractAddr *address.Address, byteToProof uint64) (*transport.StorageResponse, error) {
+	p, err := s.request(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bag, ok := s.contracts[hex.EncodeToString(contractAddr.Data())]
+	if !ok {
+		return &transport.StorageResponse{
+			Status: "error",
+			Reason: "contract is not deployed",
+		}, nil
+	}
+
+	key := hex.EncodeToString(provider) + ":" + hex.EncodeToString(contractAddr.Data())
+	d, ok := s.downloads[key]
+	if !ok {
+		d = simulatedDownload{startedAt: time.Now()}
+		s.downloads[key] = d
+	}
+
+	elapsed := time.Since(d.startedAt) - p.ResolveDelay
+	if elapsed < 0 {
+		return &transport.StorageResponse{Status: "resolving"}, nil
+	}
+
+	limit := bag.Size
+	if p.DownloadLimit > 0 {
+		limit = uint64(float64(bag.Size) * p.DownloadLimit)
+	}
+
+	downloaded := limit
+	if p.DownloadSpeed > 0 {
+		downloaded = min(limit, uint64(elapsed.Seconds()*float64(p.DownloadSpeed)))
+	}
+
+	if downloaded < bag.Size {
+		status := "downloading"
+		if downloaded == 0 {
+			status = "resolving"
+		}
+
+		return &transport.StorageResponse{Status: status, Downloaded: downloaded}, nil
+	}
+
+	if byteToProof >= bag.Size {
+		return nil, fmt.Errorf("failed to do request: byte is not exist in the given bag")
+	}
+
+	var proof []byte
+	switch {
+	case s.rnd.Float64() < p.ProofFailureRate:
+	case s.rnd.Float64() < p.InvalidProofRate:
+		proof = make([]byte, 32)
+		s.rnd.Read(proof)
+	default:
+		if proof, err = bag.proof(uint32(byteToProof / uint64(bag.PieceSize))); err != nil {
+			return nil, fmt.Errorf("failed to do request: %w", err)
+		}
+	}
+
+	return &transport.StorageResponse{
+		Status:     "active",
+		Downloaded: downloaded,
+		Proof:      proof,
+	}, nil
+}
+
+// request emulates connection to the provider: latency and network failures
+func (s *Simulator) request(ctx context.Context, provider []byte) (SimulatedProvider, error) {
+	s.mu.Lock()
+	p, ok := s.providers[hex.EncodeToString(provider)]
+	fail := ok && s.rnd.Float64() < p.FailureRate
+	s.mu.Unlock()
+
+	if !ok {
+		return p, fmt.Errorf("failed to connect to provider: provider not found in dht")
+	}
+
+	select {
+	case <-ctx.Done():
+		return p, fmt.Errorf("failed to do request: %w", ctx.Err())
+	case <-time.After(p.Latency):
+	}
+
+	if fail {
+		return p, fmt.Errorf("failed to do request: simulated network failure")
+	}
+
+	return p, nil
+}
+
+func NewSimulator(seed int64) *Simulator {
+	return &Simulator{
+		rnd:       rand.New(rand.NewSource(seed)),
+		providers: make(map[string]SimulatedProvider),
+		contracts: make(map[string]*SimulatedBag),
+		downloads: make(map[string]simulatedDownload),
+	}
+}
+
+var _ Transport = (*Simulator)(nil)
@@ -16,6 +16,16 @@ type files interface {
 	MarkBagAsPaid(ctx context.Context, bagID, userAddress, storageContract string) (err error)
 	GetUnpaidBags(ctx context.Context, userAddr string) (info v1.UnpaidBagsResponse, err error)
 	GetBagsInfoShort(ctx context.Context, bagIDs []string) (descriptions []v1.BagInfoShort, err error)
+	GetBagStateHistory(ctx context.Context, bagID string) (resp v1.BagStateHistoryResponse, err error)
+	GetBagReplication(ctx context.Context, bagID, userAddr string) (resp v1.BagReplicationResponse, err error)
+	GetBagRetention(ctx context.Context, bagID string) (resp v1.BagRetentionResponse, err error)
+	PinBag(ctx context.Context, bagID string, req v1.PinBagRequest) error
+	UnpinBag(ctx context.Context, bagID string) error
+
+	ProposeBagTransfer(ctx context.Context, userAddr string, req v1.ProposeBagTransferRequest) error
+	GetBagTransfers(ctx context.Context, userAddr string) (resp v1.BagTransfersResponse, err error)
+	CancelBagTransfer(ctx context.Context, bagID, userAddr string) error
+	AcceptBagTransfer(ctx context.Context, userAddr string, req v1.AcceptBagTransferRequest) error
 }
 
 type contracts interface {
@@ -24,10 +34,23 @@ type contracts interface {
 }
 
 type providers interface {
-	FetchProvidersRates(ctx context.Context, req v1.OffersRequest) (resp v1.ProviderRatesResponse, err error)
+	FetchProvidersRates(ctx context.Context, userAddress string, req v1.OffersRequest) (resp v1.ProviderRatesResponse, err error)
 	FetchProvidersRatesBySize(ctx context.Context, providers []string, bagSize uint64, span uint32) (resp v1.ProviderRatesResponse)
+	RecommendProviders(ctx context.Context, req v1.RecommendRequest) (resp v1.RecommendResponse, err error)
+	Quote(ctx context.Context, req v1.QuoteRequest) (resp v1.QuoteResponse, err error)
+	ProvidersFromQuote(ctx context.Context, userAddress string, quoteID string, bagID string, span uint32, providers []string) (resp []v1.ProviderShort, valid bool, err error)
+	ProvidersFromContractQuote(ctx context.Context, userAddress string, quoteID string, contractAddr string, span uint32, providers []string) (resp []v1.ProviderShort, valid bool, err error)
 	InitStorageContract(ctx context.Context, info v1.InitStorageContractRequest, providers []v1.ProviderShort) (resp v1.Transaction, err error)
 	EditStorageContract(ctx context.Context, address string, amount uint64, providers []v1.ProviderShort) (resp v1.Transaction, err error)
+	GetProviders(ctx context.Context, filter v1.ProvidersFilter) (resp v1.ProvidersListResponse, err error)
+	ImportProviders(ctx context.Context, req v1.ImportProvidersRequest) (resp v1.ImportProvidersResponse, err error)
+	GetRatesHistory(ctx context.Context, pubkey string, filter v1.RatesHistoryFilter) (resp v1.RatesHistoryResponse, err error)
+	GetProofIncidents(ctx context.Context, userAddr string) (incidents []v1.ProofIncident, err error)
+
+	GetProvidersPolicy(ctx context.Context) (resp v1.ProvidersPolicy, err error)
+	SetProviderPolicy(ctx context.Context, req v1.ProviderPolicy) (err error)
+	DeleteProviderPolicy(ctx context.Context, pubkey string) (err error)
+	SetPolicyMode(ctx context.Context, allowlistOnly bool) (err error)
 }
 
 type auth interface {
@@ -36,6 +59,32 @@ type auth interface {
 	Authenticate(ctx context.Context, signature, sessionData string) (addr string, err error)
 }
 
+type workspaces interface {
+	CreateWorkspace(ctx context.Context, userAddr string, req v1.CreateWorkspaceRequest) (info v1.WorkspaceInfo, err error)
+	GetUserWorkspaces(ctx context.Context, userAddr string) (list []v1.WorkspaceInfo, err error)
+	GetMembers(ctx context.Context, workspaceID int64, userAddr string) (members []v1.WorkspaceMember, err error)
+	InviteMember(ctx context.Context, workspaceID int64, userAddr string, req v1.InviteMemberRequest) error
+	RemoveMember(ctx context.Context, workspaceID int64, userAddr, memberAddr string) error
+
+	CheckAccess(ctx context.Context, workspaceID int64, userAddr string, role string) error
+	CheckContractAccess(ctx context.Context, workspaceID int64, userAddr, contractAddr string) error
+	AttachBag(ctx context.Context, workspaceID int64, bagID, userAddr string) error
+	GetBags(ctx context.Context, workspaceID int64, userAddr string) (bags []v1.WorkspaceBag, err error)
+	DeleteBag(ctx context.Context, workspaceID int64, bagID, userAddr string) error
+	MarkBagAsPaid(ctx context.Context, workspaceID int64, userAddr string, req v1.PaidBagRequest) error
+}
+
+type workers interface {
+	GetWorkers(ctx context.Context) (workers []v1.WorkerStatus, err error)
+	PauseWorker(ctx context.Context, name string) (err error)
+	ResumeWorker(ctx context.Context, name string) (err error)
+	TriggerWorker(ctx context.Context, name string) (err error)
+}
+
+type health interface {
+	Readiness(ctx context.Context) (resp v1.ReadinessResponse)
+}
+
 type errorResponse struct {
 	Error string `json:"error"`
 }
@@ -46,6 +95,9 @@ type handler struct {
 	files           files
 	providers       providers
 	contracts       contracts
+	workspaces      workspaces
+	workers         workers
+	checks          health
 	auth            auth
 	namespace       string
 	subsystem       string
@@ -57,6 +109,9 @@ func New(
 	files files,
 	providers providers,
 	contracts contracts,
+	workspaces workspaces,
+	workers workers,
+	health health,
 	auth auth,
 	adminAuthTokens []string,
 	namespace string,
@@ -73,6 +128,9 @@ func New(
 		files:           files,
 		providers:       providers,
 		contracts:       contracts,
+		workspaces:      workspaces,
+		workers:         workers,
+		checks:          health,
 		auth:            auth,
 		namespace:       namespace,
 		subsystem:       subsystem,
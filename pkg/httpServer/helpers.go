@@ -2,11 +2,13 @@ package httpServer
 
 import (
 	"log/slog"
+	"slices"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
 
 	"mytonstorage-backend/pkg/models"
+	v1 "mytonstorage-backend/pkg/models/api/v1"
 )
 
 func (h *handler) limitReached(c *fiber.Ctx) error {
@@ -37,6 +39,58 @@ func validateBagID(bagid string) bool {
 	return true
 }
 
+// workspaceIDParam returns workspace id for routes nested under /workspaces/:workspace_id
+func workspaceIDParam(c *fiber.Ctx) (id int64, ok bool, err error) {
+	if c.Params("workspace_id") == "" {
+		return
+	}
+
+	v, err := c.ParamsInt("workspace_id")
+	if err != nil || v <= 0 {
+		err = fiber.NewError(fiber.StatusBadRequest, "invalid workspace id")
+		return
+	}
+
+	return int64(v), true, nil
+}
+
+// providersUnavailableError lists decline reasons, so users know why a provider can't be used
+func providersUnavailableError(declines []v1.ProviderDecline) error {
+	if len(declines) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "some providers unavailable")
+	}
+
+	reasons := make([]string, 0, len(declines))
+	for _, d := range declines {
+		reasons = append(reasons, d.ProviderKey+": "+d.Reason)
+	}
+
+	return fiber.NewError(fiber.StatusBadRequest, "some providers unavailable: "+strings.Join(reasons, "; "))
+}
+
+// offersToProviders converts offers to contract terms, every offer must belong to a requested provider
+func offersToProviders(log *slog.Logger, keys []string, offers []v1.ProviderOffer) ([]v1.ProviderShort, error) {
+	providers := make([]v1.ProviderShort, 0, len(offers))
+	for _, offer := range offers {
+		index := slices.IndexFunc(keys, func(key string) bool {
+			return strings.EqualFold(key, offer.Provider.Key)
+		})
+
+		if index == -1 {
+			log.Error("some providers unavailable", slog.String("provider_key", offer.Provider.Key))
+			return nil, fiber.NewError(fiber.StatusBadRequest, "some providers unavailable, please, try again")
+		}
+
+		providers = append(providers, v1.ProviderShort{
+			Pubkey:        offer.Provider.Key,
+			MaxSpan:       offer.OfferSpan,
+			PricePerMBDay: offer.PricePerMB,
+		})
+	}
+
+	return providers, nil
+}
+
 func okHandler(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"status": "ok",
@@ -5,7 +5,6 @@ import (
 	"log/slog"
 	"mime"
 	"mime/multipart"
-	"slices"
 	"strings"
 
 	"github.com/gofiber/adaptor/v2"
@@ -85,12 +84,29 @@ func (h *handler) uploadFiles(c *fiber.Ctx) (err error) {
 		return fiber.NewError(fiber.StatusBadRequest, "no boundary in content type")
 	}
 
+	workspaceID, inWorkspace, err := workspaceIDParam(c)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	if inWorkspace {
+		if err := h.workspaces.CheckAccess(c.Context(), workspaceID, address, v1.WorkspaceRoleUploader); err != nil {
+			return errorHandler(c, err)
+		}
+	}
+
 	mr := multipart.NewReader(bytes.NewReader(body), boundary)
 	bagid, err := h.files.AddFiles(c.Context(), mr, uint64(totalSize), address)
 	if err != nil {
 		return errorHandler(c, err)
 	}
 
+	if inWorkspace {
+		if err := h.workspaces.AttachBag(c.Context(), workspaceID, bagid, address); err != nil {
+			return errorHandler(c, err)
+		}
+	}
+
 	return c.JSON(fiber.Map{
 		"bag_id": bagid,
 	})
@@ -122,6 +138,32 @@ func (h *handler) deleteBag(c *fiber.Ctx) error {
 	return okHandler(c)
 }
 
+func (h *handler) getBagReplication(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	bagID := strings.ToLower(c.Params("bag_id"))
+	if !validateBagID(bagID) {
+		log.Error("bag_id is required")
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	resp, err := h.files.GetBagReplication(c.Context(), bagID, address)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
 func (h *handler) getUnpaid(c *fiber.Ctx) error {
 	log := h.logger.With(
 		slog.String("method", c.Method()),
@@ -189,19 +231,51 @@ func (h *handler) GetBagsInfoShort(c *fiber.Ctx) error {
 	return c.JSON(bagsInfo)
 }
 
-func (h *handler) fetchProvidersOffers(c *fiber.Ctx) error {
+func (h *handler) proposeBagTransfer(c *fiber.Ctx) error {
 	log := h.logger.With(
 		slog.String("method", c.Method()),
 		slog.String("url", c.OriginalURL()),
 	)
 
-	var req v1.OffersRequest
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	var req v1.ProposeBagTransferRequest
 	if err := c.BodyParser(&req); err != nil {
 		log.Error("failed to parse request", slog.Any("error", err))
 		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
 	}
 
-	resp, err := h.providers.FetchProvidersRates(c.Context(), req)
+	req.BagID = strings.ToLower(req.BagID)
+	if !validateBagID(req.BagID) {
+		log.Error("invalid bag_id", slog.String("bag_id", req.BagID))
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	err := h.files.ProposeBagTransfer(c.Context(), address, req)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return okHandler(c)
+}
+
+func (h *handler) getBagTransfers(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	resp, err := h.files.GetBagTransfers(c.Context(), address)
 	if err != nil {
 		return errorHandler(c, err)
 	}
@@ -209,7 +283,7 @@ func (h *handler) fetchProvidersOffers(c *fiber.Ctx) error {
 	return c.JSON(resp)
 }
 
-func (h *handler) topupBalance(c *fiber.Ctx) error {
+func (h *handler) cancelBagTransfer(c *fiber.Ctx) error {
 	log := h.logger.With(
 		slog.String("method", c.Method()),
 		slog.String("url", c.OriginalURL()),
@@ -221,39 +295,91 @@ func (h *handler) topupBalance(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, "")
 	}
 
-	var req v1.TopupRequest
+	bagID := strings.ToLower(c.Params("bag_id"))
+	if !validateBagID(bagID) {
+		log.Error("bag_id is required")
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	err := h.files.CancelBagTransfer(c.Context(), bagID, address)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return okHandler(c)
+}
+
+func (h *handler) acceptBagTransfer(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	var req v1.AcceptBagTransferRequest
 	if err := c.BodyParser(&req); err != nil {
 		log.Error("failed to parse request", slog.Any("error", err))
 		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
 	}
 
-	resp, err := h.contracts.TopupBalance(c.Context(), address, req)
+	req.BagID = strings.ToLower(req.BagID)
+	if !validateBagID(req.BagID) {
+		log.Error("invalid bag_id", slog.String("bag_id", req.BagID))
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	err := h.files.AcceptBagTransfer(c.Context(), address, req)
 	if err != nil {
 		return errorHandler(c, err)
 	}
 
-	return c.JSON(resp)
+	return okHandler(c)
 }
 
-func (h *handler) withdrawBalance(c *fiber.Ctx) error {
+func (h *handler) fetchProvidersOffers(c *fiber.Ctx) error {
 	log := h.logger.With(
 		slog.String("method", c.Method()),
 		slog.String("url", c.OriginalURL()),
 	)
 
+	var req v1.OffersRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Error("failed to parse request", slog.Any("error", err))
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
 	address, ok := c.Context().UserValue("address").(string)
 	if !ok || address == "" {
 		log.Error("no user address after successful auth")
 		return fiber.NewError(fiber.StatusInternalServerError, "")
 	}
 
-	var req v1.WithdrawRequest
+	resp, err := h.providers.FetchProvidersRates(c.Context(), address, req)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *handler) quote(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	var req v1.QuoteRequest
 	if err := c.BodyParser(&req); err != nil {
 		log.Error("failed to parse request", slog.Any("error", err))
 		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
 	}
 
-	resp, err := h.contracts.WithdrawBalance(c.Context(), address, req)
+	resp, err := h.providers.Quote(c.Context(), req)
 	if err != nil {
 		return errorHandler(c, err)
 	}
@@ -261,43 +387,39 @@ func (h *handler) withdrawBalance(c *fiber.Ctx) error {
 	return c.JSON(resp)
 }
 
-func (h *handler) updateProviders(c *fiber.Ctx) error {
+func (h *handler) recommendProviders(c *fiber.Ctx) error {
 	log := h.logger.With(
 		slog.String("method", c.Method()),
 		slog.String("url", c.OriginalURL()),
 	)
 
-	var req v1.UpdateProvidersRequest
+	var req v1.RecommendRequest
 	if err := c.BodyParser(&req); err != nil {
 		log.Error("failed to parse request", slog.Any("error", err))
 		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
 	}
 
-	rates := h.providers.FetchProvidersRatesBySize(c.Context(), req.Providers, req.BagSize, req.Span)
-	if len(rates.Offers) != len(req.Providers) {
-		log.Error("not all providers returned offers", slog.Int("expected", len(req.Providers)), slog.Int("received", len(rates.Offers)))
-		return fiber.NewError(fiber.StatusBadRequest, "some providers unavailable")
+	resp, err := h.providers.RecommendProviders(c.Context(), req)
+	if err != nil {
+		return errorHandler(c, err)
 	}
 
-	providersOffers := make([]v1.ProviderShort, 0, len(rates.Offers))
-	for _, offer := range rates.Offers {
-		index := slices.IndexFunc(req.Providers, func(key string) bool {
-			return strings.EqualFold(key, offer.Provider.Key)
-		})
+	return c.JSON(resp)
+}
 
-		if index == -1 {
-			log.Error("some providers unavailable", slog.String("provider_key", offer.Provider.Key))
-			return fiber.NewError(fiber.StatusBadRequest, "some providers unavailable, please, try again")
-		}
+func (h *handler) getProviders(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
 
-		providersOffers = append(providersOffers, v1.ProviderShort{
-			Pubkey:        offer.Provider.Key,
-			MaxSpan:       offer.OfferSpan,
-			PricePerMBDay: offer.PricePerMB,
-		})
+	var filter v1.ProvidersFilter
+	if err := c.QueryParser(&filter); err != nil {
+		log.Error("failed to parse query", slog.Any("error", err))
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
 	}
 
-	resp, err := h.providers.EditStorageContract(c.Context(), req.ContractAddress, req.Amount, providersOffers)
+	resp, err := h.providers.GetProviders(c.Context(), filter)
 	if err != nil {
 		return errorHandler(c, err)
 	}
@@ -305,51 +427,114 @@ func (h *handler) updateProviders(c *fiber.Ctx) error {
 	return c.JSON(resp)
 }
 
-func (h *handler) initStorageContract(c *fiber.Ctx) error {
+func (h *handler) getProviderRatesHistory(c *fiber.Ctx) error {
 	log := h.logger.With(
 		slog.String("method", c.Method()),
 		slog.String("url", c.OriginalURL()),
 	)
 
-	var info v1.InitStorageContractRequest
-	if err := c.BodyParser(&info); err != nil {
+	var filter v1.RatesHistoryFilter
+	if err := c.QueryParser(&filter); err != nil {
+		log.Error("failed to parse query", slog.Any("error", err))
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	resp, err := h.providers.GetRatesHistory(c.Context(), c.Params("pubkey"), filter)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *handler) importProviders(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	var req v1.ImportProvidersRequest
+	if err := c.BodyParser(&req); err != nil {
 		log.Error("failed to parse request", slog.Any("error", err))
 		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
 	}
 
-	rates, err := h.providers.FetchProvidersRates(c.Context(), v1.OffersRequest{
-		BagID:     info.BagID,
-		Providers: info.ProvidersKeys,
-		Span:      info.Span,
-	})
+	resp, err := h.providers.ImportProviders(c.Context(), req)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *handler) getProvidersPolicy(c *fiber.Ctx) error {
+	resp, err := h.providers.GetProvidersPolicy(c.Context())
 	if err != nil {
-		log.Error("failed to fetch providers rates", slog.Any("error", err))
-		return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch providers rates")
+		return errorHandler(c, err)
 	}
-	if len(rates.Offers) != len(info.ProvidersKeys) {
-		log.Error("not all providers returned offers", slog.Int("expected", len(info.ProvidersKeys)), slog.Int("received", len(rates.Offers)))
-		return fiber.NewError(fiber.StatusBadRequest, "some providers unavailable")
+
+	return c.JSON(resp)
+}
+
+func (h *handler) setProviderPolicy(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	var req v1.ProviderPolicy
+	if err := c.BodyParser(&req); err != nil {
+		log.Error("failed to parse request", slog.Any("error", err))
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
 	}
 
-	providersOffers := make([]v1.ProviderShort, 0, len(rates.Offers))
-	for _, offer := range rates.Offers {
-		index := slices.IndexFunc(info.ProvidersKeys, func(key string) bool {
-			return strings.EqualFold(key, offer.Provider.Key)
-		})
+	if err := h.providers.SetProviderPolicy(c.Context(), req); err != nil {
+		return errorHandler(c, err)
+	}
 
-		if index == -1 {
-			log.Error("some providers unavailable", slog.String("provider_key", offer.Provider.Key))
-			return fiber.NewError(fiber.StatusBadRequest, "some providers unavailable, please, try again")
-		}
+	return okHandler(c)
+}
 
-		providersOffers = append(providersOffers, v1.ProviderShort{
-			Pubkey:        offer.Provider.Key,
-			MaxSpan:       offer.OfferSpan,
-			PricePerMBDay: offer.PricePerMB,
-		})
+func (h *handler) deleteProviderPolicy(c *fiber.Ctx) error {
+	if err := h.providers.DeleteProviderPolicy(c.Context(), c.Params("pubkey")); err != nil {
+		return errorHandler(c, err)
 	}
 
-	resp, err := h.providers.InitStorageContract(c.Context(), info, providersOffers)
+	return okHandler(c)
+}
+
+func (h *handler) setPolicyMode(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	var req v1.PolicyModeRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Error("failed to parse request", slog.Any("error", err))
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	if err := h.providers.SetPolicyMode(c.Context(), req.AllowlistOnly); err != nil {
+		return errorHandler(c, err)
+	}
+
+	return okHandler(c)
+}
+
+func (h *handler) getBagStateHistory(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	bagID := strings.ToLower(c.Params("bag_id"))
+	if !validateBagID(bagID) {
+		log.Error("bag_id is required")
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	resp, err := h.files.GetBagStateHistory(c.Context(), bagID)
 	if err != nil {
 		return errorHandler(c, err)
 	}
@@ -357,10 +542,536 @@ func (h *handler) initStorageContract(c *fiber.Ctx) error {
 	return c.JSON(resp)
 }
 
-func (h *handler) health(c *fiber.Ctx) error {
+func (h *handler) getBagRetention(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	bagID := strings.ToLower(c.Params("bag_id"))
+	if !validateBagID(bagID) {
+		log.Error("bag_id is required")
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	resp, err := h.files.GetBagRetention(c.Context(), bagID)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *handler) pinBag(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	bagID := strings.ToLower(c.Params("bag_id"))
+	if !validateBagID(bagID) {
+		log.Error("bag_id is required")
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	var req v1.PinBagRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			log.Error("failed to parse request", slog.Any("error", err))
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+		}
+	}
+
+	if err := h.files.PinBag(c.Context(), bagID, req); err != nil {
+		return errorHandler(c, err)
+	}
+
 	return okHandler(c)
 }
 
+func (h *handler) unpinBag(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	bagID := strings.ToLower(c.Params("bag_id"))
+	if !validateBagID(bagID) {
+		log.Error("bag_id is required")
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	if err := h.files.UnpinBag(c.Context(), bagID); err != nil {
+		return errorHandler(c, err)
+	}
+
+	return okHandler(c)
+}
+
+func (h *handler) getWorkers(c *fiber.Ctx) error {
+	list, err := h.workers.GetWorkers(c.Context())
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(v1.WorkersResponse{Workers: list})
+}
+
+func (h *handler) pauseWorker(c *fiber.Ctx) error {
+	if err := h.workers.PauseWorker(c.Context(), c.Params("name")); err != nil {
+		return errorHandler(c, err)
+	}
+
+	return okHandler(c)
+}
+
+func (h *handler) resumeWorker(c *fiber.Ctx) error {
+	if err := h.workers.ResumeWorker(c.Context(), c.Params("name")); err != nil {
+		return errorHandler(c, err)
+	}
+
+	return okHandler(c)
+}
+
+func (h *handler) triggerWorker(c *fiber.Ctx) error {
+	if err := h.workers.TriggerWorker(c.Context(), c.Params("name")); err != nil {
+		return errorHandler(c, err)
+	}
+
+	return okHandler(c)
+}
+
+func (h *handler) topupBalance(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	var req v1.TopupRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Error("failed to parse request", slog.Any("error", err))
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	if err := h.checkWorkspaceContract(c, address, req.ContractAddress); err != nil {
+		return errorHandler(c, err)
+	}
+
+	resp, err := h.contracts.TopupBalance(c.Context(), address, req)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *handler) withdrawBalance(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	var req v1.WithdrawRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Error("failed to parse request", slog.Any("error", err))
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	resp, err := h.contracts.WithdrawBalance(c.Context(), address, req)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *handler) updateProviders(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	var req v1.UpdateProvidersRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Error("failed to parse request", slog.Any("error", err))
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	address, _ := c.Context().UserValue("address").(string)
+	if err := h.checkWorkspaceContract(c, address, req.ContractAddress); err != nil {
+		return errorHandler(c, err)
+	}
+
+	// Terms fixed by a quote are used as is, fresh rates are requested only after it expires
+	var providersOffers []v1.ProviderShort
+	if req.QuoteID != "" {
+		quoted, valid, err := h.providers.ProvidersFromContractQuote(c.Context(), address, req.QuoteID, req.ContractAddress, req.Span, req.Providers)
+		if err != nil {
+			return errorHandler(c, err)
+		}
+
+		if valid {
+			providersOffers = quoted
+		} else {
+			log.Info("quote expired, fetching fresh rates")
+		}
+	}
+
+	if providersOffers == nil {
+		rates := h.providers.FetchProvidersRatesBySize(c.Context(), req.Providers, req.BagSize, req.Span)
+		if len(rates.Offers) != len(req.Providers) {
+			log.Error("not all providers returned offers", slog.Int("expected", len(req.Providers)), slog.Int("received", len(rates.Offers)))
+			return providersUnavailableError(rates.Declines)
+		}
+
+		var err error
+		providersOffers, err = offersToProviders(log, req.Providers, rates.Offers)
+		if err != nil {
+			return err
+		}
+	}
+
+	resp, err := h.providers.EditStorageContract(c.Context(), req.ContractAddress, req.Amount, providersOffers)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *handler) getProofIncidents(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	resp, err := h.providers.GetProofIncidents(c.Context(), address)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *handler) initStorageContract(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	var info v1.InitStorageContractRequest
+	if err := c.BodyParser(&info); err != nil {
+		log.Error("failed to parse request", slog.Any("error", err))
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	// Terms fixed by a quote are used as is, fresh rates are requested only after it expires
+	var providersOffers []v1.ProviderShort
+	if info.QuoteID != "" {
+		quoted, valid, err := h.providers.ProvidersFromQuote(c.Context(), address, info.QuoteID, info.BagID, info.Span, info.ProvidersKeys)
+		if err != nil {
+			return errorHandler(c, err)
+		}
+
+		if valid {
+			providersOffers = quoted
+		} else {
+			log.Info("quote expired, fetching fresh rates")
+		}
+	}
+
+	if providersOffers == nil {
+		rates, err := h.providers.FetchProvidersRates(c.Context(), address, v1.OffersRequest{
+			BagID:     info.BagID,
+			Providers: info.ProvidersKeys,
+			Span:      info.Span,
+		})
+		if err != nil {
+			log.Error("failed to fetch providers rates", slog.Any("error", err))
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch providers rates")
+		}
+		if len(rates.Offers) != len(info.ProvidersKeys) {
+			log.Error("not all providers returned offers", slog.Int("expected", len(info.ProvidersKeys)), slog.Int("received", len(rates.Offers)))
+			return providersUnavailableError(rates.Declines)
+		}
+
+		providersOffers, err = offersToProviders(log, info.ProvidersKeys, rates.Offers)
+		if err != nil {
+			return err
+		}
+	}
+
+	resp, err := h.providers.InitStorageContract(c.Context(), info, providersOffers)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+// checkWorkspaceContract verifies that a contract belongs to the workspace from the route
+// and the user is allowed to manage it. Does nothing for routes outside of workspaces.
+func (h *handler) checkWorkspaceContract(c *fiber.Ctx, userAddr, contractAddr string) error {
+	workspaceID, inWorkspace, err := workspaceIDParam(c)
+	if err != nil || !inWorkspace {
+		return err
+	}
+
+	return h.workspaces.CheckContractAccess(c.Context(), workspaceID, userAddr, contractAddr)
+}
+
+func (h *handler) createWorkspace(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	var req v1.CreateWorkspaceRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Error("failed to parse request", slog.Any("error", err))
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	resp, err := h.workspaces.CreateWorkspace(c.Context(), address, req)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *handler) getWorkspaces(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	resp, err := h.workspaces.GetUserWorkspaces(c.Context(), address)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *handler) getWorkspaceMembers(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	workspaceID, _, err := workspaceIDParam(c)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	resp, err := h.workspaces.GetMembers(c.Context(), workspaceID, address)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *handler) inviteWorkspaceMember(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	workspaceID, _, err := workspaceIDParam(c)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	var req v1.InviteMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Error("failed to parse request", slog.Any("error", err))
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	err = h.workspaces.InviteMember(c.Context(), workspaceID, address, req)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return okHandler(c)
+}
+
+func (h *handler) removeWorkspaceMember(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	workspaceID, _, err := workspaceIDParam(c)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	err = h.workspaces.RemoveMember(c.Context(), workspaceID, address, c.Params("address"))
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return okHandler(c)
+}
+
+func (h *handler) getWorkspaceBags(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	workspaceID, _, err := workspaceIDParam(c)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	resp, err := h.workspaces.GetBags(c.Context(), workspaceID, address)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return c.JSON(resp)
+}
+
+func (h *handler) deleteWorkspaceBag(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	workspaceID, _, err := workspaceIDParam(c)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	bagID := strings.ToLower(c.Params("bag_id"))
+	if !validateBagID(bagID) {
+		log.Error("bag_id is required")
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	err = h.workspaces.DeleteBag(c.Context(), workspaceID, bagID, address)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return okHandler(c)
+}
+
+func (h *handler) markWorkspaceBagAsPaid(c *fiber.Ctx) error {
+	log := h.logger.With(
+		slog.String("method", c.Method()),
+		slog.String("url", c.OriginalURL()),
+	)
+
+	address, ok := c.Context().UserValue("address").(string)
+	if !ok || address == "" {
+		log.Error("no user address after successful auth")
+		return fiber.NewError(fiber.StatusInternalServerError, "")
+	}
+
+	workspaceID, _, err := workspaceIDParam(c)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	var req v1.PaidBagRequest
+	if err := c.BodyParser(&req); err != nil {
+		log.Error("failed to parse request", slog.Any("error", err))
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request")
+	}
+
+	req.BagID = strings.ToLower(req.BagID)
+	err = h.workspaces.MarkBagAsPaid(c.Context(), workspaceID, address, req)
+	if err != nil {
+		return errorHandler(c, err)
+	}
+
+	return okHandler(c)
+}
+
+func (h *handler) health(c *fiber.Ctx) error {
+	return okHandler(c)
+}
+
+func (h *handler) readiness(c *fiber.Ctx) error {
+	resp := h.checks.Readiness(c.Context())
+	if !resp.Ready {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(resp)
+	}
+
+	return c.JSON(resp)
+}
+
 func (h *handler) metrics(c *fiber.Ctx) error {
 	m := promhttp.Handler()
 
@@ -52,6 +52,7 @@ func (h *handler) RegisterRoutes() {
 	}))
 
 	h.server.Get("/health", h.health)
+	h.server.Get("/health/ready", h.readiness)
 	h.server.Get("/metrics", h.adminAuthMiddleware, h.metrics)
 
 	apiv1 := h.server.Group("/api/v1", h.loggerMiddleware)
@@ -69,6 +70,11 @@ func (h *handler) RegisterRoutes() {
 			files.Post("/details", h.GetBagsInfoShort)
 			files.Post("/unpaid", h.getUnpaid)
 			files.Delete("/:bag_id", h.deleteBag)
+			files.Get("/:bag_id/replication", h.getBagReplication)
+			files.Get("/transfers", h.getBagTransfers)
+			files.Post("/transfers", h.proposeBagTransfer)
+			files.Post("/transfers/accept", h.acceptBagTransfer)
+			files.Delete("/transfers/:bag_id", h.cancelBagTransfer)
 		}
 
 		{
@@ -77,11 +83,52 @@ func (h *handler) RegisterRoutes() {
 			contracts.Post("/topup", h.topupBalance)
 			contracts.Post("/withdraw", h.withdrawBalance)
 			contracts.Post("/update", h.updateProviders)
+			contracts.Get("/incidents", h.getProofIncidents)
+		}
+
+		{
+			quote := apiv1.Group("/quote", h.userAuthMiddleware)
+			quote.Post("/", h.quote)
 		}
 
 		{
 			providers := apiv1.Group("/providers", h.userAuthMiddleware)
+			providers.Get("/", h.getProviders)
 			providers.Post("/offers", h.fetchProvidersOffers)
+			providers.Post("/recommend", h.recommendProviders)
+			providers.Get("/:pubkey/rates", h.getProviderRatesHistory)
+		}
+
+		{
+			admin := apiv1.Group("/admin", h.adminAuthMiddleware)
+			admin.Post("/providers/import", h.importProviders)
+			admin.Get("/providers/policy", h.getProvidersPolicy)
+			admin.Post("/providers/policy", h.setProviderPolicy)
+			admin.Put("/providers/policy/mode", h.setPolicyMode)
+			admin.Delete("/providers/policy/:pubkey", h.deleteProviderPolicy)
+			admin.Get("/bags/:bag_id/history", h.getBagStateHistory)
+			admin.Get("/bags/:bag_id/retention", h.getBagRetention)
+			admin.Put("/bags/:bag_id/pin", h.pinBag)
+			admin.Delete("/bags/:bag_id/pin", h.unpinBag)
+			admin.Get("/workers", h.getWorkers)
+			admin.Post("/workers/:name/pause", h.pauseWorker)
+			admin.Post("/workers/:name/resume", h.resumeWorker)
+			admin.Post("/workers/:name/trigger", h.triggerWorker)
+		}
+
+		{
+			workspaces := apiv1.Group("/workspaces", h.userAuthMiddleware)
+			workspaces.Post("/", h.createWorkspace)
+			workspaces.Get("/", h.getWorkspaces)
+			workspaces.Get("/:workspace_id/members", h.getWorkspaceMembers)
+			workspaces.Post("/:workspace_id/members", h.inviteWorkspaceMember)
+			workspaces.Delete("/:workspace_id/members/:address", h.removeWorkspaceMember)
+			workspaces.Get("/:workspace_id/bags", h.getWorkspaceBags)
+			workspaces.Post("/:workspace_id/files", h.uploadFiles)
+			workspaces.Post("/:workspace_id/files/paid", h.markWorkspaceBagAsPaid)
+			workspaces.Delete("/:workspace_id/files/:bag_id", h.deleteWorkspaceBag)
+			workspaces.Post("/:workspace_id/contracts/topup", h.topupBalance)
+			workspaces.Post("/:workspace_id/contracts/update", h.updateProviders)
 		}
 	}
 }
@@ -18,6 +18,108 @@ type BagInfoShort struct {
 	BagID           string `json:"bag_id"`
 	Description     string `json:"description"`
 	Size            uint64 `json:"size"`
+	State           string `json:"state"`
+}
+
+type BagStateChange struct {
+	UserAddress     string `json:"user_address"`
+	StorageContract string `json:"storage_contract,omitempty"`
+	FromState       string `json:"from_state"`
+	ToState         string `json:"to_state"`
+	Reason          string `json:"reason"`
+	CreatedAt       int64  `json:"created_at"`
+}
+
+type BagStateHistoryResponse struct {
+	BagID   string           `json:"bag_id"`
+	Changes []BagStateChange `json:"changes"`
+}
+
+type PinBagRequest struct {
+	Note string `json:"note"`
+}
+
+type RetentionDecision struct {
+	Decision  string `json:"decision"`
+	Reason    string `json:"reason"`
+	Replicas  int    `json:"replicas"`
+	Proofs    int    `json:"proofs"`
+	DecidedAt int64  `json:"decided_at"`
+}
+
+type BagRetentionResponse struct {
+	BagID    string             `json:"bag_id"`
+	Pinned   bool               `json:"pinned"`
+	PinNote  string             `json:"pin_note,omitempty"`
+	PinnedAt int64              `json:"pinned_at,omitempty"`
+	Decision *RetentionDecision `json:"decision,omitempty"`
+}
+
+const (
+	ReplicationPending        = "pending"
+	ReplicationDownloading    = "downloading"
+	ReplicationDownloaded     = "downloaded"
+	ReplicationNotifyFailed   = "notify_failed"
+	ReplicationDownloadFailed = "download_failed"
+)
+
+type ProviderReplication struct {
+	Pubkey         string  `json:"pubkey"`
+	Notified       bool    `json:"notified"`
+	NotifyAttempts int     `json:"notify_attempts"`
+	DownloadChecks int     `json:"download_checks"`
+	Size           uint64  `json:"size"`
+	Downloaded     uint64  `json:"downloaded"`
+	Progress       float64 `json:"progress"` // percent
+	LastCheckAt    int64   `json:"last_check_at,omitempty"`
+	DownloadedAt   int64   `json:"downloaded_at,omitempty"`
+	Outcome        string  `json:"outcome"`
+	Archived       bool    `json:"archived"`
+}
+
+type ContractReplication struct {
+	StorageContract string                `json:"storage_contract"`
+	Providers       []ProviderReplication `json:"providers"`
+}
+
+type BagReplicationResponse struct {
+	BagID     string                `json:"bag_id"`
+	Contracts []ContractReplication `json:"contracts"`
+}
+
+const (
+	WorkerStatusIdle    = "idle"
+	WorkerStatusRunning = "running"
+	WorkerStatusPaused  = "paused"
+	WorkerStatusStandby = "standby"
+)
+
+type WorkerStatus struct {
+	Name          string `json:"name"`
+	Status        string `json:"status"`
+	Paused        bool   `json:"paused"`
+	Leader        bool   `json:"leader"`
+	LeaderSince   int64  `json:"leader_since,omitempty"`
+	LastRunAt     int64  `json:"last_run_at,omitempty"`
+	LastSuccessAt int64  `json:"last_success_at,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	LastErrorAt   int64  `json:"last_error_at,omitempty"`
+	NextRunAt     int64  `json:"next_run_at,omitempty"`
+}
+
+type WorkersResponse struct {
+	Workers []WorkerStatus `json:"workers"`
+}
+
+type ReadinessCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Ready  bool             `json:"ready"`
+	Checks []ReadinessCheck `json:"checks"`
 }
 
 type UserBagInfo struct {
@@ -48,6 +150,67 @@ type OffersRequest struct {
 	Span      uint32   `json:"span"`
 }
 
+type RecommendRequest struct {
+	BagID    string `json:"bag_id"`
+	BagSize  uint64 `json:"bag_size"`
+	Span     uint32 `json:"span"`
+	Replicas int    `json:"replicas"`
+	// Budget is the max total price for the whole span in nanoTON, zero means no limit
+	Budget uint64 `json:"budget,omitempty"`
+}
+
+type RecommendResponse struct {
+	Providers   []ProviderOffer `json:"providers"`
+	PricePerDay uint64          `json:"price_per_day"`
+	TotalPrice  uint64          `json:"total_price"`
+	Span        uint32          `json:"span"`
+}
+
+type QuoteFile struct {
+	Path string `json:"path"`
+	Size uint64 `json:"size"`
+}
+
+// QuoteRequest takes either a files manifest or a total size. Without providers the best ones are recommended.
+type QuoteRequest struct {
+	Files     []QuoteFile `json:"files,omitempty"`
+	TotalSize uint64      `json:"total_size,omitempty"`
+	Days      uint32      `json:"days"`
+	Span      uint32      `json:"span,omitempty"`
+	Providers []string    `json:"providers,omitempty"`
+	Replicas  int         `json:"replicas,omitempty"`
+}
+
+type ProviderQuote struct {
+	Key         string `json:"key"`
+	Span        uint64 `json:"span"`
+	PricePerDay uint64 `json:"price_per_day"`
+	TotalPrice  uint64 `json:"total_price"`
+}
+
+type QuoteResponse struct {
+	BagSize     uint64            `json:"bag_size"`
+	PieceSize   uint32            `json:"piece_size"`
+	Days        uint32            `json:"days"`
+	Providers   []ProviderQuote   `json:"providers"`
+	Declines    []ProviderDecline `json:"declines,omitempty"`
+	PricePerDay uint64            `json:"price_per_day"`
+	TotalPrice  uint64            `json:"total_price"`
+	InitAmount  uint64            `json:"init_amount"`
+}
+
+type ProofIncident struct {
+	ID                int64        `json:"id"`
+	StorageContract   string       `json:"storage_contract"`
+	BagID             string       `json:"bag_id"`
+	ProviderPubkey    string       `json:"provider_pubkey"`
+	LastProofAt       int64        `json:"last_proof_at"`
+	MaxSpan           uint32       `json:"max_span"`
+	DetectedAt        int64        `json:"detected_at"`
+	ReplacementPubkey string       `json:"replacement_pubkey,omitempty"`
+	Transaction       *Transaction `json:"transaction,omitempty"`
+}
+
 type ProviderContractData struct {
 	Key          string `json:"key"`
 	MinBounty    string `json:"min_bounty"`
@@ -76,6 +239,7 @@ type InitStorageContractRequest struct {
 	OwnerAddress  string   `json:"owner_address"`
 	Amount        uint64   `json:"amount"`
 	Span          uint32   `json:"span"`
+	QuoteID       string   `json:"quote_id"`
 }
 
 type UpdateProvidersRequest struct {
@@ -84,6 +248,7 @@ type UpdateProvidersRequest struct {
 	BagSize         uint64   `json:"bag_size"`
 	Amount          uint64   `json:"amount"`
 	Span            uint32   `json:"span"`
+	QuoteID         string   `json:"quote_id"`
 }
 
 type ProviderOffer struct {
@@ -92,12 +257,16 @@ type ProviderOffer struct {
 	PricePerProof uint64 `json:"price_per_proof"`
 	PricePerMB    uint64 `json:"price_per_mb"`
 
-	Provider ProviderContractData `json:"provider"`
+	Provider   ProviderContractData `json:"provider"`
+	Reputation *ProviderReputation  `json:"reputation,omitempty"`
 }
 
 type ProviderRatesResponse struct {
 	Offers   []ProviderOffer   `json:"offers"`
 	Declines []ProviderDecline `json:"declines,omitempty"`
+	// Signed offers terms, pass it to contract init or update to get exactly these prices
+	QuoteID        string `json:"quote_id,omitempty"`
+	QuoteExpiresAt int64  `json:"quote_expires_at,omitempty"`
 }
 
 type ProviderDecline struct {
@@ -111,3 +280,167 @@ type Transaction struct {
 	Address   string `json:"address"`
 	Amount    uint64 `json:"amount"`
 }
+
+const (
+	WorkspaceRoleOwner    = "owner"
+	WorkspaceRoleUploader = "uploader"
+	WorkspaceRoleViewer   = "viewer"
+)
+
+type CreateWorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+type WorkspaceInfo struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	OwnerAddress string `json:"owner_address"`
+	Role         string `json:"role"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+type InviteMemberRequest struct {
+	Address string `json:"address"`
+	Role    string `json:"role"`
+}
+
+type WorkspaceMember struct {
+	Address    string `json:"address"`
+	Role       string `json:"role"`
+	InvitedBy  string `json:"invited_by"`
+	InvitedAt  int64  `json:"invited_at"`
+	AcceptedAt int64  `json:"accepted_at,omitempty"`
+}
+
+type WorkspaceBag struct {
+	BagID           string `json:"bag_id"`
+	UploadedBy      string `json:"uploaded_by"`
+	ContractAddress string `json:"contract_address,omitempty"`
+	Description     string `json:"description"`
+	Size            uint64 `json:"size"`
+	CreatedAt       int64  `json:"created_at"`
+}
+
+type ProposeBagTransferRequest struct {
+	BagID     string `json:"bag_id"`
+	ToAddress string `json:"to_address"`
+}
+
+type AcceptBagTransferRequest struct {
+	BagID       string `json:"bag_id"`
+	FromAddress string `json:"from_address"`
+}
+
+type BagTransfer struct {
+	BagID       string `json:"bag_id"`
+	FromAddress string `json:"from_address"`
+	ToAddress   string `json:"to_address"`
+	Description string `json:"description"`
+	Size        uint64 `json:"size"`
+	CreatedAt   int64  `json:"created_at"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+type BagTransfersResponse struct {
+	Incoming []BagTransfer `json:"incoming"`
+	Outgoing []BagTransfer `json:"outgoing"`
+}
+
+const (
+	ProviderStatusUnknown      = "unknown"
+	ProviderStatusAvailable    = "available"
+	ProviderStatusNotAvailable = "not_available"
+	ProviderStatusUnreachable  = "unreachable"
+)
+
+type ProvidersFilter struct {
+	Status          string `query:"status"`
+	MinSpaceMB      uint64 `query:"min_space_mb"`
+	MaxRatePerMBDay uint64 `query:"max_rate_per_mb_day"`
+	Span            uint32 `query:"span"`
+	SortBy          string `query:"sort_by"`
+	Order           string `query:"order"`
+	Limit           int    `query:"limit"`
+	Offset          int    `query:"offset"`
+}
+
+type RegistryProvider struct {
+	Pubkey           string `json:"pubkey"`
+	FirstSeenAt      int64  `json:"first_seen_at"`
+	LastSeenAt       int64  `json:"last_seen_at,omitempty"`
+	RatePerMBDay     uint64 `json:"rate_per_mb_day"`
+	MinBounty        uint64 `json:"min_bounty"`
+	MinSpan          uint32 `json:"min_span"`
+	MaxSpan          uint32 `json:"max_span"`
+	SpaceAvailableMB uint64 `json:"space_available_mb"`
+	Status           string `json:"status"`
+
+	Reputation *ProviderReputation `json:"reputation,omitempty"`
+}
+
+type RatesHistoryFilter struct {
+	From  int64 `query:"from"`
+	To    int64 `query:"to"`
+	Limit int   `query:"limit"`
+}
+
+type ProviderRatesPoint struct {
+	CheckedAt        int64  `json:"checked_at"`
+	Reachable        bool   `json:"reachable"`
+	Available        bool   `json:"available"`
+	RatePerMBDay     uint64 `json:"rate_per_mb_day"`
+	MinBounty        uint64 `json:"min_bounty"`
+	SpaceAvailableMB uint64 `json:"space_available_mb"`
+	LatencyMs        int64  `json:"latency_ms"`
+}
+
+type RatesHistoryResponse struct {
+	Pubkey string               `json:"pubkey"`
+	Points []ProviderRatesPoint `json:"points"`
+}
+
+// ProviderReputation score is in range [0, 1], components are omitted when there is not enough data
+type ProviderReputation struct {
+	Score              float64  `json:"score"`
+	NotifySuccessRate  *float64 `json:"notify_success_rate,omitempty"`
+	AvgDownloadSeconds *int64   `json:"avg_download_seconds,omitempty"`
+	AbandonedRate      *float64 `json:"abandoned_rate,omitempty"`
+	ProofLagSeconds    *int64   `json:"proof_lag_seconds,omitempty"`
+	Samples            int64    `json:"samples"`
+	UpdatedAt          int64    `json:"updated_at"`
+}
+
+type ProvidersListResponse struct {
+	Providers []RegistryProvider `json:"providers"`
+}
+
+type ImportProvidersRequest struct {
+	Providers []string `json:"providers"`
+}
+
+type ImportProvidersResponse struct {
+	Added int64 `json:"added"`
+}
+
+const (
+	ProviderPolicyBlocked = "blocked"
+	ProviderPolicyAllowed = "allowed"
+)
+
+type ProviderPolicy struct {
+	Pubkey    string `json:"pubkey"`
+	Status    string `json:"status"`
+	Note      string `json:"note"`
+	UpdatedAt int64  `json:"updated_at,omitempty"`
+}
+
+// ProvidersPolicy is the admin view of the providers policy.
+// In allowlist only mode providers without the allowed status can't be used in new contracts.
+type ProvidersPolicy struct {
+	AllowlistOnly bool             `json:"allowlist_only"`
+	Providers     []ProviderPolicy `json:"providers"`
+}
+
+type PolicyModeRequest struct {
+	AllowlistOnly bool `json:"allowlist_only"`
+}
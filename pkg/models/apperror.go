@@ -10,13 +10,17 @@ const (
 	InternalServerErrorCode = http.StatusInternalServerError
 	BadRequestErrorCode     = http.StatusBadRequest
 	UnauthorizedErrorCode   = http.StatusUnauthorized
+	ForbiddenErrorCode      = http.StatusForbidden
 	ServiceUnavailableCode  = http.StatusServiceUnavailable
+	ConflictErrorCode       = http.StatusConflict
 )
 
 var defaultMessages = map[int]string{
 	InternalServerErrorCode: "internal server error",
 	BadRequestErrorCode:     "bad request",
 	NotFoundErrorCode:       "not found",
+	ForbiddenErrorCode:      "forbidden",
+	ConflictErrorCode:       "conflict",
 }
 
 // AppError — custom error type to handle service layer errors
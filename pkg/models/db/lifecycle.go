@@ -0,0 +1,67 @@
+package db
+
+// Bag lifecycle states of a paid or unpaid bag relation (files.bag_users)
+const (
+	BagStateUploaded          = "uploaded"
+	BagStatePaid              = "paid"
+	BagStateProvidersResolved = "providers_resolved"
+	BagStateNotifying         = "notifying"
+	BagStateDownloading       = "downloading"
+	BagStateReplicated        = "replicated"
+	BagStateReleased          = "released"
+
+	BagStateExpired        = "expired"
+	BagStateResolveFailed  = "resolve_failed"
+	BagStateNotifyFailed   = "notify_failed"
+	BagStateDownloadFailed = "download_failed"
+)
+
+// bagTransitions lists allowed source states for every target state.
+// This is the only place transitions are defined, the repository applies a transition only from these states.
+var bagTransitions = map[string][]string{
+	BagStatePaid:              {BagStateUploaded},
+	BagStateExpired:           {BagStateUploaded},
+	BagStateProvidersResolved: {BagStatePaid},
+	BagStateResolveFailed:     {BagStatePaid},
+	BagStateNotifying:         {BagStateProvidersResolved},
+	BagStateDownloading:       {BagStateProvidersResolved, BagStateNotifying},
+	BagStateNotifyFailed:      {BagStateProvidersResolved, BagStateNotifying},
+	BagStateReplicated:        {BagStateDownloading},
+	BagStateDownloadFailed:    {BagStateDownloading},
+	BagStateReleased:          {BagStateReplicated},
+}
+
+type BagStateEdge struct {
+	From string `json:"from_state"`
+	To   string `json:"to_state"`
+}
+
+// BagStateEdges returns all allowed transitions
+func BagStateEdges() (edges []BagStateEdge) {
+	for to, from := range bagTransitions {
+		for _, f := range from {
+			edges = append(edges, BagStateEdge{From: f, To: to})
+		}
+	}
+
+	return
+}
+
+// BagTransition moves bag relations to the state. Empty user address or storage contract matches any.
+type BagTransition struct {
+	BagID           string `json:"bagid"`
+	UserAddress     string `json:"user_address"`
+	StorageContract string `json:"storage_contract"`
+	State           string `json:"state"`
+	Reason          string `json:"reason"`
+}
+
+type BagStateChange struct {
+	BagID           string `json:"bagid"`
+	UserAddress     string `json:"user_address"`
+	StorageContract string `json:"storage_contract"`
+	FromState       string `json:"from_state"`
+	ToState         string `json:"to_state"`
+	Reason          string `json:"reason"`
+	CreatedAt       int64  `json:"created_at"`
+}
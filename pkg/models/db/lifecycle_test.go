@@ -0,0 +1,35 @@
+package db
+
+import "testing"
+
+// Every state is reachable from an upload and no transition leaves a final state
+func TestBagLifecycle(t *testing.T) {
+	final := map[string]bool{
+		BagStateReleased:       true,
+		BagStateExpired:        true,
+		BagStateResolveFailed:  true,
+		BagStateNotifyFailed:   true,
+		BagStateDownloadFailed: true,
+	}
+
+	edges := BagStateEdges()
+	reached := map[string]bool{BagStateUploaded: true}
+	for changed := true; changed; {
+		changed = false
+		for _, e := range edges {
+			if final[e.From] {
+				t.Errorf("final state %s has transition to %s", e.From, e.To)
+			}
+			if reached[e.From] && !reached[e.To] {
+				reached[e.To] = true
+				changed = true
+			}
+		}
+	}
+
+	for to := range bagTransitions {
+		if !reached[to] {
+			t.Errorf("state %s is not reachable from %s", to, BagStateUploaded)
+		}
+	}
+}
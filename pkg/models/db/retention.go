@@ -0,0 +1,37 @@
+package db
+
+// Retention decisions for staged bag data whose provider notifications are finished
+const (
+	RetentionRelease = "release"
+	RetentionHold    = "hold"
+)
+
+// RetentionCandidate is a staged bag with all provider notifications finished, successfully or not
+type RetentionCandidate struct {
+	BagID      string
+	Providers  int
+	Notified   int
+	Replicas   int
+	Proofs     int
+	Pinned     bool
+	FinishedAt int64
+
+	// Previous decision, empty if the bag was not evaluated yet
+	PrevDecision string
+	PrevReason   string
+}
+
+type RetentionDecision struct {
+	BagID     string `json:"bagid"`
+	Decision  string `json:"decision"`
+	Reason    string `json:"reason"`
+	Replicas  int    `json:"replicas"`
+	Proofs    int    `json:"proofs"`
+	DecidedAt int64  `json:"-"`
+}
+
+type BagPin struct {
+	BagID     string
+	Note      string
+	CreatedAt int64
+}
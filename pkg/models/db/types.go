@@ -13,6 +13,7 @@ type BagDescription struct {
 	BagID           string `json:"bagid"`
 	Description     string `json:"description"`
 	Size            uint64 `json:"size"`
+	State           string `json:"state"`
 }
 
 type UserBagInfo struct {
@@ -25,6 +26,8 @@ type BagStorageContract struct {
 	BagID           string `json:"bagid"`
 	StorageContract string `json:"storage_contract"`
 	FilesSize       uint64 `json:"files_size"`
+	NotifyAttempts  int    `json:"notify_attempts"`
+	NextAttemptAt   int64  `json:"next_attempt_at"`
 }
 
 type ProviderNotification struct {
@@ -33,4 +36,165 @@ type ProviderNotification struct {
 	ProviderPubkey  string `json:"provider_pubkey"`
 	Size            uint64 `json:"size"`
 	Downloaded      uint64 `json:"downloaded"`
+	NotifyAttempts  int    `json:"notify_attempts"`
+	DownloadChecks  int    `json:"download_checks"`
+	NextAttemptAt   int64  `json:"next_attempt_at"`
+}
+
+// ProviderReplication is a provider notification of a bag contract, live or archived to notifications_history
+type ProviderReplication struct {
+	StorageContract string
+	ProviderPubkey  string
+	Notified        bool
+	NotifyAttempts  int
+	DownloadChecks  int
+	Size            uint64
+	Downloaded      uint64
+	LastCheckAt     int64
+	DownloadedAt    int64
+	Archived        bool
+}
+
+// Backlog is the number of items waiting in each step of the files pipeline
+type Backlog struct {
+	UnpaidBags           int64
+	ContractsToResolve   int64
+	NotificationsPending int64
+	DownloadsInProgress  int64
+	RemovalsPending      int64
+}
+
+type Workspace struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	OwnerAddress string `json:"owner_address"`
+	Role         string `json:"role"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+type WorkspaceMember struct {
+	WorkspaceID int64  `json:"workspace_id"`
+	UserAddress string `json:"user_address"`
+	Role        string `json:"role"`
+	InvitedBy   string `json:"invited_by"`
+	InvitedAt   int64  `json:"invited_at"`
+	AcceptedAt  int64  `json:"accepted_at"`
+}
+
+type WorkspaceBag struct {
+	BagID           string `json:"bagid"`
+	UserAddress     string `json:"user_address"`
+	StorageContract string `json:"storage_contract"`
+	Description     string `json:"description"`
+	Size            uint64 `json:"size"`
+	CreatedAt       int64  `json:"created_at"`
+}
+
+type BagTransfer struct {
+	BagID       string `json:"bagid"`
+	FromAddress string `json:"from_address"`
+	ToAddress   string `json:"to_address"`
+	Description string `json:"description"`
+	Size        uint64 `json:"size"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+type RegistryProvider struct {
+	Pubkey           string `json:"pubkey"`
+	FirstSeenAt      int64  `json:"first_seen_at"`
+	LastSeenAt       int64  `json:"last_seen_at"`
+	RatePerMBDay     uint64 `json:"rate_per_mb_day"`
+	MinBounty        uint64 `json:"min_bounty"`
+	MinSpan          uint32 `json:"min_span"`
+	MaxSpan          uint32 `json:"max_span"`
+	SpaceAvailableMB uint64 `json:"space_available_mb"`
+	Status           string `json:"status"`
+
+	Reputation *ProviderReputation `json:"-"`
+}
+
+type ProviderRatesPoint struct {
+	Pubkey           string `json:"pubkey"`
+	CheckedAt        int64  `json:"checked_at"`
+	Reachable        bool   `json:"reachable"`
+	Available        bool   `json:"available"`
+	RatePerMBDay     uint64 `json:"rate_per_mb_day"`
+	MinBounty        uint64 `json:"min_bounty"`
+	SpaceAvailableMB uint64 `json:"space_available_mb"`
+	LatencyMs        int64  `json:"latency_ms"`
+}
+
+type PaidContract struct {
+	StorageContract string
+	BagID           string
+	UserAddress     string
+	Size            uint64
+}
+
+// ProofAudit is a result of verifying a storage proof returned by a provider during download checks
+type ProofAudit struct {
+	ProviderPubkey  string `json:"provider_pubkey"`
+	StorageContract string `json:"storage_contract"`
+	BagID           string `json:"bagid"`
+	ByteToProof     uint64 `json:"byte_to_proof"`
+	Piece           uint32 `json:"piece"`
+	Valid           bool   `json:"valid"`
+	Error           string `json:"error"`
+}
+
+type ProofIncident struct {
+	ID                int64  `json:"id"`
+	StorageContract   string `json:"storage_contract"`
+	ProviderPubkey    string `json:"provider_pubkey"`
+	BagID             string `json:"bagid"`
+	UserAddress       string `json:"user_address"`
+	BagSize           uint64 `json:"bag_size"`
+	LastProofAt       int64  `json:"last_proof_at"`
+	MaxSpan           uint32 `json:"max_span"`
+	DetectedAt        int64  `json:"detected_at"`
+	ReplacementPubkey string `json:"replacement_pubkey"`
+	// ReplacementTx is a JSON encoded v1.Transaction
+	ReplacementTx []byte `json:"-"`
+}
+
+// ProviderStats is aggregated notification history of a provider used to compute reputation
+type ProviderStats struct {
+	Pubkey             string
+	Total              int64
+	Notified           int64
+	NotifyFailed       int64
+	Downloaded         int64
+	Abandoned          int64
+	AvgDownloadSeconds int64
+	ProofLagSeconds    *int64
+}
+
+type ProviderReputation struct {
+	Pubkey             string   `json:"pubkey"`
+	Score              float64  `json:"score"`
+	NotifySuccessRate  *float64 `json:"notify_success_rate"`
+	AvgDownloadSeconds *int64   `json:"avg_download_seconds"`
+	AbandonedRate      *float64 `json:"abandoned_rate"`
+	ProofLagSeconds    *int64   `json:"proof_lag_seconds"`
+	Samples            int64    `json:"samples"`
+	UpdatedAt          int64    `json:"updated_at"`
+}
+
+type ProviderPolicy struct {
+	Pubkey    string `json:"pubkey"`
+	Status    string `json:"status"`
+	Note      string `json:"note"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+type ProvidersFilter struct {
+	Status          string
+	MinSpaceMB      uint64
+	MaxRatePerMBDay uint64
+	Span            uint32
+	Pubkeys         []string
+	SortBy          string
+	Desc            bool
+	Limit           int
+	Offset          int
 }
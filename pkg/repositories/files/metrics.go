@@ -60,17 +60,6 @@ func (m *metricsMiddleware) RemoveUnusedBags(ctx context.Context) (removed []str
 	return m.repo.RemoveUnusedBags(ctx)
 }
 
-func (m *metricsMiddleware) RemoveNotifiedBags(ctx context.Context, limit int, sec uint64, maxNotifyAttempts int, maxDownloadChecks int) (removed []string, err error) {
-	defer func(s time.Time) {
-		labels := []string{
-			"RemoveNotifiedBags", strconv.FormatBool(err != nil),
-		}
-		m.reqCount.WithLabelValues(labels...).Add(1)
-		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
-	}(time.Now())
-	return m.repo.RemoveNotifiedBags(ctx, limit, sec, maxNotifyAttempts, maxDownloadChecks)
-}
-
 func (m *metricsMiddleware) CanUpload(ctx context.Context, userID string, sec uint64) (can bool, err error) {
 	defer func(s time.Time) {
 		labels := []string{
@@ -148,6 +137,171 @@ func (m *metricsMiddleware) IncreaseAttempts(ctx context.Context, bags []db.BagS
 	return m.repo.IncreaseAttempts(ctx, bags)
 }
 
+func (m *metricsMiddleware) ProposeBagTransfer(ctx context.Context, bagID, fromAddress, toAddress string) (cnt int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"ProposeBagTransfer", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.ProposeBagTransfer(ctx, bagID, fromAddress, toAddress)
+}
+
+func (m *metricsMiddleware) GetBagTransfers(ctx context.Context, userAddress string, sec uint64) (transfers []db.BagTransfer, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetBagTransfers", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetBagTransfers(ctx, userAddress, sec)
+}
+
+func (m *metricsMiddleware) CancelBagTransfer(ctx context.Context, bagID, fromAddress string) (cnt int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"CancelBagTransfer", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.CancelBagTransfer(ctx, bagID, fromAddress)
+}
+
+func (m *metricsMiddleware) AcceptBagTransfer(ctx context.Context, bagID, fromAddress, toAddress string, sec uint64) (cnt int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"AcceptBagTransfer", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.AcceptBagTransfer(ctx, bagID, fromAddress, toAddress, sec)
+}
+
+func (m *metricsMiddleware) GetPaidContracts(ctx context.Context, days int) (contracts []db.PaidContract, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetPaidContracts", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetPaidContracts(ctx, days)
+}
+
+func (m *metricsMiddleware) TransitBags(ctx context.Context, transitions []db.BagTransition) (cnt int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"TransitBags", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.TransitBags(ctx, transitions)
+}
+
+func (m *metricsMiddleware) GetBagStateHistory(ctx context.Context, bagID string) (changes []db.BagStateChange, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetBagStateHistory", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetBagStateHistory(ctx, bagID)
+}
+
+func (m *metricsMiddleware) GetBagReplication(ctx context.Context, bagID, userAddress string) (owned bool, replication []db.ProviderReplication, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetBagReplication", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetBagReplication(ctx, bagID, userAddress)
+}
+
+func (m *metricsMiddleware) GetBacklog(ctx context.Context, maxNotifyAttempts int, maxDownloadChecks int) (backlog db.Backlog, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetBacklog", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetBacklog(ctx, maxNotifyAttempts, maxDownloadChecks)
+}
+
+func (m *metricsMiddleware) GetRetentionCandidates(ctx context.Context, limit int, sec uint64, maxNotifyAttempts int, maxDownloadChecks int) (candidates []db.RetentionCandidate, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetRetentionCandidates", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetRetentionCandidates(ctx, limit, sec, maxNotifyAttempts, maxDownloadChecks)
+}
+
+func (m *metricsMiddleware) SaveRetentionDecisions(ctx context.Context, decisions []db.RetentionDecision) (err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"SaveRetentionDecisions", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.SaveRetentionDecisions(ctx, decisions)
+}
+
+func (m *metricsMiddleware) ReleaseBags(ctx context.Context, bagIDs []string) (released []string, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"ReleaseBags", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.ReleaseBags(ctx, bagIDs)
+}
+
+func (m *metricsMiddleware) PinBag(ctx context.Context, bagID, note string) (err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"PinBag", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.PinBag(ctx, bagID, note)
+}
+
+func (m *metricsMiddleware) UnpinBag(ctx context.Context, bagID string) (cnt int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"UnpinBag", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.UnpinBag(ctx, bagID)
+}
+
+func (m *metricsMiddleware) GetBagRetention(ctx context.Context, bagID string) (pin *db.BagPin, decision *db.RetentionDecision, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetBagRetention", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetBagRetention(ctx, bagID)
+}
+
 func NewMetrics(reqCount *prometheus.CounterVec, reqDuration *prometheus.HistogramVec, repo Repository) Repository {
 	return &metricsMiddleware{
 		reqCount:    reqCount,
@@ -7,6 +7,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"mytonstorage-backend/pkg/models/db"
+	"mytonstorage-backend/pkg/repositories/lifecycle"
 )
 
 type repository struct {
@@ -18,7 +19,9 @@ type Repository interface {
 	RemoveUserBagRelation(ctx context.Context, bagID, userAddress string) (int64, error)
 	RemoveUnpaidBagsRelations(ctx context.Context, sec uint64) (bagids []string, err error)
 	RemoveUnusedBags(ctx context.Context) (removed []string, err error)
-	RemoveNotifiedBags(ctx context.Context, limit int, sec uint64, maxNotifyAttempts int, maxDownloadChecks int) (removed []string, err error)
+	GetRetentionCandidates(ctx context.Context, limit int, sec uint64, maxNotifyAttempts int, maxDownloadChecks int) (candidates []db.RetentionCandidate, err error)
+	SaveRetentionDecisions(ctx context.Context, decisions []db.RetentionDecision) error
+	ReleaseBags(ctx context.Context, bagIDs []string) (released []string, err error)
 	CanUpload(ctx context.Context, userID string, sec uint64) (bool, error)
 	GetUnpaidBags(ctx context.Context, userID string) ([]db.UserBagInfo, error)
 	IsBagExpired(ctx context.Context, bagID string, userAddress string, sec uint64) (expired bool, err error)
@@ -28,6 +31,22 @@ type Repository interface {
 
 	GetNotifyInfo(ctx context.Context, limit int, notifyAttempts int) ([]db.BagStorageContract, error)
 	IncreaseAttempts(ctx context.Context, bags []db.BagStorageContract) error
+
+	TransitBags(ctx context.Context, transitions []db.BagTransition) (int64, error)
+	GetBagStateHistory(ctx context.Context, bagID string) ([]db.BagStateChange, error)
+	GetBagReplication(ctx context.Context, bagID, userAddress string) (owned bool, replication []db.ProviderReplication, err error)
+	GetBacklog(ctx context.Context, maxNotifyAttempts int, maxDownloadChecks int) (backlog db.Backlog, err error)
+
+	PinBag(ctx context.Context, bagID, note string) error
+	UnpinBag(ctx context.Context, bagID string) (int64, error)
+	GetBagRetention(ctx context.Context, bagID string) (pin *db.BagPin, decision *db.RetentionDecision, err error)
+
+	ProposeBagTransfer(ctx context.Context, bagID, fromAddress, toAddress string) (int64, error)
+	GetBagTransfers(ctx context.Context, userAddress string, sec uint64) ([]db.BagTransfer, error)
+	CancelBagTransfer(ctx context.Context, bagID, fromAddress string) (int64, error)
+	AcceptBagTransfer(ctx context.Context, bagID, fromAddress, toAddress string, sec uint64) (int64, error)
+
+	GetPaidContracts(ctx context.Context, days int) ([]db.PaidContract, error)
 }
 
 func (r *repository) AddBag(ctx context.Context, bag db.BagInfo, userAddr string) error {
@@ -54,6 +73,7 @@ func (r *repository) RemoveUnusedBags(ctx context.Context) (removed []string, er
 			FROM files.bags b
 				LEFT JOIN files.bag_users bu ON b.bagid = bu.bagid
 			WHERE bu.bagid IS NULL
+				AND NOT EXISTS (SELECT 1 FROM files.bag_pins p WHERE p.bagid = b.bagid) -- pinned by operator
 		),
 		remove AS (
 			DELETE FROM files.bags
@@ -94,85 +114,273 @@ func (r *repository) RemoveUserBagRelation(ctx context.Context, bagID, userAddre
 	return
 }
 
+// RemoveUnpaidBagsRelations moves unpaid relations older than sec to the expired state and removes them
 func (r *repository) RemoveUnpaidBagsRelations(ctx context.Context, sec uint64) (bagids []string, err error) {
-	query := `
-		WITH to_remove AS (
-			SELECT bu.bagid, bu.user_address
-			FROM files.bag_users bu
-			WHERE bu.storage_contract IS NULL 
-				AND EXTRACT(EPOCH FROM (NOW() - bu.created_at)) > $1
-		),
-		remove AS (
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	selectExpired := `
+		SELECT bagid, user_address
+		FROM files.bag_users
+		WHERE state = $1
+			AND storage_contract IS NULL -- never expire a relation that has a contract, whatever its state says
+			AND EXTRACT(EPOCH FROM (NOW() - created_at)) > $2
+		FOR UPDATE
+	`
+	rows, err := tx.Query(ctx, selectExpired, db.BagStateUploaded, sec)
+	if err != nil {
+		return
+	}
+
+	var transitions []db.BagTransition
+	for rows.Next() {
+		t := db.BagTransition{
+			State:  db.BagStateExpired,
+			Reason: "unpaid bag lifetime exceeded",
+		}
+		if err = rows.Scan(&t.BagID, &t.UserAddress); err != nil {
+			rows.Close()
+			return
+		}
+		transitions = append(transitions, t)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	if len(transitions) == 0 {
+		return
+	}
+
+	if _, err = lifecycle.TransitBags(ctx, tx, transitions); err != nil {
+		return
+	}
+
+	remove := `
+		WITH remove AS (
 			DELETE FROM files.bag_users
-			WHERE (bagid, user_address) IN (SELECT bagid, user_address FROM to_remove)
+			WHERE state = $1
 			RETURNING bagid
 		)
 		SELECT DISTINCT bagid FROM remove
 	`
-	rows, err := r.db.Query(ctx, query, sec)
+	rows, err = tx.Query(ctx, remove, db.BagStateExpired)
 	if err != nil {
-		return nil, err
+		return
 	}
-	defer rows.Close()
 
 	for rows.Next() {
 		var bagID string
-		if err := rows.Scan(&bagID); err != nil {
-			return nil, err
+		if err = rows.Scan(&bagID); err != nil {
+			rows.Close()
+			return
 		}
 		bagids = append(bagids, bagID)
 	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return
+	}
 
-	return bagids, nil
+	err = tx.Commit(ctx)
+
+	return
 }
 
-func (r *repository) RemoveNotifiedBags(ctx context.Context, limit int, sec uint64, maxNotifyAttempts int, maxDownloadChecks int) (removed []string, err error) {
+// GetRetentionCandidates returns bags whose provider notifications are all finished and not updated for sec seconds:
+// failed to notify after N attempts, failed download check after N attempts or fully downloaded.
+// Bags not evaluated for the longest time come first, so held bags don't starve others.
+func (r *repository) GetRetentionCandidates(ctx context.Context, limit int, sec uint64, maxNotifyAttempts int, maxDownloadChecks int) (candidates []db.RetentionCandidate, err error) {
 	query := `
-		WITH cte AS (
-			SELECT 
-				n.provider_pubkey, 
-				n.storage_contract, 
-				n.bagid, 
-				( 
-					(
-						(NOT n.notified AND n.notify_attempts >= $1) -- failed to notify after N attempts
-						OR (n.notified AND n.download_checks >= $2) -- failed download check after N attempts
-						OR (n.size = n.downloaded) -- fully downloaded
-					)
-					AND EXTRACT(EPOCH FROM (NOW() - n.updated_at)) > $3
-				) as can_delete
-			FROM providers.notifications n 
-		), del AS (
-			SELECT c.provider_pubkey, c.storage_contract
-			FROM cte c
-			WHERE c.bagid IN (
-				SELECT bagid 
-				FROM cte 
-				GROUP BY bagid 
-				HAVING MIN(can_delete::int) = 1  -- for one bag all can_delete must be true
-			)
-			LIMIT $4
+		WITH n AS (
+			SELECT
+				n.bagid,
+				n.provider_pubkey,
+				n.updated_at,
+				n.notified,
+				(n.size = n.downloaded) AS replicated,
+				(
+					(NOT n.notified AND n.notify_attempts >= $1)
+					OR (n.notified AND n.download_checks >= $2)
+					OR (n.size = n.downloaded)
+				) AS finished
+			FROM providers.notifications n
+		), bags AS (
+			SELECT
+				bagid,
+				COUNT(DISTINCT provider_pubkey) AS providers,
+				COUNT(DISTINCT provider_pubkey) FILTER (WHERE notified) AS notified,
+				COUNT(DISTINCT provider_pubkey) FILTER (WHERE replicated) AS replicas,
+				MAX(updated_at) AS finished_at
+			FROM n
+			GROUP BY bagid
+			HAVING bool_and(finished) -- for one bag all notifications must be finished
+				AND EXTRACT(EPOCH FROM (NOW() - MAX(updated_at))) > $3
 		)
-		DELETE FROM providers.notifications n
-		USING del
-		WHERE (n.provider_pubkey, n.storage_contract) = (del.provider_pubkey, del.storage_contract)
-		RETURNING n.bagid;`
+		SELECT
+			b.bagid,
+			b.providers,
+			b.notified,
+			b.replicas,
+			(SELECT COUNT(*) FROM providers.proof_audit pa WHERE pa.bagid = b.bagid AND pa.valid),
+			p.bagid IS NOT NULL,
+			b.finished_at,
+			COALESCE(r.decision, ''),
+			COALESCE(r.reason, '')
+		FROM bags b
+			LEFT JOIN files.bag_pins p ON p.bagid = b.bagid
+			LEFT JOIN files.bag_retention r ON r.bagid = b.bagid
+		ORDER BY r.decided_at NULLS FIRST
+		LIMIT $4`
 
 	rows, err := r.db.Query(ctx, query, maxNotifyAttempts, maxDownloadChecks, sec, limit)
 	if err != nil {
-		return nil, err
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c db.RetentionCandidate
+		var finishedAt *time.Time
+		if err = rows.Scan(&c.BagID, &c.Providers, &c.Notified, &c.Replicas, &c.Proofs, &c.Pinned, &finishedAt, &c.PrevDecision, &c.PrevReason); err != nil {
+			return
+		}
+		if finishedAt != nil {
+			c.FinishedAt = finishedAt.Unix()
+		}
+		candidates = append(candidates, c)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+func (r *repository) SaveRetentionDecisions(ctx context.Context, decisions []db.RetentionDecision) (err error) {
+	if len(decisions) == 0 {
+		return
+	}
+
+	query := `
+		INSERT INTO files.bag_retention (bagid, decision, reason, replicas, proofs, decided_at)
+		SELECT x.bagid, x.decision, x.reason, x.replicas, x.proofs, now()
+		FROM jsonb_to_recordset($1::jsonb) AS x(bagid text, decision text, reason text, replicas integer, proofs integer)
+		ON CONFLICT (bagid) DO UPDATE
+			SET decision = EXCLUDED.decision,
+				reason = EXCLUDED.reason,
+				replicas = EXCLUDED.replicas,
+				proofs = EXCLUDED.proofs,
+				decided_at = EXCLUDED.decided_at
+	`
+	_, err = r.db.Exec(ctx, query, decisions)
+	return
+}
+
+// ReleaseBags removes provider notifications of the bags, staged data of the returned bags can be removed from disk
+func (r *repository) ReleaseBags(ctx context.Context, bagIDs []string) (released []string, err error) {
+	if len(bagIDs) == 0 {
+		return
+	}
+
+	query := `
+		WITH del AS (
+			DELETE FROM providers.notifications
+			WHERE bagid = ANY($1::text[])
+			RETURNING bagid
+		)
+		SELECT DISTINCT bagid FROM del`
+
+	rows, err := r.db.Query(ctx, query, bagIDs)
+	if err != nil {
+		return
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var bagID string
-		if err := rows.Scan(&bagID); err != nil {
-			return nil, err
+		if err = rows.Scan(&bagID); err != nil {
+			return
 		}
-		removed = append(removed, bagID)
+		released = append(released, bagID)
 	}
 
-	return removed, nil
+	err = rows.Err()
+
+	return
+}
+
+func (r *repository) PinBag(ctx context.Context, bagID, note string) (err error) {
+	query := `
+		INSERT INTO files.bag_pins (bagid, note)
+		VALUES ($1, $2)
+		ON CONFLICT (bagid) DO UPDATE
+			SET note = EXCLUDED.note
+	`
+	_, err = r.db.Exec(ctx, query, bagID, note)
+	return
+}
+
+func (r *repository) UnpinBag(ctx context.Context, bagID string) (cnt int64, err error) {
+	query := `
+		DELETE FROM files.bag_pins
+		WHERE bagid = $1
+	`
+	row, err := r.db.Exec(ctx, query, bagID)
+	if err != nil {
+		return
+	}
+
+	cnt = row.RowsAffected()
+
+	return
+}
+
+// GetBagRetention returns the pin and the last retention decision of the bag, nil when there is none
+func (r *repository) GetBagRetention(ctx context.Context, bagID string) (pin *db.BagPin, decision *db.RetentionDecision, err error) {
+	query := `
+		SELECT
+			p.bagid IS NOT NULL, COALESCE(p.note, ''), p.created_at,
+			r.bagid IS NOT NULL, COALESCE(r.decision, ''), COALESCE(r.reason, ''), COALESCE(r.replicas, 0), COALESCE(r.proofs, 0), r.decided_at
+		FROM (SELECT $1::text AS bagid) b
+			LEFT JOIN files.bag_pins p ON p.bagid = b.bagid
+			LEFT JOIN files.bag_retention r ON r.bagid = b.bagid
+	`
+
+	var pinned, decided bool
+	var p db.BagPin
+	var d db.RetentionDecision
+	var pinnedAt, decidedAt *time.Time
+	err = r.db.QueryRow(ctx, query, bagID).Scan(
+		&pinned, &p.Note, &pinnedAt,
+		&decided, &d.Decision, &d.Reason, &d.Replicas, &d.Proofs, &decidedAt,
+	)
+	if err != nil {
+		return
+	}
+
+	if pinned {
+		p.BagID = bagID
+		if pinnedAt != nil {
+			p.CreatedAt = pinnedAt.Unix()
+		}
+		pin = &p
+	}
+
+	if decided {
+		d.BagID = bagID
+		if decidedAt != nil {
+			d.DecidedAt = decidedAt.Unix()
+		}
+		decision = &d
+	}
+
+	return
 }
 
 func (r *repository) CanUpload(ctx context.Context, userID string, sec uint64) (bool, error) {
@@ -181,13 +389,13 @@ func (r *repository) CanUpload(ctx context.Context, userID string, sec uint64) (
 			SELECT 1
 			FROM files.bag_users
 			WHERE user_address = $1 
-				AND storage_contract IS NULL 
+				AND state = $3 
 				AND (NOW() - created_at) < $2
 		)
 	`
 
 	var hasUnpaid bool
-	err := r.db.QueryRow(ctx, query, userID, time.Duration(sec)*time.Second).Scan(&hasUnpaid)
+	err := r.db.QueryRow(ctx, query, userID, time.Duration(sec)*time.Second, db.BagStateUploaded).Scan(&hasUnpaid)
 	if err != nil {
 		return false, err
 	}
@@ -200,9 +408,9 @@ func (r *repository) GetUnpaidBags(ctx context.Context, userID string) ([]db.Use
 	query := `
 		SELECT bagid, user_address, created_at
 		FROM files.bag_users
-		WHERE user_address = $1 AND storage_contract IS NULL;
+		WHERE user_address = $1 AND state = $2;
 	`
-	rows, err := r.db.Query(ctx, query, userID)
+	rows, err := r.db.Query(ctx, query, userID, db.BagStateUploaded)
 	if err != nil {
 		return nil, err
 	}
@@ -228,26 +436,49 @@ func (r *repository) IsBagExpired(ctx context.Context, bagID string, userAddress
 			FROM files.bag_users
 			WHERE bagid = $1 
 				AND user_address = $2 
-				AND storage_contract IS NULL 
+				AND state = $4 
 				AND EXTRACT(EPOCH FROM (NOW() - created_at)) > $3
 		);
 	`
-	err = r.db.QueryRow(ctx, query, bagID, userAddress, sec).Scan(&expired)
+	err = r.db.QueryRow(ctx, query, bagID, userAddress, sec, db.BagStateUploaded).Scan(&expired)
 	return
 }
 
 func (r *repository) MarkBagAsPaid(ctx context.Context, bagID, userAddress, storageContract string) (cnt int64, err error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
 	query := `
 		UPDATE files.bag_users
 		SET storage_contract = $3
 		WHERE bagid = $1 AND user_address = $2
 		RETURNING 1;
 	`
-	row, err := r.db.Exec(ctx, query, bagID, userAddress, storageContract)
+	row, err := tx.Exec(ctx, query, bagID, userAddress, storageContract)
+	if err != nil || row.RowsAffected() == 0 {
+		return
+	}
+
+	// Contract of an already paid bag can be replaced, state stays the same then
+	_, err = lifecycle.TransitBags(ctx, tx, []db.BagTransition{{
+		BagID:       bagID,
+		UserAddress: userAddress,
+		State:       db.BagStatePaid,
+		Reason:      "storage contract " + storageContract,
+	}})
 	if err != nil {
 		return
 	}
 
+	if err = tx.Commit(ctx); err != nil {
+		return
+	}
+
 	cnt = row.RowsAffected()
 
 	return
@@ -255,7 +486,7 @@ func (r *repository) MarkBagAsPaid(ctx context.Context, bagID, userAddress, stor
 
 func (r *repository) GetBagsInfoShort(ctx context.Context, contracts []string) (descriptions []db.BagDescription, err error) {
 	query := `
-		SELECT bu.storage_contract, b.bagid, b.description, b.size
+		SELECT bu.storage_contract, b.bagid, b.description, b.size, bu.state
 		FROM files.bag_users bu 
 			JOIN files.bags b ON b.bagid = bu.bagid
 		WHERE bu.storage_contract = ANY($1::text[])
@@ -269,7 +500,7 @@ func (r *repository) GetBagsInfoShort(ctx context.Context, contracts []string) (
 
 	for rows.Next() {
 		var desc db.BagDescription
-		if err := rows.Scan(&desc.ContractAddress, &desc.BagID, &desc.Description, &desc.Size); err != nil {
+		if err := rows.Scan(&desc.ContractAddress, &desc.BagID, &desc.Description, &desc.Size, &desc.State); err != nil {
 			return nil, err
 		}
 		descriptions = append(descriptions, desc)
@@ -281,22 +512,24 @@ func (r *repository) GetBagsInfoShort(ctx context.Context, contracts []string) (
 func (r *repository) GetNotifyInfo(ctx context.Context, limit int, notifyAttempts int) (resp []db.BagStorageContract, err error) {
 	var info db.BagStorageContract
 	query := `
-		SELECT bu.bagid, bu.storage_contract, b.files_size
+		SELECT bu.bagid, bu.storage_contract, b.files_size, bu.notify_attempts
 		FROM files.bag_users bu
 			JOIN files.bags b ON b.bagid=bu.bagid
-		WHERE bu.storage_contract IS NOT NULL 
-			AND bu.notify_attempts >= 0 
+		WHERE bu.state = $3
+			AND bu.storage_contract IS NOT NULL 
 			AND bu.notify_attempts < $2
+			AND bu.next_attempt_at <= now()
+		ORDER BY bu.next_attempt_at
 		LIMIT $1;
 	`
-	rows, err := r.db.Query(ctx, query, limit, notifyAttempts)
+	rows, err := r.db.Query(ctx, query, limit, notifyAttempts, db.BagStatePaid)
 	if err != nil {
 		return
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		if sErr := rows.Scan(&info.BagID, &info.StorageContract, &info.FilesSize); sErr != nil {
+		if sErr := rows.Scan(&info.BagID, &info.StorageContract, &info.FilesSize, &info.NotifyAttempts); sErr != nil {
 			err = sErr
 			return
 		}
@@ -308,17 +541,346 @@ func (r *repository) GetNotifyInfo(ctx context.Context, limit int, notifyAttempt
 func (r *repository) IncreaseAttempts(ctx context.Context, bags []db.BagStorageContract) (err error) {
 	query := `
 		WITH cte AS (
-			SELECT x.bagid, x.storage_contract
-			FROM jsonb_array_elements($1::jsonb) AS x(bagid, storage_contract)
+			SELECT x.bagid, x.storage_contract, x.next_attempt_at
+			FROM jsonb_to_recordset($1::jsonb) AS x(bagid text, storage_contract text, next_attempt_at bigint)
 		)
-		UPDATE files.bag_users
-		SET notify_attempts = notify_attempts + 1
-		WHERE (bagid, storage_contract) IN (SELECT bagid, storage_contract FROM cte)
+		UPDATE files.bag_users bu
+		SET notify_attempts = bu.notify_attempts + 1,
+			next_attempt_at = to_timestamp(c.next_attempt_at)
+		FROM cte c
+		WHERE (bu.bagid, bu.storage_contract) = (c.bagid, c.storage_contract)
 	`
 	_, err = r.db.Exec(ctx, query, bags)
 	return
 }
 
+// TransitBags moves bag relations to new states, transitions not allowed by the lifecycle are skipped.
+// Every applied transition is written to files.bag_states_history.
+func (r *repository) TransitBags(ctx context.Context, transitions []db.BagTransition) (cnt int64, err error) {
+	return lifecycle.TransitBags(ctx, r.db, transitions)
+}
+
+func (r *repository) GetBagStateHistory(ctx context.Context, bagID string) (changes []db.BagStateChange, err error) {
+	query := `
+		SELECT bagid, user_address, COALESCE(storage_contract, ''), from_state, to_state, reason, created_at
+		FROM files.bag_states_history
+		WHERE bagid = $1
+		ORDER BY created_at, id
+	`
+	rows, err := r.db.Query(ctx, query, bagID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c db.BagStateChange
+		var createdAt *time.Time
+		if err = rows.Scan(&c.BagID, &c.UserAddress, &c.StorageContract, &c.FromState, &c.ToState, &c.Reason, &createdAt); err != nil {
+			return
+		}
+		if createdAt != nil {
+			c.CreatedAt = createdAt.Unix()
+		}
+		changes = append(changes, c)
+	}
+
+	return
+}
+
+// GetBagReplication returns notifications of the user bag contracts, archived ones come from notifications_history.
+// owned is false when the user has no current or removed relation with the bag.
+func (r *repository) GetBagReplication(ctx context.Context, bagID, userAddress string) (owned bool, replication []db.ProviderReplication, err error) {
+	query := `
+		WITH c AS (
+			SELECT storage_contract
+			FROM files.bag_users
+			WHERE bagid = $1 AND user_address = $2
+			UNION
+			SELECT storage_contract
+			FROM files.bag_users_history
+			WHERE bagid = $1 AND user_address = $2
+				AND transferred_to IS NULL
+		), n AS (
+			SELECT storage_contract, provider_pubkey, notified, notify_attempts, download_checks::int, size, downloaded,
+				updated_at AS last_check_at, downloaded_at, false AS archived
+			FROM providers.notifications
+			WHERE bagid = $1
+			UNION ALL
+			SELECT storage_contract, provider_pubkey, notified, notify_attempts, download_checks, size, downloaded,
+				archived_at::timestamptz, downloaded_at, true
+			FROM providers.notifications_history
+			WHERE bagid = $1
+		)
+		SELECT c.storage_contract, n.provider_pubkey, n.notified, n.notify_attempts, n.download_checks,
+			n.size, n.downloaded, n.last_check_at, n.downloaded_at, n.archived
+		FROM c
+			LEFT JOIN n ON n.storage_contract = c.storage_contract
+		ORDER BY c.storage_contract, n.archived, n.provider_pubkey
+	`
+	rows, err := r.db.Query(ctx, query, bagID, userAddress)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		owned = true
+
+		var contract, pubkey *string
+		var notified, archived *bool
+		var notifyAttempts, downloadChecks *int
+		var size, downloaded *uint64
+		var lastCheckAt, downloadedAt *time.Time
+		if err = rows.Scan(&contract, &pubkey, &notified, &notifyAttempts, &downloadChecks, &size, &downloaded, &lastCheckAt, &downloadedAt, &archived); err != nil {
+			return
+		}
+
+		// Unpaid relation or contract without notifications yet
+		if contract == nil || pubkey == nil {
+			continue
+		}
+
+		p := db.ProviderReplication{
+			StorageContract: *contract,
+			ProviderPubkey:  *pubkey,
+			Notified:        *notified,
+			NotifyAttempts:  *notifyAttempts,
+			DownloadChecks:  *downloadChecks,
+			Size:            *size,
+			Downloaded:      *downloaded,
+			Archived:        *archived,
+		}
+		if lastCheckAt != nil {
+			p.LastCheckAt = lastCheckAt.Unix()
+		}
+		if downloadedAt != nil {
+			p.DownloadedAt = downloadedAt.Unix()
+		}
+		replication = append(replication, p)
+	}
+
+	return
+}
+
+// GetBacklog counts items of the files pipeline that are not finished yet.
+// Removals pending are bags with all provider notifications finished, successfully or not, waiting for the retention policy.
+func (r *repository) GetBacklog(ctx context.Context, maxNotifyAttempts int, maxDownloadChecks int) (backlog db.Backlog, err error) {
+	query := `
+		WITH n AS (
+			SELECT
+				bagid,
+				(NOT notified AND notify_attempts < $1) AS pending,
+				(notified AND downloaded < size AND download_checks < $2) AS downloading
+			FROM providers.notifications
+		)
+		SELECT
+			(SELECT COUNT(*) FROM files.bag_users WHERE state = $3),
+			(SELECT COUNT(*) FROM files.bag_users WHERE state = $4),
+			(SELECT COUNT(*) FROM n WHERE pending),
+			(SELECT COUNT(*) FROM n WHERE downloading),
+			(
+				SELECT COUNT(*)
+				FROM (
+					SELECT bagid
+					FROM n
+					GROUP BY bagid
+					HAVING NOT bool_or(pending OR downloading)
+				) r
+			);
+	`
+
+	err = r.db.QueryRow(ctx, query, maxNotifyAttempts, maxDownloadChecks, db.BagStateUploaded, db.BagStatePaid).Scan(
+		&backlog.UnpaidBags,
+		&backlog.ContractsToResolve,
+		&backlog.NotificationsPending,
+		&backlog.DownloadsInProgress,
+		&backlog.RemovalsPending,
+	)
+
+	return
+}
+
+func (r *repository) ProposeBagTransfer(ctx context.Context, bagID, fromAddress, toAddress string) (cnt int64, err error) {
+	query := `
+		INSERT INTO files.bag_transfers (bagid, from_address, to_address, created_at)
+		SELECT bagid, user_address, $3, NOW()
+		FROM files.bag_users
+		WHERE bagid = $1 AND user_address = $2
+		ON CONFLICT (bagid, from_address) DO UPDATE
+			SET to_address = EXCLUDED.to_address,
+				created_at = EXCLUDED.created_at
+	`
+	row, err := r.db.Exec(ctx, query, bagID, fromAddress, toAddress)
+	if err != nil {
+		return
+	}
+
+	cnt = row.RowsAffected()
+
+	return
+}
+
+func (r *repository) GetBagTransfers(ctx context.Context, userAddress string, sec uint64) (transfers []db.BagTransfer, err error) {
+	query := `
+		SELECT t.bagid, t.from_address, t.to_address, b.description, b.size, t.created_at
+		FROM files.bag_transfers t
+			JOIN files.bags b ON b.bagid = t.bagid
+		WHERE (t.from_address = $1 OR t.to_address = $1)
+			AND EXTRACT(EPOCH FROM (NOW() - t.created_at)) < $2
+		ORDER BY t.created_at DESC
+	`
+	rows, err := r.db.Query(ctx, query, userAddress, sec)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t db.BagTransfer
+		var createdAt *time.Time
+		if err = rows.Scan(&t.BagID, &t.FromAddress, &t.ToAddress, &t.Description, &t.Size, &createdAt); err != nil {
+			return
+		}
+		if createdAt != nil {
+			t.CreatedAt = createdAt.Unix()
+		}
+		transfers = append(transfers, t)
+	}
+
+	return
+}
+
+func (r *repository) CancelBagTransfer(ctx context.Context, bagID, fromAddress string) (cnt int64, err error) {
+	query := `
+		DELETE FROM files.bag_transfers
+		WHERE bagid = $1 AND from_address = $2
+	`
+	row, err := r.db.Exec(ctx, query, bagID, fromAddress)
+	if err != nil {
+		return
+	}
+
+	cnt = row.RowsAffected()
+
+	return
+}
+
+// AcceptBagTransfer moves bag relation to the new owner in one transaction.
+// Returns 0 if there is no pending transfer or the sender no longer owns the bag.
+func (r *repository) AcceptBagTransfer(ctx context.Context, bagID, fromAddress, toAddress string, sec uint64) (cnt int64, err error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	takeTransfer := `
+		DELETE FROM files.bag_transfers
+		WHERE bagid = $1 AND from_address = $2 AND to_address = $3
+			AND EXTRACT(EPOCH FROM (NOW() - created_at)) < $4
+	`
+	row, err := tx.Exec(ctx, takeTransfer, bagID, fromAddress, toAddress, sec)
+	if err != nil || row.RowsAffected() == 0 {
+		return
+	}
+
+	// Old row goes to bag_users_history through the delete trigger
+	moveRelation := `
+		WITH old AS (
+			DELETE FROM files.bag_users
+			WHERE bagid = $1 AND user_address = $2
+			RETURNING bagid, storage_contract, created_at, notify_attempts, workspace_id, state, state_reason, state_updated_at
+		)
+		INSERT INTO files.bag_users (bagid, user_address, storage_contract, created_at, updated_at, notify_attempts, workspace_id, state, state_reason, state_updated_at)
+		SELECT bagid, $3, storage_contract, created_at, NOW(), notify_attempts, workspace_id, state, state_reason, state_updated_at
+		FROM old
+		ON CONFLICT (bagid, user_address) DO UPDATE
+			SET storage_contract = COALESCE(files.bag_users.storage_contract, EXCLUDED.storage_contract),
+				notify_attempts = CASE
+					WHEN files.bag_users.storage_contract IS NULL THEN EXCLUDED.notify_attempts
+					ELSE files.bag_users.notify_attempts
+				END,
+				state = CASE
+					WHEN files.bag_users.storage_contract IS NULL THEN EXCLUDED.state
+					ELSE files.bag_users.state
+				END,
+				state_reason = CASE
+					WHEN files.bag_users.storage_contract IS NULL THEN EXCLUDED.state_reason
+					ELSE files.bag_users.state_reason
+				END,
+				updated_at = NOW()
+	`
+	row, err = tx.Exec(ctx, moveRelation, bagID, fromAddress, toAddress)
+	if err != nil || row.RowsAffected() == 0 {
+		return
+	}
+
+	markHistory := `
+		UPDATE files.bag_users_history
+		SET transferred_to = $3
+		WHERE bagid = $1 AND user_address = $2
+	`
+	if _, err = tx.Exec(ctx, markHistory, bagID, fromAddress, toAddress); err != nil {
+		return
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return
+	}
+
+	cnt = row.RowsAffected()
+
+	return
+}
+
+// GetPaidContracts returns contracts of current bags and of bags removed from the node less than days ago
+func (r *repository) GetPaidContracts(ctx context.Context, days int) (contracts []db.PaidContract, err error) {
+	query := `
+		WITH c AS (
+			SELECT storage_contract, bagid, user_address
+			FROM files.bag_users
+			WHERE storage_contract IS NOT NULL
+			UNION
+			SELECT storage_contract, bagid, user_address
+			FROM files.bag_users_history
+			WHERE storage_contract IS NOT NULL
+				AND transferred_to IS NULL
+				AND deleted_at > now() - make_interval(days => $1)
+		)
+		SELECT DISTINCT ON (c.storage_contract)
+			c.storage_contract,
+			c.bagid,
+			c.user_address,
+			COALESCE(
+				b.size,
+				(SELECT max(n.size) FROM providers.notifications n WHERE n.storage_contract = c.storage_contract),
+				(SELECT max(nh.size) FROM providers.notifications_history nh WHERE nh.storage_contract = c.storage_contract),
+				0
+			)
+		FROM c
+			LEFT JOIN files.bags b ON b.bagid = c.bagid
+		ORDER BY c.storage_contract
+	`
+	rows, err := r.db.Query(ctx, query, days)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c db.PaidContract
+		if err = rows.Scan(&c.StorageContract, &c.BagID, &c.UserAddress, &c.Size); err != nil {
+			return
+		}
+		contracts = append(contracts, c)
+	}
+
+	return
+}
+
 func NewRepository(db *pgxpool.Pool) Repository {
 	return &repository{
 		db: db,
@@ -0,0 +1,68 @@
+package lifecycle
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"mytonstorage-backend/pkg/models/db"
+)
+
+// Querier is implemented by both the pool and a transaction
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// TransitBags moves bag relations to new states, transitions not allowed by the lifecycle are skipped.
+// Every applied transition is written to files.bag_states_history.
+// All repositories changing the state of a bag relation must go through it.
+func TransitBags(ctx context.Context, q Querier, transitions []db.BagTransition) (cnt int64, err error) {
+	if len(transitions) == 0 {
+		return
+	}
+
+	type key struct{ bagID, userAddress, storageContract, state string }
+	seen := make(map[key]struct{}, len(transitions))
+	unique := make([]db.BagTransition, 0, len(transitions))
+	for _, t := range transitions {
+		k := key{t.BagID, t.UserAddress, t.StorageContract, t.State}
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		unique = append(unique, t)
+	}
+
+	query := `
+		WITH t AS (
+			SELECT x.bagid, x.user_address, x.storage_contract, x.state, x.reason
+			FROM jsonb_to_recordset($1::jsonb) AS x(bagid text, user_address text, storage_contract text, state text, reason text)
+		), e AS (
+			SELECT e.from_state, e.to_state
+			FROM jsonb_to_recordset($2::jsonb) AS e(from_state text, to_state text)
+		), upd AS (
+			UPDATE files.bag_users bu
+			SET state = t.state,
+				state_reason = t.reason,
+				state_updated_at = now()
+			FROM t, e, files.bag_users old
+			WHERE bu.bagid = t.bagid
+				AND (t.user_address = '' OR bu.user_address = t.user_address)
+				AND (t.storage_contract = '' OR bu.storage_contract = t.storage_contract)
+				AND (old.bagid, old.user_address) = (bu.bagid, bu.user_address)
+				AND (e.from_state, e.to_state) = (old.state, t.state)
+			RETURNING bu.bagid, bu.user_address, bu.storage_contract, old.state AS from_state, bu.state AS to_state, bu.state_reason
+		)
+		INSERT INTO files.bag_states_history (bagid, user_address, storage_contract, from_state, to_state, reason, created_at)
+		SELECT bagid, user_address, storage_contract, from_state, to_state, state_reason, now()
+		FROM upd
+	`
+	row, err := q.Exec(ctx, query, unique, db.BagStateEdges())
+	if err != nil {
+		return
+	}
+
+	cnt = row.RowsAffected()
+
+	return
+}
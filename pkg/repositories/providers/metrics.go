@@ -82,6 +82,248 @@ func (m *metricsMiddleware) MarkAsNotified(ctx context.Context, notifications []
 	return m.repo.MarkAsNotified(ctx, notifications)
 }
 
+func (m *metricsMiddleware) AddProviders(ctx context.Context, pubkeys []string) (cnt int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"AddProviders", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.AddProviders(ctx, pubkeys)
+}
+
+func (m *metricsMiddleware) UpdateProvidersRates(ctx context.Context, providers []db.RegistryProvider) (err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"UpdateProvidersRates", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.UpdateProvidersRates(ctx, providers)
+}
+
+func (m *metricsMiddleware) MarkProvidersUnreachable(ctx context.Context, pubkeys []string) (err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"MarkProvidersUnreachable", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.MarkProvidersUnreachable(ctx, pubkeys)
+}
+
+func (m *metricsMiddleware) GetRegistryProviders(ctx context.Context, filter db.ProvidersFilter) (providers []db.RegistryProvider, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetRegistryProviders", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetRegistryProviders(ctx, filter)
+}
+
+func (m *metricsMiddleware) GetProvidersStats(ctx context.Context, days int, maxNotifyAttempts int, maxDownloadChecks int) (stats []db.ProviderStats, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetProvidersStats", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetProvidersStats(ctx, days, maxNotifyAttempts, maxDownloadChecks)
+}
+
+func (m *metricsMiddleware) GetDownloadedContracts(ctx context.Context, days int, limit int) (contracts []string, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetDownloadedContracts", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetDownloadedContracts(ctx, days, limit)
+}
+
+func (m *metricsMiddleware) UpdateProvidersReputation(ctx context.Context, reputation []db.ProviderReputation) (err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"UpdateProvidersReputation", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.UpdateProvidersReputation(ctx, reputation)
+}
+
+func (m *metricsMiddleware) GetProvidersReputation(ctx context.Context, pubkeys []string) (reputation []db.ProviderReputation, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetProvidersReputation", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetProvidersReputation(ctx, pubkeys)
+}
+
+func (m *metricsMiddleware) GetRegistryPubkeys(ctx context.Context) (pubkeys []string, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetRegistryPubkeys", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetRegistryPubkeys(ctx)
+}
+
+func (m *metricsMiddleware) AddRatesHistory(ctx context.Context, points []db.ProviderRatesPoint) (err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"AddRatesHistory", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.AddRatesHistory(ctx, points)
+}
+
+func (m *metricsMiddleware) GetRatesHistory(ctx context.Context, pubkey string, from, to int64, limit int) (points []db.ProviderRatesPoint, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetRatesHistory", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetRatesHistory(ctx, pubkey, from, to, limit)
+}
+
+func (m *metricsMiddleware) CleanOldRatesHistory(ctx context.Context, days int) (cnt int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"CleanOldRatesHistory", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.CleanOldRatesHistory(ctx, days)
+}
+
+func (m *metricsMiddleware) AddProofIncidents(ctx context.Context, incidents []db.ProofIncident) (err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"AddProofIncidents", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.AddProofIncidents(ctx, incidents)
+}
+
+func (m *metricsMiddleware) ResolveProofIncidents(ctx context.Context, contracts []string, open []db.ProofIncident) (cnt int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"ResolveProofIncidents", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.ResolveProofIncidents(ctx, contracts, open)
+}
+
+func (m *metricsMiddleware) GetIncidentsToReplace(ctx context.Context, limit int) (incidents []db.ProofIncident, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetIncidentsToReplace", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetIncidentsToReplace(ctx, limit)
+}
+
+func (m *metricsMiddleware) SetIncidentReplacement(ctx context.Context, id int64, pubkey string, tx []byte) (err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"SetIncidentReplacement", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.SetIncidentReplacement(ctx, id, pubkey, tx)
+}
+
+func (m *metricsMiddleware) GetUserProofIncidents(ctx context.Context, userAddress string) (incidents []db.ProofIncident, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetUserProofIncidents", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetUserProofIncidents(ctx, userAddress)
+}
+
+func (m *metricsMiddleware) SetProviderPolicy(ctx context.Context, policy db.ProviderPolicy) (err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"SetProviderPolicy", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.SetProviderPolicy(ctx, policy)
+}
+
+func (m *metricsMiddleware) DeleteProviderPolicy(ctx context.Context, pubkey string) (cnt int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"DeleteProviderPolicy", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.DeleteProviderPolicy(ctx, pubkey)
+}
+
+func (m *metricsMiddleware) GetProvidersPolicy(ctx context.Context) (policy []db.ProviderPolicy, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetProvidersPolicy", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetProvidersPolicy(ctx)
+}
+
+func (m *metricsMiddleware) AddProofAudits(ctx context.Context, audits []db.ProofAudit) (err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"AddProofAudits", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.AddProofAudits(ctx, audits)
+}
+
+func (m *metricsMiddleware) CleanOldProofAudits(ctx context.Context, days int) (cnt int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"CleanOldProofAudits", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.CleanOldProofAudits(ctx, days)
+}
+
 func NewMetrics(reqCount *prometheus.CounterVec, reqDuration *prometheus.HistogramVec, repo Repository) Repository {
 	return &metricsMiddleware{
 		reqCount:    reqCount,
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 
@@ -19,6 +20,43 @@ type Repository interface {
 	IncreaseDownloadChecks(ctx context.Context, notifications []db.ProviderNotification) error
 	IncreaseNotifyAttempts(ctx context.Context, notifications []db.ProviderNotification) error
 	MarkAsNotified(ctx context.Context, notifications []db.ProviderNotification) error
+
+	AddProviders(ctx context.Context, pubkeys []string) (int64, error)
+	UpdateProvidersRates(ctx context.Context, providers []db.RegistryProvider) error
+	MarkProvidersUnreachable(ctx context.Context, pubkeys []string) error
+	GetRegistryProviders(ctx context.Context, filter db.ProvidersFilter) (providers []db.RegistryProvider, err error)
+
+	GetProvidersStats(ctx context.Context, days int, maxNotifyAttempts int, maxDownloadChecks int) (stats []db.ProviderStats, err error)
+	GetDownloadedContracts(ctx context.Context, days int, limit int) (contracts []string, err error)
+	UpdateProvidersReputation(ctx context.Context, reputation []db.ProviderReputation) error
+	GetProvidersReputation(ctx context.Context, pubkeys []string) (reputation []db.ProviderReputation, err error)
+
+	GetRegistryPubkeys(ctx context.Context) (pubkeys []string, err error)
+	AddRatesHistory(ctx context.Context, points []db.ProviderRatesPoint) error
+	GetRatesHistory(ctx context.Context, pubkey string, from, to int64, limit int) (points []db.ProviderRatesPoint, err error)
+	CleanOldRatesHistory(ctx context.Context, days int) (cnt int64, err error)
+
+	AddProofAudits(ctx context.Context, audits []db.ProofAudit) error
+	CleanOldProofAudits(ctx context.Context, days int) (cnt int64, err error)
+
+	AddProofIncidents(ctx context.Context, incidents []db.ProofIncident) error
+	ResolveProofIncidents(ctx context.Context, contracts []string, open []db.ProofIncident) (cnt int64, err error)
+	GetIncidentsToReplace(ctx context.Context, limit int) (incidents []db.ProofIncident, err error)
+	SetIncidentReplacement(ctx context.Context, id int64, pubkey string, tx []byte) error
+	GetUserProofIncidents(ctx context.Context, userAddress string) (incidents []db.ProofIncident, err error)
+
+	SetProviderPolicy(ctx context.Context, policy db.ProviderPolicy) error
+	DeleteProviderPolicy(ctx context.Context, pubkey string) (cnt int64, err error)
+	GetProvidersPolicy(ctx context.Context) (policy []db.ProviderPolicy, err error)
+}
+
+var registrySortColumns = map[string]string{
+	"rate":       "p.rate_per_mb_day",
+	"space":      "p.space_available_mb",
+	"last_seen":  "p.last_seen_at",
+	"first_seen": "p.first_seen_at",
+	"max_span":   "p.max_span",
+	"reputation": "r.score",
 }
 
 func (r *repository) AddProviderToNotifyQueue(ctx context.Context, notifications []db.ProviderNotification) (err error) {
@@ -26,10 +64,6 @@ func (r *repository) AddProviderToNotifyQueue(ctx context.Context, notifications
         WITH cte AS (
             SELECT x.bagid, x.storage_contract, x.provider_pubkey, x.size
             FROM jsonb_to_recordset($1::jsonb) AS x(bagid text, storage_contract text, provider_pubkey text, size bigint)
-        ), update_files AS (
-            UPDATE files.bag_users
-            SET notify_attempts = -1
-            WHERE (bagid, storage_contract) IN (SELECT DISTINCT bagid, storage_contract FROM cte)
         )
         INSERT INTO providers.notifications (bagid, storage_contract, provider_pubkey, size)
         SELECT bagid, storage_contract, provider_pubkey, size
@@ -42,13 +76,13 @@ func (r *repository) AddProviderToNotifyQueue(ctx context.Context, notifications
 
 func (r *repository) GetProvidersInProgress(ctx context.Context, limit int, maxDownloadChecks int) (notifications []db.ProviderNotification, err error) {
 	query := `
-		SELECT bagid, storage_contract, provider_pubkey, size, downloaded
+		SELECT bagid, storage_contract, provider_pubkey, size, downloaded, download_checks
 		FROM providers.notifications
 		WHERE size > downloaded 
 			AND notified
 			AND download_checks <= $2
-			AND updated_at < now() - interval '5 minute'
-		ORDER BY updated_at ASC		-- oldest first
+			AND next_attempt_at <= now()
+		ORDER BY next_attempt_at ASC		-- longest due first
 		LIMIT $1
 	`
 	rows, err := r.db.Query(ctx, query, limit, maxDownloadChecks)
@@ -59,7 +93,7 @@ func (r *repository) GetProvidersInProgress(ctx context.Context, limit int, maxD
 
 	for rows.Next() {
 		var provider db.ProviderNotification
-		if err = rows.Scan(&provider.BagID, &provider.StorageContract, &provider.ProviderPubkey, &provider.Size, &provider.Downloaded); err != nil {
+		if err = rows.Scan(&provider.BagID, &provider.StorageContract, &provider.ProviderPubkey, &provider.Size, &provider.Downloaded, &provider.DownloadChecks); err != nil {
 			return
 		}
 		notifications = append(notifications, provider)
@@ -69,9 +103,12 @@ func (r *repository) GetProvidersInProgress(ctx context.Context, limit int, maxD
 
 func (r *repository) GetProvidersToNotify(ctx context.Context, limit int, notifyAttempts int) (notifications []db.ProviderNotification, err error) {
 	query := `
-		SELECT bagid, storage_contract, provider_pubkey, size
+		SELECT bagid, storage_contract, provider_pubkey, size, notify_attempts
 		FROM providers.notifications
-		WHERE notify_attempts <= $2 AND NOT notified
+		WHERE notify_attempts <= $2 
+			AND NOT notified
+			AND next_attempt_at <= now()
+		ORDER BY next_attempt_at ASC
 		LIMIT $1
 	`
 	rows, err := r.db.Query(ctx, query, limit, notifyAttempts)
@@ -82,7 +119,7 @@ func (r *repository) GetProvidersToNotify(ctx context.Context, limit int, notify
 
 	for rows.Next() {
 		var provider db.ProviderNotification
-		if err = rows.Scan(&provider.BagID, &provider.StorageContract, &provider.ProviderPubkey, &provider.Size); err != nil {
+		if err = rows.Scan(&provider.BagID, &provider.StorageContract, &provider.ProviderPubkey, &provider.Size, &provider.NotifyAttempts); err != nil {
 			return
 		}
 		notifications = append(notifications, provider)
@@ -93,12 +130,17 @@ func (r *repository) GetProvidersToNotify(ctx context.Context, limit int, notify
 func (r *repository) IncreaseDownloadChecks(ctx context.Context, notifications []db.ProviderNotification) error {
 	query := `
 		WITH cte AS (
-			SELECT storage_contract, provider_pubkey, downloaded
-			FROM jsonb_to_recordset($1::jsonb) AS x(storage_contract text, provider_pubkey text, downloaded bigint)
+			SELECT storage_contract, provider_pubkey, downloaded, next_attempt_at
+			FROM jsonb_to_recordset($1::jsonb) AS x(storage_contract text, provider_pubkey text, downloaded bigint, next_attempt_at bigint)
 		)
 		UPDATE providers.notifications n
 		SET download_checks = download_checks + 1,
 			downloaded = c.downloaded,
+			next_attempt_at = to_timestamp(c.next_attempt_at),
+			downloaded_at = CASE
+				WHEN n.downloaded_at IS NULL AND c.downloaded >= n.size THEN now()
+				ELSE n.downloaded_at
+			END,
 			updated_at = now()
 		FROM cte c
 		WHERE (n.storage_contract, n.provider_pubkey) = (c.storage_contract, c.provider_pubkey)
@@ -109,33 +151,537 @@ func (r *repository) IncreaseDownloadChecks(ctx context.Context, notifications [
 
 func (r *repository) IncreaseNotifyAttempts(ctx context.Context, notifications []db.ProviderNotification) error {
 	query := `
-		UPDATE providers.notifications
-		SET notify_attempts = notify_attempts + 1,
+		UPDATE providers.notifications n
+		SET notify_attempts = n.notify_attempts + 1,
+			next_attempt_at = to_timestamp(x.next_attempt_at),
 			updated_at = now()
-		WHERE (storage_contract, provider_pubkey) IN (
-			SELECT storage_contract, provider_pubkey
-			FROM jsonb_to_recordset($1::jsonb) AS x(storage_contract text, provider_pubkey text)
-		)
+		FROM jsonb_to_recordset($1::jsonb) AS x(storage_contract text, provider_pubkey text, next_attempt_at bigint)
+		WHERE (n.storage_contract, n.provider_pubkey) = (x.storage_contract, x.provider_pubkey)
 	`
 	_, err := r.db.Exec(ctx, query, notifications)
 	return err
 }
 
+// MarkAsNotified also schedules the first download check at next_attempt_at
 func (r *repository) MarkAsNotified(ctx context.Context, notifications []db.ProviderNotification) error {
 	query := `
-		UPDATE providers.notifications
-		SET notify_attempts = notify_attempts + 1,
+		UPDATE providers.notifications n
+		SET notify_attempts = n.notify_attempts + 1,
 			notified = true,
+			next_attempt_at = to_timestamp(x.next_attempt_at),
 			updated_at = now()
-		WHERE (storage_contract, provider_pubkey) IN (
-			SELECT storage_contract, provider_pubkey
-			FROM jsonb_to_recordset($1::jsonb) AS x(storage_contract text, provider_pubkey text)
-		)
+		FROM jsonb_to_recordset($1::jsonb) AS x(storage_contract text, provider_pubkey text, next_attempt_at bigint)
+		WHERE (n.storage_contract, n.provider_pubkey) = (x.storage_contract, x.provider_pubkey)
 	`
 	_, err := r.db.Exec(ctx, query, notifications)
 	return err
 }
 
+func (r *repository) AddProviders(ctx context.Context, pubkeys []string) (cnt int64, err error) {
+	query := `
+		INSERT INTO providers.registry (pubkey, first_seen_at, updated_at)
+		SELECT DISTINCT lower(pk), now(), now()
+		FROM unnest($1::text[]) AS pk
+		ON CONFLICT (pubkey) DO NOTHING
+	`
+	row, err := r.db.Exec(ctx, query, pubkeys)
+	if err != nil {
+		return
+	}
+
+	cnt = row.RowsAffected()
+
+	return
+}
+
+func (r *repository) UpdateProvidersRates(ctx context.Context, providers []db.RegistryProvider) error {
+	query := `
+		INSERT INTO providers.registry (pubkey, first_seen_at, last_seen_at, rate_per_mb_day, min_bounty, min_span, max_span, space_available_mb, status, updated_at)
+		SELECT lower(x.pubkey), now(), now(), x.rate_per_mb_day, x.min_bounty, x.min_span, x.max_span, x.space_available_mb, x.status, now()
+		FROM jsonb_to_recordset($1::jsonb) AS x(pubkey text, rate_per_mb_day bigint, min_bounty bigint, min_span integer, max_span integer, space_available_mb bigint, status text)
+		ON CONFLICT (pubkey) DO UPDATE
+			SET last_seen_at = EXCLUDED.last_seen_at,
+				rate_per_mb_day = EXCLUDED.rate_per_mb_day,
+				min_bounty = EXCLUDED.min_bounty,
+				min_span = EXCLUDED.min_span,
+				max_span = EXCLUDED.max_span,
+				space_available_mb = EXCLUDED.space_available_mb,
+				status = EXCLUDED.status,
+				updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.Exec(ctx, query, providers)
+	return err
+}
+
+func (r *repository) MarkProvidersUnreachable(ctx context.Context, pubkeys []string) error {
+	query := `
+		UPDATE providers.registry
+		SET status = 'unreachable',
+			updated_at = now()
+		WHERE pubkey IN (SELECT lower(pk) FROM unnest($1::text[]) AS pk)
+	`
+	_, err := r.db.Exec(ctx, query, pubkeys)
+	return err
+}
+
+func (r *repository) GetRegistryProviders(ctx context.Context, filter db.ProvidersFilter) (providers []db.RegistryProvider, err error) {
+	sortColumn, ok := registrySortColumns[filter.SortBy]
+	if !ok {
+		sortColumn = "p.last_seen_at"
+	}
+
+	order := "ASC NULLS LAST"
+	if filter.Desc {
+		order = "DESC NULLS LAST"
+	}
+
+	query := `
+		SELECT p.pubkey, p.first_seen_at, p.last_seen_at, p.rate_per_mb_day, p.min_bounty, p.min_span, p.max_span, p.space_available_mb, p.status,
+			r.score, r.notify_success_rate, r.avg_download_seconds, r.abandoned_rate, r.proof_lag_seconds, r.samples, r.updated_at
+		FROM providers.registry p
+			LEFT JOIN providers.reputation r ON r.pubkey = p.pubkey
+		WHERE ($1 = '' OR p.status = $1)
+			AND p.space_available_mb >= $2
+			AND ($3 = 0 OR p.rate_per_mb_day <= $3)
+			AND ($4 = 0 OR (p.min_span <= $4 AND p.max_span >= $4))
+			AND (coalesce(cardinality($7::text[]), 0) = 0 OR p.pubkey IN (SELECT lower(pk) FROM unnest($7::text[]) AS pk))
+		ORDER BY ` + sortColumn + ` ` + order + `, p.pubkey
+		LIMIT $5 OFFSET $6
+	`
+	rows, err := r.db.Query(ctx, query, filter.Status, filter.MinSpaceMB, filter.MaxRatePerMBDay, filter.Span, filter.Limit, filter.Offset, filter.Pubkeys)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p db.RegistryProvider
+		var firstSeenAt, lastSeenAt, reputationAt *time.Time
+		var score *float64
+		var rep db.ProviderReputation
+		var samples *int64
+		if err = rows.Scan(
+			&p.Pubkey, &firstSeenAt, &lastSeenAt, &p.RatePerMBDay, &p.MinBounty, &p.MinSpan, &p.MaxSpan, &p.SpaceAvailableMB, &p.Status,
+			&score, &rep.NotifySuccessRate, &rep.AvgDownloadSeconds, &rep.AbandonedRate, &rep.ProofLagSeconds, &samples, &reputationAt,
+		); err != nil {
+			return
+		}
+		if score != nil {
+			rep.Pubkey = p.Pubkey
+			rep.Score = *score
+			if samples != nil {
+				rep.Samples = *samples
+			}
+			if reputationAt != nil {
+				rep.UpdatedAt = reputationAt.Unix()
+			}
+			p.Reputation = &rep
+		}
+		if firstSeenAt != nil {
+			p.FirstSeenAt = firstSeenAt.Unix()
+		}
+		if lastSeenAt != nil {
+			p.LastSeenAt = lastSeenAt.Unix()
+		}
+		providers = append(providers, p)
+	}
+
+	return
+}
+
+func (r *repository) GetProvidersStats(ctx context.Context, days int, maxNotifyAttempts int, maxDownloadChecks int) (stats []db.ProviderStats, err error) {
+	query := `
+		WITH n AS (
+			SELECT lower(provider_pubkey) AS pubkey, size, downloaded, notify_attempts, download_checks, notified, created_at, downloaded_at
+			FROM providers.notifications
+			WHERE created_at > now() - make_interval(days => $1)
+			UNION ALL
+			SELECT lower(provider_pubkey), size, downloaded, notify_attempts, download_checks, notified, created_at, downloaded_at
+			FROM providers.notifications_history
+			WHERE archived_at > now() - make_interval(days => $1)
+		)
+		SELECT
+			n.pubkey,
+			count(*),
+			count(*) FILTER (WHERE n.notified),
+			count(*) FILTER (WHERE NOT n.notified AND n.notify_attempts > $2),
+			count(*) FILTER (WHERE n.notified AND n.downloaded >= n.size),
+			count(*) FILTER (WHERE n.notified AND n.downloaded < n.size AND n.download_checks > $3),
+			COALESCE(avg(extract(epoch FROM n.downloaded_at - n.created_at)) FILTER (WHERE n.downloaded_at IS NOT NULL), 0)::bigint,
+			r.proof_lag_seconds
+		FROM n
+			LEFT JOIN providers.reputation r ON r.pubkey = n.pubkey
+		GROUP BY n.pubkey, r.proof_lag_seconds
+	`
+	rows, err := r.db.Query(ctx, query, days, maxNotifyAttempts, maxDownloadChecks)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s db.ProviderStats
+		if err = rows.Scan(&s.Pubkey, &s.Total, &s.Notified, &s.NotifyFailed, &s.Downloaded, &s.Abandoned, &s.AvgDownloadSeconds, &s.ProofLagSeconds); err != nil {
+			return
+		}
+		stats = append(stats, s)
+	}
+
+	return
+}
+
+func (r *repository) GetDownloadedContracts(ctx context.Context, days int, limit int) (contracts []string, err error) {
+	query := `
+		SELECT storage_contract
+		FROM (
+			SELECT storage_contract
+			FROM providers.notifications
+			WHERE notified AND downloaded >= size AND created_at > now() - make_interval(days => $1)
+			UNION
+			SELECT storage_contract
+			FROM providers.notifications_history
+			WHERE notified AND downloaded >= size AND archived_at > now() - make_interval(days => $1)
+		) c
+		ORDER BY random()
+		LIMIT $2
+	`
+	rows, err := r.db.Query(ctx, query, days, limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var contract string
+		if err = rows.Scan(&contract); err != nil {
+			return
+		}
+		contracts = append(contracts, contract)
+	}
+
+	return
+}
+
+func (r *repository) UpdateProvidersReputation(ctx context.Context, reputation []db.ProviderReputation) error {
+	query := `
+		INSERT INTO providers.reputation (pubkey, score, notify_success_rate, avg_download_seconds, abandoned_rate, proof_lag_seconds, samples, updated_at)
+		SELECT lower(x.pubkey), x.score, x.notify_success_rate, x.avg_download_seconds, x.abandoned_rate, x.proof_lag_seconds, x.samples, now()
+		FROM jsonb_to_recordset($1::jsonb) AS x(pubkey text, score double precision, notify_success_rate double precision, avg_download_seconds bigint, abandoned_rate double precision, proof_lag_seconds bigint, samples integer)
+		ON CONFLICT (pubkey) DO UPDATE
+			SET score = EXCLUDED.score,
+				notify_success_rate = EXCLUDED.notify_success_rate,
+				avg_download_seconds = EXCLUDED.avg_download_seconds,
+				abandoned_rate = EXCLUDED.abandoned_rate,
+				proof_lag_seconds = EXCLUDED.proof_lag_seconds,
+				samples = EXCLUDED.samples,
+				updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.db.Exec(ctx, query, reputation)
+	return err
+}
+
+func (r *repository) GetProvidersReputation(ctx context.Context, pubkeys []string) (reputation []db.ProviderReputation, err error) {
+	query := `
+		SELECT pubkey, score, notify_success_rate, avg_download_seconds, abandoned_rate, proof_lag_seconds, samples, updated_at
+		FROM providers.reputation
+		WHERE pubkey IN (SELECT lower(pk) FROM unnest($1::text[]) AS pk)
+	`
+	rows, err := r.db.Query(ctx, query, pubkeys)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rep db.ProviderReputation
+		var updatedAt *time.Time
+		if err = rows.Scan(&rep.Pubkey, &rep.Score, &rep.NotifySuccessRate, &rep.AvgDownloadSeconds, &rep.AbandonedRate, &rep.ProofLagSeconds, &rep.Samples, &updatedAt); err != nil {
+			return
+		}
+		if updatedAt != nil {
+			rep.UpdatedAt = updatedAt.Unix()
+		}
+		reputation = append(reputation, rep)
+	}
+
+	return
+}
+
+func (r *repository) GetRegistryPubkeys(ctx context.Context) (pubkeys []string, err error) {
+	query := `
+		SELECT pubkey
+		FROM providers.registry
+		ORDER BY pubkey
+	`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pubkey string
+		if err = rows.Scan(&pubkey); err != nil {
+			return
+		}
+		pubkeys = append(pubkeys, pubkey)
+	}
+
+	return
+}
+
+func (r *repository) AddRatesHistory(ctx context.Context, points []db.ProviderRatesPoint) error {
+	query := `
+		INSERT INTO providers.rates_history (pubkey, checked_at, reachable, available, rate_per_mb_day, min_bounty, space_available_mb, latency_ms)
+		SELECT lower(x.pubkey), to_timestamp(x.checked_at), x.reachable, x.available, x.rate_per_mb_day, x.min_bounty, x.space_available_mb, x.latency_ms
+		FROM jsonb_to_recordset($1::jsonb) AS x(pubkey text, checked_at bigint, reachable boolean, available boolean, rate_per_mb_day bigint, min_bounty bigint, space_available_mb bigint, latency_ms integer)
+		ON CONFLICT (pubkey, checked_at) DO NOTHING
+	`
+	_, err := r.db.Exec(ctx, query, points)
+	return err
+}
+
+func (r *repository) GetRatesHistory(ctx context.Context, pubkey string, from, to int64, limit int) (points []db.ProviderRatesPoint, err error) {
+	query := `
+		SELECT pubkey, checked_at, reachable, available, rate_per_mb_day, min_bounty, space_available_mb, latency_ms
+		FROM providers.rates_history
+		WHERE pubkey = lower($1)
+			AND checked_at >= to_timestamp($2)
+			AND checked_at <= to_timestamp($3)
+		ORDER BY checked_at ASC
+		LIMIT $4
+	`
+	rows, err := r.db.Query(ctx, query, pubkey, from, to, limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p db.ProviderRatesPoint
+		var checkedAt *time.Time
+		if err = rows.Scan(&p.Pubkey, &checkedAt, &p.Reachable, &p.Available, &p.RatePerMBDay, &p.MinBounty, &p.SpaceAvailableMB, &p.LatencyMs); err != nil {
+			return
+		}
+		if checkedAt != nil {
+			p.CheckedAt = checkedAt.Unix()
+		}
+		points = append(points, p)
+	}
+
+	return
+}
+
+func (r *repository) CleanOldRatesHistory(ctx context.Context, days int) (cnt int64, err error) {
+	query := `
+		DELETE FROM providers.rates_history
+		WHERE checked_at < now() - make_interval(days => $1)
+	`
+	row, err := r.db.Exec(ctx, query, days)
+	if err != nil {
+		return
+	}
+
+	cnt = row.RowsAffected()
+
+	return
+}
+
+func (r *repository) AddProofAudits(ctx context.Context, audits []db.ProofAudit) error {
+	query := `
+		INSERT INTO providers.proof_audit (provider_pubkey, storage_contract, bagid, byte_to_proof, piece, valid, error, checked_at)
+		SELECT lower(x.provider_pubkey), x.storage_contract, x.bagid, x.byte_to_proof, x.piece, x.valid, x.error, now()
+		FROM jsonb_to_recordset($1::jsonb) AS x(provider_pubkey text, storage_contract text, bagid text, byte_to_proof bigint, piece bigint, valid boolean, error text)
+	`
+	_, err := r.db.Exec(ctx, query, audits)
+	return err
+}
+
+func (r *repository) CleanOldProofAudits(ctx context.Context, days int) (cnt int64, err error) {
+	query := `
+		DELETE FROM providers.proof_audit
+		WHERE checked_at < now() - make_interval(days => $1)
+	`
+	row, err := r.db.Exec(ctx, query, days)
+	if err != nil {
+		return
+	}
+
+	cnt = row.RowsAffected()
+
+	return
+}
+
+func (r *repository) AddProofIncidents(ctx context.Context, incidents []db.ProofIncident) error {
+	query := `
+		INSERT INTO providers.proof_incidents (storage_contract, provider_pubkey, bagid, user_address, bag_size, last_proof_at, max_span)
+		SELECT x.storage_contract, lower(x.provider_pubkey), x.bagid, x.user_address, x.bag_size, to_timestamp(x.last_proof_at), x.max_span
+		FROM jsonb_to_recordset($1::jsonb) AS x(storage_contract text, provider_pubkey text, bagid text, user_address text, bag_size bigint, last_proof_at bigint, max_span integer)
+		ON CONFLICT (storage_contract, provider_pubkey) WHERE resolved_at IS NULL DO UPDATE
+			SET last_proof_at = EXCLUDED.last_proof_at,
+				user_address = EXCLUDED.user_address
+	`
+	_, err := r.db.Exec(ctx, query, incidents)
+	return err
+}
+
+// ResolveProofIncidents closes incidents of checked contracts that are not in the open list anymore
+func (r *repository) ResolveProofIncidents(ctx context.Context, contracts []string, open []db.ProofIncident) (cnt int64, err error) {
+	query := `
+		UPDATE providers.proof_incidents
+		SET resolved_at = now()
+		WHERE resolved_at IS NULL
+			AND storage_contract = ANY($1::text[])
+			AND (storage_contract, provider_pubkey) NOT IN (
+				SELECT x.storage_contract, lower(x.provider_pubkey)
+				FROM jsonb_to_recordset($2::jsonb) AS x(storage_contract text, provider_pubkey text)
+			)
+	`
+	if open == nil {
+		open = []db.ProofIncident{}
+	}
+
+	row, err := r.db.Exec(ctx, query, contracts, open)
+	if err != nil {
+		return
+	}
+
+	cnt = row.RowsAffected()
+
+	return
+}
+
+func (r *repository) GetIncidentsToReplace(ctx context.Context, limit int) (incidents []db.ProofIncident, err error) {
+	query := `
+		SELECT id, storage_contract, provider_pubkey, bagid, user_address, bag_size, last_proof_at, max_span, detected_at
+		FROM providers.proof_incidents
+		WHERE resolved_at IS NULL AND replacement_tx IS NULL
+		ORDER BY detected_at ASC
+		LIMIT $1
+	`
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i db.ProofIncident
+		var lastProofAt, detectedAt *time.Time
+		if err = rows.Scan(&i.ID, &i.StorageContract, &i.ProviderPubkey, &i.BagID, &i.UserAddress, &i.BagSize, &lastProofAt, &i.MaxSpan, &detectedAt); err != nil {
+			return
+		}
+		if lastProofAt != nil {
+			i.LastProofAt = lastProofAt.Unix()
+		}
+		if detectedAt != nil {
+			i.DetectedAt = detectedAt.Unix()
+		}
+		incidents = append(incidents, i)
+	}
+
+	return
+}
+
+func (r *repository) SetIncidentReplacement(ctx context.Context, id int64, pubkey string, tx []byte) error {
+	query := `
+		UPDATE providers.proof_incidents
+		SET replacement_pubkey = lower($2),
+			replacement_tx = $3::jsonb
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query, id, pubkey, string(tx))
+	return err
+}
+
+func (r *repository) GetUserProofIncidents(ctx context.Context, userAddress string) (incidents []db.ProofIncident, err error) {
+	query := `
+		SELECT id, storage_contract, provider_pubkey, bagid, user_address, bag_size, last_proof_at, max_span, detected_at,
+			COALESCE(replacement_pubkey, ''), replacement_tx
+		FROM providers.proof_incidents
+		WHERE resolved_at IS NULL AND user_address = $1
+		ORDER BY detected_at DESC
+	`
+	rows, err := r.db.Query(ctx, query, userAddress)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i db.ProofIncident
+		var lastProofAt, detectedAt *time.Time
+		if err = rows.Scan(&i.ID, &i.StorageContract, &i.ProviderPubkey, &i.BagID, &i.UserAddress, &i.BagSize, &lastProofAt, &i.MaxSpan, &detectedAt,
+			&i.ReplacementPubkey, &i.ReplacementTx); err != nil {
+			return
+		}
+		if lastProofAt != nil {
+			i.LastProofAt = lastProofAt.Unix()
+		}
+		if detectedAt != nil {
+			i.DetectedAt = detectedAt.Unix()
+		}
+		incidents = append(incidents, i)
+	}
+
+	return
+}
+
+func (r *repository) SetProviderPolicy(ctx context.Context, policy db.ProviderPolicy) error {
+	query := `
+		INSERT INTO providers.policy (pubkey, status, note)
+		VALUES (lower($1), $2, $3)
+		ON CONFLICT (pubkey) DO UPDATE
+			SET status = EXCLUDED.status,
+				note = EXCLUDED.note,
+				updated_at = now()
+	`
+	_, err := r.db.Exec(ctx, query, policy.Pubkey, policy.Status, policy.Note)
+	return err
+}
+
+func (r *repository) DeleteProviderPolicy(ctx context.Context, pubkey string) (cnt int64, err error) {
+	query := `
+		DELETE FROM providers.policy
+		WHERE pubkey = lower($1)
+	`
+	row, err := r.db.Exec(ctx, query, pubkey)
+	if err != nil {
+		return
+	}
+
+	cnt = row.RowsAffected()
+
+	return
+}
+
+func (r *repository) GetProvidersPolicy(ctx context.Context) (policy []db.ProviderPolicy, err error) {
+	query := `
+		SELECT pubkey, status, note, updated_at
+		FROM providers.policy
+		ORDER BY pubkey
+	`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p db.ProviderPolicy
+		var updatedAt *time.Time
+		if err = rows.Scan(&p.Pubkey, &p.Status, &p.Note, &updatedAt); err != nil {
+			return
+		}
+		if updatedAt != nil {
+			p.UpdatedAt = updatedAt.Unix()
+		}
+		policy = append(policy, p)
+	}
+
+	return
+}
+
 func NewRepository(db *pgxpool.Pool) Repository {
 	return &repository{
 		db: db,
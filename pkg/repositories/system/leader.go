@@ -0,0 +1,182 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// Namespace of advisory locks taken by workers, the second key is a hash of the worker name
+	workersLockNamespace = 7_340_001
+	// The leader connection is pinged this often, leases are canceled as soon as a ping fails
+	leaderCheckInterval = 5 * time.Second
+	// Every query on the leader connection must finish within this time
+	leaderQueryTimeout = 5 * time.Second
+)
+
+// Leader elects a single instance per worker using session level advisory locks.
+// All locks are held on one dedicated connection, so a stopped or crashed instance releases them with it.
+type Leader interface {
+	// Lead takes the worker lock if it is free. The returned lease is canceled when the lock is lost,
+	// work done under the leadership must stop then, another instance may already be running it.
+	Lead(ctx context.Context, name string) (lease context.Context, leading bool, err error)
+}
+
+// leaderSession is a connection holding advisory locks, its context ends together with the connection
+type leaderSession struct {
+	conn     *pgxpool.Conn
+	held     map[string]struct{}
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+	dropOnce sync.Once
+}
+
+type leader struct {
+	db *pgxpool.Pool
+
+	// mu guards the session and its held locks and is never held during queries
+	mu      sync.Mutex
+	session *leaderSession
+
+	// connMu serializes queries on the session connection, a connection can't be used concurrently
+	connMu sync.Mutex
+}
+
+func (l *leader) Lead(ctx context.Context, name string) (lease context.Context, leading bool, err error) {
+	s, err := l.connect(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Locks are ours while the session is alive, the watchdog checks the connection
+	l.mu.Lock()
+	_, held := s.held[name]
+	l.mu.Unlock()
+	if held {
+		return s.ctx, s.ctx.Err() == nil, nil
+	}
+
+	var locked bool
+	l.connMu.Lock()
+	// The connection is closed under connMu only after the session is canceled
+	if s.ctx.Err() != nil {
+		l.connMu.Unlock()
+		return nil, false, context.Cause(s.ctx)
+	}
+	qctx, cancel := context.WithTimeout(ctx, leaderQueryTimeout)
+	err = s.conn.QueryRow(qctx, "SELECT pg_try_advisory_lock($1, hashtext($2))", workersLockNamespace, name).Scan(&locked)
+	cancel()
+	l.connMu.Unlock()
+
+	if err != nil {
+		err = fmt.Errorf("failed to take worker lock: %w", err)
+		l.drop(s, err)
+		return nil, false, err
+	}
+
+	if !locked {
+		return nil, false, nil
+	}
+
+	l.mu.Lock()
+	s.held[name] = struct{}{}
+	l.mu.Unlock()
+
+	return s.ctx, s.ctx.Err() == nil, nil
+}
+
+// connect returns the live session or opens a new one
+func (l *leader) connect(ctx context.Context) (*leaderSession, error) {
+	l.mu.Lock()
+	s := l.session
+	l.mu.Unlock()
+	if s != nil {
+		return s, nil
+	}
+
+	l.connMu.Lock()
+	defer l.connMu.Unlock()
+
+	// Another worker may have connected while this one waited
+	l.mu.Lock()
+	s = l.session
+	l.mu.Unlock()
+	if s != nil {
+		return s, nil
+	}
+
+	conn, err := l.db.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire leader connection: %w", err)
+	}
+
+	s = &leaderSession{
+		conn: conn,
+		held: make(map[string]struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancelCause(context.Background())
+
+	l.mu.Lock()
+	l.session = s
+	l.mu.Unlock()
+
+	go l.watch(s)
+
+	return s, nil
+}
+
+// watch pings the session connection until it fails, all leases of the session are canceled then
+func (l *leader) watch(s *leaderSession) {
+	t := time.NewTicker(leaderCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		l.connMu.Lock()
+		if s.ctx.Err() != nil {
+			l.connMu.Unlock()
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), leaderQueryTimeout)
+		err := s.conn.Ping(ctx)
+		cancel()
+		l.connMu.Unlock()
+
+		if err != nil {
+			l.drop(s, fmt.Errorf("leader connection lost: %w", err))
+			return
+		}
+	}
+}
+
+// drop cancels leases of the session and closes its connection together with all locks taken on it
+func (l *leader) drop(s *leaderSession, cause error) {
+	l.mu.Lock()
+	if l.session == s {
+		l.session = nil
+	}
+	l.mu.Unlock()
+
+	s.dropOnce.Do(func() {
+		s.cancel(cause)
+
+		l.connMu.Lock()
+		_ = s.conn.Hijack().Close(context.Background())
+		l.connMu.Unlock()
+	})
+}
+
+func NewLeader(db *pgxpool.Pool) Leader {
+	return &leader{
+		db: db,
+	}
+}
@@ -0,0 +1,157 @@
+package workspaces
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"mytonstorage-backend/pkg/models/db"
+)
+
+type metricsMiddleware struct {
+	reqCount    *prometheus.CounterVec
+	reqDuration *prometheus.HistogramVec
+	repo        Repository
+}
+
+func (m *metricsMiddleware) CreateWorkspace(ctx context.Context, name, ownerAddress string) (id int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"CreateWorkspace", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.CreateWorkspace(ctx, name, ownerAddress)
+}
+
+func (m *metricsMiddleware) GetUserWorkspaces(ctx context.Context, userAddress string) (workspaces []db.Workspace, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetUserWorkspaces", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetUserWorkspaces(ctx, userAddress)
+}
+
+func (m *metricsMiddleware) GetMemberRole(ctx context.Context, workspaceID int64, userAddress string) (role string, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetMemberRole", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetMemberRole(ctx, workspaceID, userAddress)
+}
+
+func (m *metricsMiddleware) GetMembers(ctx context.Context, workspaceID int64) (members []db.WorkspaceMember, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetMembers", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetMembers(ctx, workspaceID)
+}
+
+func (m *metricsMiddleware) InviteMember(ctx context.Context, member db.WorkspaceMember) (err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"InviteMember", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.InviteMember(ctx, member)
+}
+
+func (m *metricsMiddleware) RemoveMember(ctx context.Context, workspaceID int64, userAddress string) (res int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"RemoveMember", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.RemoveMember(ctx, workspaceID, userAddress)
+}
+
+func (m *metricsMiddleware) AcceptInvitations(ctx context.Context, userAddress string) (res int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"AcceptInvitations", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.AcceptInvitations(ctx, userAddress)
+}
+
+func (m *metricsMiddleware) AttachBag(ctx context.Context, workspaceID int64, bagID, userAddress string) (res int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"AttachBag", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.AttachBag(ctx, workspaceID, bagID, userAddress)
+}
+
+func (m *metricsMiddleware) GetWorkspaceBags(ctx context.Context, workspaceID int64) (bags []db.WorkspaceBag, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"GetWorkspaceBags", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.GetWorkspaceBags(ctx, workspaceID)
+}
+
+func (m *metricsMiddleware) RemoveWorkspaceBag(ctx context.Context, workspaceID int64, bagID string) (res int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"RemoveWorkspaceBag", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.RemoveWorkspaceBag(ctx, workspaceID, bagID)
+}
+
+func (m *metricsMiddleware) MarkWorkspaceBagAsPaid(ctx context.Context, workspaceID int64, bagID, storageContract string) (res int64, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"MarkWorkspaceBagAsPaid", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.MarkWorkspaceBagAsPaid(ctx, workspaceID, bagID, storageContract)
+}
+
+func (m *metricsMiddleware) IsWorkspaceContract(ctx context.Context, workspaceID int64, storageContract string) (res bool, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"IsWorkspaceContract", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.repo.IsWorkspaceContract(ctx, workspaceID, storageContract)
+}
+
+func NewMetrics(reqCount *prometheus.CounterVec, reqDuration *prometheus.HistogramVec, repo Repository) Repository {
+	return &metricsMiddleware{
+		reqCount:    reqCount,
+		reqDuration: reqDuration,
+		repo:        repo,
+	}
+}
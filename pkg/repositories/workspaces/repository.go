@@ -0,0 +1,304 @@
+package workspaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"mytonstorage-backend/pkg/models/db"
+	"mytonstorage-backend/pkg/repositories/lifecycle"
+)
+
+type repository struct {
+	db *pgxpool.Pool
+}
+
+type Repository interface {
+	CreateWorkspace(ctx context.Context, name, ownerAddress string) (id int64, err error)
+	GetUserWorkspaces(ctx context.Context, userAddress string) (workspaces []db.Workspace, err error)
+	GetMemberRole(ctx context.Context, workspaceID int64, userAddress string) (role string, err error)
+	GetMembers(ctx context.Context, workspaceID int64) (members []db.WorkspaceMember, err error)
+	InviteMember(ctx context.Context, member db.WorkspaceMember) error
+	RemoveMember(ctx context.Context, workspaceID int64, userAddress string) (int64, error)
+	AcceptInvitations(ctx context.Context, userAddress string) (int64, error)
+
+	AttachBag(ctx context.Context, workspaceID int64, bagID, userAddress string) (int64, error)
+	GetWorkspaceBags(ctx context.Context, workspaceID int64) (bags []db.WorkspaceBag, err error)
+	RemoveWorkspaceBag(ctx context.Context, workspaceID int64, bagID string) (int64, error)
+	MarkWorkspaceBagAsPaid(ctx context.Context, workspaceID int64, bagID, storageContract string) (int64, error)
+	IsWorkspaceContract(ctx context.Context, workspaceID int64, storageContract string) (bool, error)
+}
+
+func (r *repository) CreateWorkspace(ctx context.Context, name, ownerAddress string) (id int64, err error) {
+	query := `
+		WITH ws AS (
+			INSERT INTO files.workspaces (name, owner_address, created_at)
+			VALUES ($1, $2, NOW())
+			RETURNING id
+		), owner AS (
+			INSERT INTO files.workspace_members (workspace_id, user_address, role, invited_by, invited_at, accepted_at)
+			SELECT id, $2, 'owner', $2, NOW(), NOW()
+			FROM ws
+		)
+		SELECT id FROM ws;
+	`
+	err = r.db.QueryRow(ctx, query, name, ownerAddress).Scan(&id)
+	return
+}
+
+func (r *repository) GetUserWorkspaces(ctx context.Context, userAddress string) (workspaces []db.Workspace, err error) {
+	query := `
+		SELECT w.id, w.name, w.owner_address, m.role, w.created_at
+		FROM files.workspace_members m
+			JOIN files.workspaces w ON w.id = m.workspace_id
+		WHERE m.user_address = $1 AND m.accepted_at IS NOT NULL
+		ORDER BY w.id
+	`
+	rows, err := r.db.Query(ctx, query, userAddress)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w db.Workspace
+		var createdAt *time.Time
+		if err = rows.Scan(&w.ID, &w.Name, &w.OwnerAddress, &w.Role, &createdAt); err != nil {
+			return
+		}
+		if createdAt != nil {
+			w.CreatedAt = createdAt.Unix()
+		}
+		workspaces = append(workspaces, w)
+	}
+
+	return
+}
+
+func (r *repository) GetMemberRole(ctx context.Context, workspaceID int64, userAddress string) (role string, err error) {
+	query := `
+		SELECT role
+		FROM files.workspace_members
+		WHERE workspace_id = $1 AND user_address = $2 AND accepted_at IS NOT NULL
+	`
+	rows, err := r.db.Query(ctx, query, workspaceID, userAddress)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		if err = rows.Scan(&role); err != nil {
+			return
+		}
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+func (r *repository) GetMembers(ctx context.Context, workspaceID int64) (members []db.WorkspaceMember, err error) {
+	query := `
+		SELECT workspace_id, user_address, role, invited_by, invited_at, accepted_at
+		FROM files.workspace_members
+		WHERE workspace_id = $1
+		ORDER BY invited_at
+	`
+	rows, err := r.db.Query(ctx, query, workspaceID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m db.WorkspaceMember
+		var invitedAt, acceptedAt *time.Time
+		if err = rows.Scan(&m.WorkspaceID, &m.UserAddress, &m.Role, &m.InvitedBy, &invitedAt, &acceptedAt); err != nil {
+			return
+		}
+		if invitedAt != nil {
+			m.InvitedAt = invitedAt.Unix()
+		}
+		if acceptedAt != nil {
+			m.AcceptedAt = acceptedAt.Unix()
+		}
+		members = append(members, m)
+	}
+
+	return
+}
+
+func (r *repository) InviteMember(ctx context.Context, member db.WorkspaceMember) error {
+	query := `
+		INSERT INTO files.workspace_members (workspace_id, user_address, role, invited_by, invited_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (workspace_id, user_address) DO UPDATE
+			SET role = EXCLUDED.role
+	`
+	_, err := r.db.Exec(ctx, query, member.WorkspaceID, member.UserAddress, member.Role, member.InvitedBy)
+	return err
+}
+
+func (r *repository) RemoveMember(ctx context.Context, workspaceID int64, userAddress string) (cnt int64, err error) {
+	query := `
+		DELETE FROM files.workspace_members
+		WHERE workspace_id = $1 AND user_address = $2 AND role <> 'owner'
+	`
+	row, err := r.db.Exec(ctx, query, workspaceID, userAddress)
+	if err != nil {
+		return
+	}
+
+	cnt = row.RowsAffected()
+
+	return
+}
+
+func (r *repository) AcceptInvitations(ctx context.Context, userAddress string) (cnt int64, err error) {
+	query := `
+		UPDATE files.workspace_members
+		SET accepted_at = NOW()
+		WHERE user_address = $1 AND accepted_at IS NULL
+	`
+	row, err := r.db.Exec(ctx, query, userAddress)
+	if err != nil {
+		return
+	}
+
+	cnt = row.RowsAffected()
+
+	return
+}
+
+func (r *repository) AttachBag(ctx context.Context, workspaceID int64, bagID, userAddress string) (cnt int64, err error) {
+	query := `
+		UPDATE files.bag_users
+		SET workspace_id = $1,
+			updated_at = NOW()
+		WHERE bagid = $2 AND user_address = $3
+	`
+	row, err := r.db.Exec(ctx, query, workspaceID, bagID, userAddress)
+	if err != nil {
+		return
+	}
+
+	cnt = row.RowsAffected()
+
+	return
+}
+
+func (r *repository) GetWorkspaceBags(ctx context.Context, workspaceID int64) (bags []db.WorkspaceBag, err error) {
+	query := `
+		SELECT bu.bagid, bu.user_address, COALESCE(bu.storage_contract, ''), b.description, b.size, bu.created_at
+		FROM files.bag_users bu
+			JOIN files.bags b ON b.bagid = bu.bagid
+		WHERE bu.workspace_id = $1
+		ORDER BY bu.created_at DESC
+	`
+	rows, err := r.db.Query(ctx, query, workspaceID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bag db.WorkspaceBag
+		var createdAt *time.Time
+		if err = rows.Scan(&bag.BagID, &bag.UserAddress, &bag.StorageContract, &bag.Description, &bag.Size, &createdAt); err != nil {
+			return
+		}
+		if createdAt != nil {
+			bag.CreatedAt = createdAt.Unix()
+		}
+		bags = append(bags, bag)
+	}
+
+	return
+}
+
+func (r *repository) RemoveWorkspaceBag(ctx context.Context, workspaceID int64, bagID string) (cnt int64, err error) {
+	query := `
+		DELETE FROM files.bag_users
+		WHERE workspace_id = $1 AND bagid = $2
+	`
+	row, err := r.db.Exec(ctx, query, workspaceID, bagID)
+	if err != nil {
+		return
+	}
+
+	cnt = row.RowsAffected()
+
+	return
+}
+
+func (r *repository) MarkWorkspaceBagAsPaid(ctx context.Context, workspaceID int64, bagID, storageContract string) (cnt int64, err error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	query := `
+		UPDATE files.bag_users
+		SET storage_contract = $3
+		WHERE workspace_id = $1 AND bagid = $2
+		RETURNING user_address
+	`
+	rows, err := tx.Query(ctx, query, workspaceID, bagID, storageContract)
+	if err != nil {
+		return
+	}
+
+	var transitions []db.BagTransition
+	for rows.Next() {
+		t := db.BagTransition{
+			BagID:  bagID,
+			State:  db.BagStatePaid,
+			Reason: "storage contract " + storageContract,
+		}
+		if err = rows.Scan(&t.UserAddress); err != nil {
+			rows.Close()
+			return
+		}
+		transitions = append(transitions, t)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil || len(transitions) == 0 {
+		return
+	}
+
+	// Contract of an already paid bag can be replaced, state stays the same then
+	if _, err = lifecycle.TransitBags(ctx, tx, transitions); err != nil {
+		return
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return
+	}
+
+	cnt = int64(len(transitions))
+
+	return
+}
+
+func (r *repository) IsWorkspaceContract(ctx context.Context, workspaceID int64, storageContract string) (exists bool, err error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM files.bag_users
+			WHERE workspace_id = $1 AND storage_contract = $2
+		)
+	`
+	err = r.db.QueryRow(ctx, query, workspaceID, storageContract).Scan(&exists)
+	return
+}
+
+func NewRepository(db *pgxpool.Pool) Repository {
+	return &repository{
+		db: db,
+	}
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// signingKey is a single key of the keyring. Zero expiresAt means the key never expires.
+type signingKey struct {
+	id        string
+	key       ed25519.PrivateKey
+	expiresAt time.Time
+}
+
+// Keyring holds one active key used to sign new sessions and
+// any number of older keys still accepted for verification until they expire.
+type Keyring struct {
+	mu     sync.RWMutex
+	active string
+	keys   map[string]signingKey
+}
+
+func validateKeyID(id string) error {
+	if id == "" {
+		return fmt.Errorf("empty key id")
+	}
+
+	if strings.ContainsAny(id, ":.,") {
+		return fmt.Errorf("key id %q contains forbidden characters", id)
+	}
+
+	return nil
+}
+
+// AddVerificationKey registers a key that is accepted for verification only.
+func (k *Keyring) AddVerificationKey(id string, key ed25519.PrivateKey, expiresAt time.Time) error {
+	if err := validateKeyID(id); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, exists := k.keys[id]; exists {
+		return fmt.Errorf("duplicate key id %q", id)
+	}
+
+	k.keys[id] = signingKey{id: id, key: key, expiresAt: expiresAt}
+
+	return nil
+}
+
+// Sign signs data with the active key and returns the key id used.
+func (k *Keyring) Sign(data []byte) (id string, signature []byte) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	active := k.keys[k.active]
+
+	return active.id, ed25519.Sign(active.key, data)
+}
+
+// Verify checks the signature with the key identified by id.
+// Unknown and expired keys never verify.
+func (k *Keyring) Verify(id string, data, signature []byte) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	sk, ok := k.keys[id]
+	if !ok {
+		return false
+	}
+
+	if !sk.expiresAt.IsZero() && time.Now().After(sk.expiresAt) {
+		return false
+	}
+
+	return ed25519.Verify(sk.key.Public().(ed25519.PublicKey), data, signature)
+}
+
+// VerifyAny checks the signature against every non-expired key.
+// Used for sessions issued before signatures carried a key id.
+func (k *Keyring) VerifyAny(data, signature []byte) bool {
+	k.mu.RLock()
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	k.mu.RUnlock()
+
+	for _, id := range ids {
+		if k.Verify(id, data, signature) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func NewKeyring(activeID string, active ed25519.PrivateKey) (*Keyring, error) {
+	if err := validateKeyID(activeID); err != nil {
+		return nil, err
+	}
+
+	return &Keyring{
+		active: activeID,
+		keys: map[string]signingKey{
+			activeID: {id: activeID, key: active},
+		},
+	}, nil
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+)
+
+func testKey(b byte) ed25519.PrivateKey {
+	seed := make([]byte, ed25519.SeedSize)
+	seed[0] = b
+	return ed25519.NewKeyFromSeed(seed)
+}
+
+// Sessions signed before a rotation stay valid until the old key expires
+func TestKeyringRotation(t *testing.T) {
+	data := []byte("1700000000:0:abc")
+
+	old, _ := NewKeyring("old", testKey(1))
+	oldID, oldSig := old.Sign(data)
+
+	expired, _ := NewKeyring("expired", testKey(3))
+	expiredID, expiredSig := expired.Sign(data)
+
+	k, err := NewKeyring("new", testKey(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = k.AddVerificationKey("old", testKey(1), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err = k.AddVerificationKey("expired", testKey(3), time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err = k.AddVerificationKey("old", testKey(4), time.Time{}); err == nil {
+		t.Fatal("duplicate key id accepted")
+	}
+
+	newID, newSig := k.Sign(data)
+	if newID != "new" {
+		t.Fatalf("signed with %q, want the active key", newID)
+	}
+
+	tests := []struct {
+		name      string
+		id        string
+		signature []byte
+		valid     bool
+		validAny  bool
+	}{
+		{"active key", newID, newSig, true, true},
+		{"rotated key", oldID, oldSig, true, true},
+		{"expired key", expiredID, expiredSig, false, false},
+		{"signature of another key", oldID, newSig, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := k.Verify(tt.id, data, tt.signature); got != tt.valid {
+				t.Errorf("Verify() = %v, want %v", got, tt.valid)
+			}
+
+			if got := k.VerifyAny(data, tt.signature); got != tt.validAny {
+				t.Errorf("VerifyAny() = %v, want %v", got, tt.validAny)
+			}
+		})
+	}
+}
@@ -2,7 +2,6 @@ package auth
 
 import (
 	"context"
-	"crypto/ed25519"
 	"encoding/hex"
 	"fmt"
 	"log/slog"
@@ -14,13 +13,19 @@ import (
 
 	"mytonstorage-backend/pkg/models"
 	v1 "mytonstorage-backend/pkg/models/api/v1"
+	"mytonstorage-backend/pkg/utils"
 )
 
+type workspacesDb interface {
+	AcceptInvitations(ctx context.Context, userAddress string) (int64, error)
+}
+
 type service struct {
-	verifier *wallet.TonConnectVerifier
-	key      ed25519.PrivateKey
-	host     string
-	logger   *slog.Logger
+	verifier   *wallet.TonConnectVerifier
+	keys       *Keyring
+	workspaces workspacesDb
+	host       string
+	logger     *slog.Logger
 }
 
 type Auth interface {
@@ -52,24 +57,52 @@ func (s *service) Login(ctx context.Context, info v1.LoginInfo) (sessionID strin
 		return
 	}
 
-	timestamp := time.Now().Unix()
-	sessionData := fmt.Sprintf("%d:%s", timestamp, addr.String())
-	signature := ed25519.Sign(s.key, []byte(sessionData))
-	sessionID = fmt.Sprintf("%x:%s", signature, sessionData)
+	sessionID = s.signSession(addr, time.Now())
+
+	// Logging in with the invited wallet accepts all pending workspace invitations
+	accepted, aErr := s.workspaces.AcceptInvitations(ctx, utils.UserAddress(addr))
+	if aErr != nil {
+		logger.Error("failed to accept workspace invitations", slog.Any("error", aErr))
+	} else if accepted > 0 {
+		logger.Info("workspace invitations accepted", slog.Int64("count", accepted))
+	}
 
 	// todo: save to db
 
 	return
 }
 
+// signSession returns session id in the "<key id>.<hex signature>:<timestamp>:<address>" form
+func (s *service) signSession(addr *address.Address, at time.Time) string {
+	sessionData := fmt.Sprintf("%d:%s", at.Unix(), utils.UserAddress(addr))
+	keyID, signature := s.keys.Sign([]byte(sessionData))
+
+	return fmt.Sprintf("%s.%x:%s", keyID, signature, sessionData)
+}
+
 func (s *service) Authenticate(ctx context.Context, signature, sessionData string) (addr string, err error) {
 	logger := s.logger.With(
 		slog.String("method", "Authenticate"),
 	)
 
+	// Signature is "<key id>.<hex>", sessions issued before key rotation have no key id
+	keyID, sigHex, hasKeyID := strings.Cut(signature, ".")
+	if !hasKeyID {
+		sigHex = keyID
+	}
+
 	signedMessage := []byte(sessionData)
-	sigBytes, err := hex.DecodeString(signature)
-	if err != nil || !ed25519.Verify(s.key.Public().(ed25519.PublicKey), signedMessage, sigBytes) {
+	sigBytes, err := hex.DecodeString(sigHex)
+	valid := err == nil
+	if valid {
+		if hasKeyID {
+			valid = s.keys.Verify(keyID, signedMessage, sigBytes)
+		} else {
+			valid = s.keys.VerifyAny(signedMessage, sigBytes)
+		}
+	}
+
+	if !valid {
 		logger.Error("failed to verify signature", slog.Any("error", err))
 		err = models.NewAppError(models.UnauthorizedErrorCode, "invalid signature")
 		return
@@ -89,11 +122,11 @@ func (s *service) Authenticate(ctx context.Context, signature, sessionData strin
 		return
 	}
 
-	addr = a.String()
+	addr = utils.UserAddress(a)
 
 	return
 }
 
-func New(verifier *wallet.TonConnectVerifier, key ed25519.PrivateKey, host string, logger *slog.Logger) Auth {
-	return &service{verifier: verifier, key: key, host: host, logger: logger}
+func New(verifier *wallet.TonConnectVerifier, keys *Keyring, workspaces workspacesDb, host string, logger *slog.Logger) Auth {
+	return &service{verifier: verifier, keys: keys, workspaces: workspaces, host: host, logger: logger}
 }
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+
+	"mytonstorage-backend/pkg/utils"
+)
+
+// Addresses typed by users must come out in the same form as the address of the wallet's session,
+// otherwise transfers, invitations and self checks never match the session user
+func TestSessionAddressMatchesNormalizedAddress(t *testing.T) {
+	keys, err := NewKeyring("k1", testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &service{keys: keys, logger: slog.New(slog.DiscardHandler)}
+
+	data := make([]byte, 32)
+	for i := range data {
+		data[i] = byte(i + 1)
+	}
+	wallet := address.NewAddress(0, 0, data)
+
+	// TonConnect sends the raw form on login
+	raw, err := address.ParseRawAddr(wallet.StringRaw())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature, sessionData, _ := strings.Cut(s.signSession(raw, time.Now()), ":")
+	sessionAddr, err := s.Authenticate(context.Background(), signature, sessionData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Issued sessions and stored relations already carry this form, it must not change
+	if sessionAddr != raw.String() {
+		t.Fatalf("session address = %s, want %s", sessionAddr, raw.String())
+	}
+
+	tests := []struct {
+		name string
+		addr string
+	}{
+		{"raw", wallet.StringRaw()},
+		{"bounceable", wallet.Bounce(true).String()},
+		{"non-bounceable", wallet.Bounce(false).String()},
+		{"testnet bounceable", wallet.Bounce(true).Testnet(true).String()},
+		{"testnet non-bounceable", wallet.Bounce(false).Testnet(true).String()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := utils.NormalizeUserAddress(tt.addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != sessionAddr {
+				t.Errorf("NormalizeUserAddress(%s) = %s, session address is %s", tt.addr, got, sessionAddr)
+			}
+		})
+	}
+}
@@ -18,6 +18,10 @@ func (c *cacheMiddleware) AddFiles(ctx context.Context, mr *multipart.Reader, si
 	return c.svc.AddFiles(ctx, mr, size, userAddr)
 }
 
+func (c *cacheMiddleware) DrainUploads(ctx context.Context) error {
+	return c.svc.DrainUploads(ctx)
+}
+
 func (c *cacheMiddleware) DeleteBag(ctx context.Context, bagID string, userAddr string) (err error) {
 	err = c.svc.DeleteBag(ctx, bagID, userAddr)
 	if err != nil {
@@ -42,6 +46,42 @@ func (c *cacheMiddleware) GetBagsInfoShort(ctx context.Context, contracts []stri
 	return c.svc.GetBagsInfoShort(ctx, contracts)
 }
 
+func (c *cacheMiddleware) GetBagReplication(ctx context.Context, bagID, userAddr string) (resp v1.BagReplicationResponse, err error) {
+	return c.svc.GetBagReplication(ctx, bagID, userAddr)
+}
+
+func (c *cacheMiddleware) GetBagStateHistory(ctx context.Context, bagID string) (resp v1.BagStateHistoryResponse, err error) {
+	return c.svc.GetBagStateHistory(ctx, bagID)
+}
+
+func (c *cacheMiddleware) GetBagRetention(ctx context.Context, bagID string) (resp v1.BagRetentionResponse, err error) {
+	return c.svc.GetBagRetention(ctx, bagID)
+}
+
+func (c *cacheMiddleware) PinBag(ctx context.Context, bagID string, req v1.PinBagRequest) error {
+	return c.svc.PinBag(ctx, bagID, req)
+}
+
+func (c *cacheMiddleware) UnpinBag(ctx context.Context, bagID string) error {
+	return c.svc.UnpinBag(ctx, bagID)
+}
+
+func (c *cacheMiddleware) ProposeBagTransfer(ctx context.Context, userAddr string, req v1.ProposeBagTransferRequest) error {
+	return c.svc.ProposeBagTransfer(ctx, userAddr, req)
+}
+
+func (c *cacheMiddleware) GetBagTransfers(ctx context.Context, userAddr string) (resp v1.BagTransfersResponse, err error) {
+	return c.svc.GetBagTransfers(ctx, userAddr)
+}
+
+func (c *cacheMiddleware) CancelBagTransfer(ctx context.Context, bagID, userAddr string) error {
+	return c.svc.CancelBagTransfer(ctx, bagID, userAddr)
+}
+
+func (c *cacheMiddleware) AcceptBagTransfer(ctx context.Context, userAddr string, req v1.AcceptBagTransferRequest) error {
+	return c.svc.AcceptBagTransfer(ctx, userAddr, req)
+}
+
 func NewCacheMiddleware(
 	svc Files,
 ) Files {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"mime"
 	"mime/multipart"
 	"os"
@@ -22,10 +23,13 @@ import (
 	"mytonstorage-backend/pkg/models"
 	v1 "mytonstorage-backend/pkg/models/api/v1"
 	"mytonstorage-backend/pkg/models/db"
+	"mytonstorage-backend/pkg/utils"
 )
 
 const (
 	descriptionsStoreLimit = 1000
+	bagTransferLifetime    = 7 * 24 * time.Hour
+	maxPinNoteLen          = 1024
 )
 
 type service struct {
@@ -35,6 +39,7 @@ type service struct {
 	storageDir              string
 	totalDiskSpaceAvailable uint64
 	unpaidFilesLifetime     time.Duration
+	uploads                 *uploads
 	logger                  *slog.Logger
 }
 
@@ -59,6 +64,16 @@ type filesDb interface {
 	IncreaseAttempts(ctx context.Context, bags []db.BagStorageContract) error
 	MarkBagAsPaid(ctx context.Context, bagID, userAddress, storageContract string) (cnt int64, err error)
 	GetBagsInfoShort(ctx context.Context, contracts []string) (info []db.BagDescription, err error)
+	GetBagStateHistory(ctx context.Context, bagID string) ([]db.BagStateChange, error)
+	GetBagReplication(ctx context.Context, bagID, userAddress string) (owned bool, replication []db.ProviderReplication, err error)
+	PinBag(ctx context.Context, bagID, note string) error
+	UnpinBag(ctx context.Context, bagID string) (int64, error)
+	GetBagRetention(ctx context.Context, bagID string) (pin *db.BagPin, decision *db.RetentionDecision, err error)
+
+	ProposeBagTransfer(ctx context.Context, bagID, fromAddress, toAddress string) (int64, error)
+	GetBagTransfers(ctx context.Context, userAddress string, sec uint64) ([]db.BagTransfer, error)
+	CancelBagTransfer(ctx context.Context, bagID, fromAddress string) (int64, error)
+	AcceptBagTransfer(ctx context.Context, bagID, fromAddress, toAddress string, sec uint64) (int64, error)
 }
 
 type Files interface {
@@ -67,6 +82,18 @@ type Files interface {
 	MarkBagAsPaid(ctx context.Context, bagID, userAddress, storageContract string) (err error)
 	GetUnpaidBags(ctx context.Context, userAddr string) (info v1.UnpaidBagsResponse, err error)
 	GetBagsInfoShort(ctx context.Context, contracts []string) (info []v1.BagInfoShort, err error)
+	GetBagStateHistory(ctx context.Context, bagID string) (resp v1.BagStateHistoryResponse, err error)
+	GetBagReplication(ctx context.Context, bagID, userAddr string) (resp v1.BagReplicationResponse, err error)
+	GetBagRetention(ctx context.Context, bagID string) (resp v1.BagRetentionResponse, err error)
+	PinBag(ctx context.Context, bagID string, req v1.PinBagRequest) error
+	UnpinBag(ctx context.Context, bagID string) error
+
+	ProposeBagTransfer(ctx context.Context, userAddr string, req v1.ProposeBagTransferRequest) error
+	GetBagTransfers(ctx context.Context, userAddr string) (resp v1.BagTransfersResponse, err error)
+	CancelBagTransfer(ctx context.Context, bagID, userAddr string) error
+	AcceptBagTransfer(ctx context.Context, userAddr string, req v1.AcceptBagTransferRequest) error
+
+	DrainUploads(ctx context.Context) error
 }
 
 func (s *service) AddFiles(ctx context.Context, mr *multipart.Reader, size uint64, userAddr string) (bagid string, err error) {
@@ -76,6 +103,12 @@ func (s *service) AddFiles(ctx context.Context, mr *multipart.Reader, size uint6
 		slog.String("user_address", userAddr),
 	)
 
+	if !s.uploads.begin() {
+		err = models.NewAppError(models.ServiceUnavailableCode, "server is shutting down")
+		return
+	}
+	defer s.uploads.end()
+
 	// Check paids
 	canUpload, err := s.files.CanUpload(ctx, userAddr, uint64(s.unpaidFilesLifetime.Seconds()))
 	if err != nil {
@@ -118,6 +151,8 @@ func (s *service) AddFiles(ctx context.Context, mr *multipart.Reader, size uint6
 		return
 	}
 
+	s.uploads.track(dstPath)
+
 	// Remove the directory if handling an error
 	defer func() {
 		if err != nil {
@@ -125,6 +160,8 @@ func (s *service) AddFiles(ctx context.Context, mr *multipart.Reader, size uint6
 				log.Error("Failed to remove directory after error", slog.Any("error", rmErr))
 			}
 		}
+
+		s.uploads.untrack(dstPath)
 	}()
 
 	// Parse multipart to disk
@@ -340,12 +377,315 @@ func (s *service) GetBagsInfoShort(ctx context.Context, contracts []string) (inf
 			BagID:           d.BagID,
 			Description:     d.Description,
 			Size:            d.Size,
+			State:           d.State,
 		})
 	}
 
 	return info, nil
 }
 
+func (s *service) GetBagReplication(ctx context.Context, bagID, userAddr string) (resp v1.BagReplicationResponse, err error) {
+	log := s.logger.With(
+		slog.String("method", "GetBagReplication"),
+		slog.String("bag_id", bagID),
+		slog.String("user_address", userAddr),
+	)
+
+	owned, replication, err := s.files.GetBagReplication(ctx, bagID, userAddr)
+	if err != nil {
+		log.Error("Failed to get bag replication", "error", err)
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	if !owned {
+		err = models.NewAppError(models.NotFoundErrorCode, "bag not found")
+		return
+	}
+
+	resp.BagID = bagID
+	resp.Contracts = []v1.ContractReplication{}
+	for _, r := range replication {
+		if len(resp.Contracts) == 0 || resp.Contracts[len(resp.Contracts)-1].StorageContract != r.StorageContract {
+			resp.Contracts = append(resp.Contracts, v1.ContractReplication{StorageContract: r.StorageContract})
+		}
+
+		progress := 0.0
+		if r.Size > 0 {
+			progress = math.Min(float64(r.Downloaded)/float64(r.Size)*100, 100)
+		}
+
+		c := &resp.Contracts[len(resp.Contracts)-1]
+		c.Providers = append(c.Providers, v1.ProviderReplication{
+			Pubkey:         r.ProviderPubkey,
+			Notified:       r.Notified,
+			NotifyAttempts: r.NotifyAttempts,
+			DownloadChecks: r.DownloadChecks,
+			Size:           r.Size,
+			Downloaded:     r.Downloaded,
+			Progress:       math.Round(progress*100) / 100,
+			LastCheckAt:    r.LastCheckAt,
+			DownloadedAt:   r.DownloadedAt,
+			Outcome:        replicationOutcome(r),
+			Archived:       r.Archived,
+		})
+	}
+
+	return
+}
+
+// replicationOutcome is final for archived notifications, live ones are still in progress
+func replicationOutcome(r db.ProviderReplication) string {
+	switch {
+	case r.Size > 0 && r.Downloaded >= r.Size:
+		return v1.ReplicationDownloaded
+	case !r.Archived && !r.Notified:
+		return v1.ReplicationPending
+	case !r.Archived:
+		return v1.ReplicationDownloading
+	case !r.Notified:
+		return v1.ReplicationNotifyFailed
+	default:
+		return v1.ReplicationDownloadFailed
+	}
+}
+
+func (s *service) GetBagStateHistory(ctx context.Context, bagID string) (resp v1.BagStateHistoryResponse, err error) {
+	log := s.logger.With(
+		slog.String("method", "GetBagStateHistory"),
+		slog.String("bag_id", bagID),
+	)
+
+	changes, err := s.files.GetBagStateHistory(ctx, bagID)
+	if err != nil {
+		log.Error("Failed to get bag state history", "error", err)
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	if len(changes) == 0 {
+		err = models.NewAppError(models.NotFoundErrorCode, "bag not found")
+		return
+	}
+
+	resp.BagID = bagID
+	resp.Changes = make([]v1.BagStateChange, 0, len(changes))
+	for _, c := range changes {
+		resp.Changes = append(resp.Changes, v1.BagStateChange{
+			UserAddress:     c.UserAddress,
+			StorageContract: c.StorageContract,
+			FromState:       c.FromState,
+			ToState:         c.ToState,
+			Reason:          c.Reason,
+			CreatedAt:       c.CreatedAt,
+		})
+	}
+
+	return
+}
+
+func (s *service) GetBagRetention(ctx context.Context, bagID string) (resp v1.BagRetentionResponse, err error) {
+	log := s.logger.With(
+		slog.String("method", "GetBagRetention"),
+		slog.String("bag_id", bagID),
+	)
+
+	pin, decision, err := s.files.GetBagRetention(ctx, bagID)
+	if err != nil {
+		log.Error("Failed to get bag retention", "error", err)
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	if pin == nil && decision == nil {
+		err = models.NewAppError(models.NotFoundErrorCode, "bag has no retention decision")
+		return
+	}
+
+	resp.BagID = bagID
+	if pin != nil {
+		resp.Pinned = true
+		resp.PinNote = pin.Note
+		resp.PinnedAt = pin.CreatedAt
+	}
+
+	if decision != nil {
+		resp.Decision = &v1.RetentionDecision{
+			Decision:  decision.Decision,
+			Reason:    decision.Reason,
+			Replicas:  decision.Replicas,
+			Proofs:    decision.Proofs,
+			DecidedAt: decision.DecidedAt,
+		}
+	}
+
+	return
+}
+
+// PinBag keeps staged data of the bag on the node regardless of the retention policy
+func (s *service) PinBag(ctx context.Context, bagID string, req v1.PinBagRequest) (err error) {
+	log := s.logger.With(
+		slog.String("method", "PinBag"),
+		slog.String("bag_id", bagID),
+	)
+
+	if len(req.Note) > maxPinNoteLen {
+		err = models.NewAppError(models.BadRequestErrorCode, "note is too long")
+		return
+	}
+
+	if err = s.files.PinBag(ctx, bagID, req.Note); err != nil {
+		log.Error("Failed to pin bag", "error", err)
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	log.Info("Bag pinned")
+
+	return
+}
+
+func (s *service) UnpinBag(ctx context.Context, bagID string) (err error) {
+	log := s.logger.With(
+		slog.String("method", "UnpinBag"),
+		slog.String("bag_id", bagID),
+	)
+
+	cnt, err := s.files.UnpinBag(ctx, bagID)
+	if err != nil {
+		log.Error("Failed to unpin bag", "error", err)
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	if cnt == 0 {
+		err = models.NewAppError(models.NotFoundErrorCode, "bag is not pinned")
+		return
+	}
+
+	log.Info("Bag unpinned")
+
+	return
+}
+
+func (s *service) ProposeBagTransfer(ctx context.Context, userAddr string, req v1.ProposeBagTransferRequest) error {
+	log := s.logger.With(
+		slog.String("method", "ProposeBagTransfer"),
+		slog.String("bag_id", req.BagID),
+		slog.String("from_address", userAddr),
+		slog.String("to_address", req.ToAddress),
+	)
+
+	toAddr, err := utils.NormalizeUserAddress(req.ToAddress)
+	if err != nil {
+		return models.NewAppError(models.BadRequestErrorCode, "invalid address")
+	}
+
+	if toAddr == userAddr {
+		return models.NewAppError(models.BadRequestErrorCode, "can't transfer bag to yourself")
+	}
+
+	cnt, err := s.files.ProposeBagTransfer(ctx, req.BagID, userAddr, toAddr)
+	if err != nil {
+		log.Error("Failed to propose bag transfer", "error", err)
+		return models.NewAppError(models.InternalServerErrorCode, "")
+	}
+
+	if cnt == 0 {
+		return models.NewAppError(models.NotFoundErrorCode, "bag not found")
+	}
+
+	log.Info("Bag transfer proposed")
+
+	return nil
+}
+
+func (s *service) GetBagTransfers(ctx context.Context, userAddr string) (resp v1.BagTransfersResponse, err error) {
+	log := s.logger.With(
+		slog.String("method", "GetBagTransfers"),
+		slog.String("user_address", userAddr),
+	)
+
+	transfers, err := s.files.GetBagTransfers(ctx, userAddr, uint64(bagTransferLifetime.Seconds()))
+	if err != nil {
+		log.Error("Failed to get bag transfers", "error", err)
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	resp.Incoming = make([]v1.BagTransfer, 0)
+	resp.Outgoing = make([]v1.BagTransfer, 0)
+	for _, t := range transfers {
+		transfer := v1.BagTransfer{
+			BagID:       t.BagID,
+			FromAddress: t.FromAddress,
+			ToAddress:   t.ToAddress,
+			Description: t.Description,
+			Size:        t.Size,
+			CreatedAt:   t.CreatedAt,
+			ExpiresAt:   t.CreatedAt + int64(bagTransferLifetime.Seconds()),
+		}
+
+		if t.FromAddress == userAddr {
+			resp.Outgoing = append(resp.Outgoing, transfer)
+		} else {
+			resp.Incoming = append(resp.Incoming, transfer)
+		}
+	}
+
+	return
+}
+
+func (s *service) CancelBagTransfer(ctx context.Context, bagID, userAddr string) error {
+	log := s.logger.With(
+		slog.String("method", "CancelBagTransfer"),
+		slog.String("bag_id", bagID),
+		slog.String("from_address", userAddr),
+	)
+
+	cnt, err := s.files.CancelBagTransfer(ctx, bagID, userAddr)
+	if err != nil {
+		log.Error("Failed to cancel bag transfer", "error", err)
+		return models.NewAppError(models.InternalServerErrorCode, "")
+	}
+
+	if cnt == 0 {
+		return models.NewAppError(models.NotFoundErrorCode, "transfer not found")
+	}
+
+	log.Info("Bag transfer canceled")
+
+	return nil
+}
+
+func (s *service) AcceptBagTransfer(ctx context.Context, userAddr string, req v1.AcceptBagTransferRequest) error {
+	log := s.logger.With(
+		slog.String("method", "AcceptBagTransfer"),
+		slog.String("bag_id", req.BagID),
+		slog.String("from_address", req.FromAddress),
+		slog.String("to_address", userAddr),
+	)
+
+	fromAddr, err := utils.NormalizeUserAddress(req.FromAddress)
+	if err != nil {
+		return models.NewAppError(models.BadRequestErrorCode, "invalid address")
+	}
+
+	cnt, err := s.files.AcceptBagTransfer(ctx, req.BagID, fromAddr, userAddr, uint64(bagTransferLifetime.Seconds()))
+	if err != nil {
+		log.Error("Failed to accept bag transfer", "error", err)
+		return models.NewAppError(models.InternalServerErrorCode, "")
+	}
+
+	if cnt == 0 {
+		return models.NewAppError(models.NotFoundErrorCode, "transfer not found or expired")
+	}
+
+	log.Info("Bag transferred successfully")
+
+	return nil
+}
+
 func (s *service) saveToTONStorage(ctx context.Context, path, description string, log *slog.Logger) (info *tonstorage.BagDetailed, err error) {
 	// Save file(s) to TON Storage
 	bagid, err := s.tonstorage.Create(ctx, description, path)
@@ -382,6 +722,7 @@ func NewService(
 		storageDir:              storageDir,
 		totalDiskSpaceAvailable: totalDiskSpaceAvailable,
 		unpaidFilesLifetime:     unpaidFilesLifetime,
+		uploads:                 &uploads{dirs: make(map[string]struct{})},
 		logger:                  logger,
 	}
 }
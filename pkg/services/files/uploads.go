@@ -0,0 +1,86 @@
+package files
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// uploads tracks upload directories being written, so shutdown can wait for them and remove unfinished ones
+type uploads struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	draining bool
+	dirs     map[string]struct{}
+}
+
+// begin registers an upload, it fails once draining started
+func (u *uploads) begin() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.draining {
+		return false
+	}
+
+	u.wg.Add(1)
+
+	return true
+}
+
+func (u *uploads) track(dir string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.dirs[dir] = struct{}{}
+}
+
+func (u *uploads) untrack(dir string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	delete(u.dirs, dir)
+}
+
+func (u *uploads) end() {
+	u.wg.Done()
+}
+
+// DrainUploads declines new uploads and waits for running ones until ctx is done.
+// Directories of uploads that did not finish in time are removed.
+func (s *service) DrainUploads(ctx context.Context) (err error) {
+	log := s.logger.With(slog.String("method", "DrainUploads"))
+
+	s.uploads.mu.Lock()
+	s.uploads.draining = true
+	s.uploads.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		s.uploads.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		log.Info("all uploads finished")
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.uploads.mu.Lock()
+	defer s.uploads.mu.Unlock()
+
+	for dir := range s.uploads.dirs {
+		if rmErr := os.RemoveAll(dir); rmErr != nil {
+			log.Error("failed to remove partial upload", slog.String("dir", dir), slog.String("error", rmErr.Error()))
+			continue
+		}
+
+		log.Warn("removed partial upload", slog.String("dir", dir))
+	}
+
+	return
+}
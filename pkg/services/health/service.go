@@ -0,0 +1,151 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/xssnick/tonutils-go/ton"
+
+	tonstorage "mytonstorage-backend/pkg/clients/ton-storage"
+	v1 "mytonstorage-backend/pkg/models/api/v1"
+)
+
+// Every dependency must answer within this time to be considered reachable
+const checkTimeout = 5 * time.Second
+
+type database interface {
+	Ping(ctx context.Context) error
+}
+
+type storage interface {
+	List(ctx context.Context) (*tonstorage.ListShort, error)
+}
+
+type liteservers interface {
+	GetMasterchainInfo(ctx context.Context) (*ton.BlockIDExt, error)
+}
+
+type workers interface {
+	GetWorkers(ctx context.Context) (workers []v1.WorkerStatus, err error)
+}
+
+type service struct {
+	db              database
+	storage         storage
+	liteservers     liteservers
+	workers         workers
+	criticalWorkers []string
+	window          time.Duration
+	logger          *slog.Logger
+}
+
+type Health interface {
+	Readiness(ctx context.Context) (resp v1.ReadinessResponse)
+}
+
+// Readiness checks dependencies in parallel and makes sure critical workers succeeded recently.
+// Workers led by other instances and paused workers are not checked.
+func (s *service) Readiness(ctx context.Context) (resp v1.ReadinessResponse) {
+	// Readiness is a public endpoint, so dependencies errors are only logged
+	unreachable := func(error) string { return "unreachable" }
+
+	checks := []struct {
+		name   string
+		check  func(ctx context.Context) error
+		reason func(err error) string
+	}{
+		{name: "postgres", check: s.db.Ping, reason: unreachable},
+		{name: "ton_storage", check: func(ctx context.Context) (err error) {
+			_, err = s.storage.List(ctx)
+			return
+		}, reason: unreachable},
+		{name: "liteservers", check: func(ctx context.Context) (err error) {
+			_, err = s.liteservers.GetMasterchainInfo(ctx)
+			return
+		}, reason: unreachable},
+		{name: "workers", check: s.checkWorkers, reason: error.Error},
+	}
+
+	resp.Checks = make([]v1.ReadinessCheck, len(checks))
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			cctx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			resp.Checks[i] = v1.ReadinessCheck{Name: c.name, OK: true}
+			if err := c.check(cctx); err != nil {
+				s.logger.Error("readiness check failed", slog.String("check", c.name), slog.String("error", err.Error()))
+				resp.Checks[i].OK = false
+				resp.Checks[i].Error = c.reason(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	resp.Ready = true
+	for _, c := range resp.Checks {
+		resp.Ready = resp.Ready && c.OK
+	}
+
+	return
+}
+
+func (s *service) checkWorkers(ctx context.Context) error {
+	list, err := s.workers.GetWorkers(ctx)
+	if err != nil {
+		return err
+	}
+
+	statuses := make(map[string]v1.WorkerStatus, len(list))
+	for _, w := range list {
+		statuses[w.Name] = w
+	}
+
+	var stale []string
+	for _, name := range s.criticalWorkers {
+		w, ok := statuses[name]
+		if !ok || !w.Leader || w.Paused {
+			continue
+		}
+
+		// A worker that just became leader is given the whole window for the first success
+		since := max(w.LastSuccessAt, w.LeaderSince)
+		if time.Since(time.Unix(since, 0)) > s.window {
+			stale = append(stale, name)
+		}
+	}
+
+	if len(stale) > 0 {
+		return fmt.Errorf("no successful run within %s: %v", s.window, stale)
+	}
+
+	return nil
+}
+
+func NewService(
+	db database,
+	storage storage,
+	liteservers liteservers,
+	workers workers,
+	criticalWorkers []string,
+	window time.Duration,
+	logger *slog.Logger,
+) Health {
+	return &service{
+		db:              db,
+		storage:         storage,
+		liteservers:     liteservers,
+		workers:         workers,
+		criticalWorkers: criticalWorkers,
+		window:          window,
+		logger:          logger,
+	}
+}
@@ -3,52 +3,104 @@ package providers
 import (
 	"context"
 	"fmt"
+	"math/bits"
+	"strings"
 	"time"
 
+	"github.com/xssnick/tonutils-storage-provider/pkg/transport"
+	"golang.org/x/sync/singleflight"
+
 	"mytonstorage-backend/pkg/cache"
-	v1 "mytonstorage-backend/pkg/models/api/v1"
 )
 
-type providersCache struct {
-	cache *cache.SimpleCache
-	svc   Providers
+const (
+	minSizeBucket = 1 << 20 // 1 MB
+	// Shared fetch is detached from the first caller, so it must be bounded on its own: 3 tries + pauses between them
+	sharedFetchTimeout = 3*providerRequestTimeout + 2*time.Second
+)
+
+// Declines that are likely to repeat for a while and are worth caching
+var cachedDeclines = map[string]struct{}{
+	"not available":     {},
+	"can't fetch rates": {},
 }
 
-func (c *providersCache) FetchProvidersRates(ctx context.Context, req v1.OffersRequest) (resp v1.ProviderRatesResponse, err error) {
-	return c.svc.FetchProvidersRates(ctx, req)
+type ratesEntry struct {
+	rates  *transport.StorageRatesResponse
+	reason string
 }
 
-func (c *providersCache) FetchProvidersRatesBySize(ctx context.Context, providers []string, bagSize uint64, span uint32) (resp v1.ProviderRatesResponse) {
-	return c.svc.FetchProvidersRatesBySize(ctx, providers, bagSize, span)
+// ratesCache keeps provider rates responses shared between all requests.
+// Successful responses and declines live in separate caches with their own TTL,
+// concurrent identical requests are coalesced into one provider call.
+type ratesCache struct {
+	rates    *cache.SimpleCache
+	declines *cache.SimpleCache
+	group    singleflight.Group
 }
 
-func (c *providersCache) InitStorageContract(ctx context.Context, info v1.InitStorageContractRequest, providers []v1.ProviderShort) (resp v1.Transaction, err error) {
-	return c.svc.InitStorageContract(ctx, info, providers)
+// sizeBucket rounds bag size up to the next power of two, so bags of close sizes share cached rates.
+// It is only a part of the cache key, providers are always asked about the real size.
+func sizeBucket(size uint64) uint64 {
+	if size <= minSizeBucket {
+		return minSizeBucket
+	}
+
+	return 1 << bits.Len64(size-1)
 }
 
-func (c *providersCache) EditStorageContract(ctx context.Context, address string, amount uint64, providers []v1.ProviderShort) (resp v1.Transaction, err error) {
-	return c.svc.EditStorageContract(ctx, address, amount, providers)
+func ratesKey(providerKey string, bucket uint64, span uint32) string {
+	return fmt.Sprintf("pr_%s_%d_%d", strings.ToLower(providerKey), bucket, span)
 }
 
-func (c *providersCache) fetchProviderRates(ctx context.Context, providerKey string, bagSize uint64, span uint32) (offer *v1.ProviderOffer, reason string) {
-	key := fmt.Sprintf("pr_%s_%d_%d", providerKey, bagSize, span)
-	if cached, ok := c.cache.Get(key); ok {
-		if offer, ok = cached.(*v1.ProviderOffer); ok {
+// get returns cached rates or calls fetch once for all concurrent callers with the same key
+func (c *ratesCache) get(
+	ctx context.Context,
+	providerKey string,
+	bucket uint64,
+	span uint32,
+	fetch func(ctx context.Context) (rates *transport.StorageRatesResponse, reason string),
+) (rates *transport.StorageRatesResponse, reason string) {
+	key := ratesKey(providerKey, bucket, span)
+
+	if cached, ok := c.rates.Get(key); ok {
+		if rates, ok = cached.(*transport.StorageRatesResponse); ok {
 			return
 		}
 	}
 
-	offer, reason = c.svc.fetchProviderRates(ctx, providerKey, bagSize, span)
-	if offer != nil && reason == "" {
-		c.cache.Set(key, offer)
+	if cached, ok := c.declines.Get(key); ok {
+		if reason, ok = cached.(string); ok {
+			return
+		}
 	}
 
-	return
+	ch := c.group.DoChan(key, func() (any, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedFetchTimeout)
+		defer cancel()
+
+		r, rs := fetch(fetchCtx)
+		if rs == "" && r != nil {
+			c.rates.Set(key, r)
+		} else if _, ok := cachedDeclines[rs]; ok {
+			c.declines.Set(key, rs)
+		}
+
+		return ratesEntry{rates: r, reason: rs}, nil
+	})
+
+	select {
+	case res := <-ch:
+		entry := res.Val.(ratesEntry)
+		return entry.rates, entry.reason
+	case <-ctx.Done():
+		return nil, declineTimeout
+	}
 }
 
-func NewProvidersCache(svc Providers) Providers {
-	return &providersCache{
-		cache: cache.NewSimpleCache(1 * time.Hour),
-		svc:   svc,
+func newRatesCache(ratesTTL, declinesTTL time.Duration) *ratesCache {
+	return &ratesCache{
+		rates:    cache.NewSimpleCache(ratesTTL),
+		declines: cache.NewSimpleCache(declinesTTL),
 	}
 }
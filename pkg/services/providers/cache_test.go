@@ -0,0 +1,125 @@
+package providers
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xssnick/tonutils-storage-provider/pkg/transport"
+)
+
+func TestRatesCacheCoalescesConcurrentCalls(t *testing.T) {
+	c := newRatesCache(time.Hour, time.Hour)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) (*transport.StorageRatesResponse, string) {
+		calls.Add(1)
+		<-release
+		return &transport.StorageRatesResponse{Available: true}, ""
+	}
+
+	const callers = 20
+	var wg sync.WaitGroup
+	results := make(chan *transport.StorageRatesResponse, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rates, _ := c.get(context.Background(), "AB", sizeBucket(5<<20), 86400, fetch)
+			results <- rates
+		}()
+	}
+
+	// Let the callers pile up on the pending fetch
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for rates := range results {
+		if rates == nil || !rates.Available {
+			t.Fatalf("caller got %+v, want shared rates", rates)
+		}
+	}
+
+	// A bag of a close size and the same provider in another case share the entry
+	if _, reason := c.get(context.Background(), "ab", sizeBucket(6<<20), 86400, fetch); reason != "" {
+		t.Fatalf("cached get declined: %s", reason)
+	}
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("provider was asked %d times, want 1", n)
+	}
+}
+
+func TestRatesCacheDeclines(t *testing.T) {
+	tests := []struct {
+		name   string
+		reason string
+		cached bool
+	}{
+		{"not available", "not available", true},
+		{"can't fetch rates", "can't fetch rates", true},
+		{"timeout is transient", declineTimeout, false},
+		{"provider error is transient", "failed to request rates", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newRatesCache(time.Hour, time.Hour)
+
+			var calls atomic.Int32
+			fetch := func(ctx context.Context) (*transport.StorageRatesResponse, string) {
+				calls.Add(1)
+				return nil, tt.reason
+			}
+
+			for range 2 {
+				if _, reason := c.get(context.Background(), "ab", minSizeBucket, 86400, fetch); reason != tt.reason {
+					t.Fatalf("reason = %q, want %q", reason, tt.reason)
+				}
+			}
+
+			want := int32(2)
+			if tt.cached {
+				want = 1
+			}
+			if n := calls.Load(); n != want {
+				t.Errorf("provider was asked %d times, want %d", n, want)
+			}
+		})
+	}
+}
+
+// Declines expire on their own TTL, rates stay cached meanwhile
+func TestRatesCacheTTL(t *testing.T) {
+	c := newRatesCache(time.Hour, 20*time.Millisecond)
+
+	var rateCalls, declineCalls atomic.Int32
+	rates := func(ctx context.Context) (*transport.StorageRatesResponse, string) {
+		rateCalls.Add(1)
+		return &transport.StorageRatesResponse{Available: true}, ""
+	}
+	decline := func(ctx context.Context) (*transport.StorageRatesResponse, string) {
+		declineCalls.Add(1)
+		return nil, "not available"
+	}
+
+	c.get(context.Background(), "aa", minSizeBucket, 86400, rates)
+	c.get(context.Background(), "bb", minSizeBucket, 86400, decline)
+
+	time.Sleep(50 * time.Millisecond)
+
+	c.get(context.Background(), "aa", minSizeBucket, 86400, rates)
+	c.get(context.Background(), "bb", minSizeBucket, 86400, decline)
+
+	if n := rateCalls.Load(); n != 1 {
+		t.Errorf("rates were fetched %d times, want 1", n)
+	}
+	if n := declineCalls.Load(); n != 2 {
+		t.Errorf("declined provider was asked %d times, want 2", n)
+	}
+}
@@ -0,0 +1,184 @@
+package providers
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"mytonstorage-backend/pkg/models"
+	v1 "mytonstorage-backend/pkg/models/api/v1"
+	"mytonstorage-backend/pkg/models/db"
+	"mytonstorage-backend/pkg/utils"
+)
+
+const (
+	allowlistOnlyParam = "providers_allowlist_only"
+	maxPolicyNoteLen   = 1024
+)
+
+type providersPolicy struct {
+	allowlistOnly bool
+	entries       map[string]db.ProviderPolicy
+}
+
+// decline returns the reason why the provider can't be used, empty when policy allows it
+func (p providersPolicy) decline(pubkey string) string {
+	entry, ok := p.entries[strings.ToLower(pubkey)]
+	if ok && entry.Status == v1.ProviderPolicyBlocked {
+		return "provider is blocked by administrator"
+	}
+
+	if p.allowlistOnly && (!ok || entry.Status != v1.ProviderPolicyAllowed) {
+		return "provider is not in allowlist"
+	}
+
+	return ""
+}
+
+func (s *service) loadPolicy(ctx context.Context) (policy providersPolicy, err error) {
+	mode, err := s.params.GetParam(ctx, allowlistOnlyParam)
+	if err != nil {
+		return
+	}
+
+	if mode != "" {
+		policy.allowlistOnly, err = strconv.ParseBool(mode)
+		if err != nil {
+			return
+		}
+	}
+
+	entries, err := s.registry.GetProvidersPolicy(ctx)
+	if err != nil {
+		return
+	}
+
+	policy.entries = make(map[string]db.ProviderPolicy, len(entries))
+	for _, e := range entries {
+		policy.entries[e.Pubkey] = e
+	}
+
+	return
+}
+
+// checkContractPolicy validates providers that are going to be written to a contract
+func (s *service) checkContractPolicy(ctx context.Context, providers []v1.ProviderShort) error {
+	policy, err := s.loadPolicy(ctx)
+	if err != nil {
+		s.logger.Error("failed to load providers policy", slog.String("error", err.Error()))
+		return models.NewAppError(models.InternalServerErrorCode, "")
+	}
+
+	for _, p := range providers {
+		if reason := policy.decline(p.Pubkey); reason != "" {
+			return models.NewAppError(models.BadRequestErrorCode, p.Pubkey+": "+reason)
+		}
+	}
+
+	return nil
+}
+
+func (s *service) GetProvidersPolicy(ctx context.Context) (resp v1.ProvidersPolicy, err error) {
+	log := s.logger.With("method", "GetProvidersPolicy")
+
+	policy, err := s.loadPolicy(ctx)
+	if err != nil {
+		log.Error("failed to load providers policy", slog.String("error", err.Error()))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	resp.AllowlistOnly = policy.allowlistOnly
+	resp.Providers = make([]v1.ProviderPolicy, 0, len(policy.entries))
+	for _, e := range policy.entries {
+		resp.Providers = append(resp.Providers, v1.ProviderPolicy{
+			Pubkey:    e.Pubkey,
+			Status:    e.Status,
+			Note:      e.Note,
+			UpdatedAt: e.UpdatedAt,
+		})
+	}
+
+	slices.SortFunc(resp.Providers, func(a, b v1.ProviderPolicy) int {
+		return strings.Compare(a.Pubkey, b.Pubkey)
+	})
+
+	return
+}
+
+func (s *service) SetProviderPolicy(ctx context.Context, req v1.ProviderPolicy) (err error) {
+	log := s.logger.With(
+		"method", "SetProviderPolicy",
+		"pubkey", req.Pubkey,
+		"status", req.Status)
+
+	if _, hErr := utils.ToHashBytes(req.Pubkey); hErr != nil {
+		err = models.NewAppError(models.BadRequestErrorCode, "invalid provider pubkey")
+		return
+	}
+
+	if req.Status != v1.ProviderPolicyBlocked && req.Status != v1.ProviderPolicyAllowed {
+		err = models.NewAppError(models.BadRequestErrorCode, "invalid status")
+		return
+	}
+
+	if len(req.Note) > maxPolicyNoteLen {
+		err = models.NewAppError(models.BadRequestErrorCode, "note is too long")
+		return
+	}
+
+	err = s.registry.SetProviderPolicy(ctx, db.ProviderPolicy{
+		Pubkey: req.Pubkey,
+		Status: req.Status,
+		Note:   req.Note,
+	})
+	if err != nil {
+		log.Error("failed to set provider policy", slog.String("error", err.Error()))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	log.Info("provider policy updated")
+
+	return
+}
+
+func (s *service) DeleteProviderPolicy(ctx context.Context, pubkey string) (err error) {
+	log := s.logger.With(
+		"method", "DeleteProviderPolicy",
+		"pubkey", pubkey)
+
+	removed, err := s.registry.DeleteProviderPolicy(ctx, pubkey)
+	if err != nil {
+		log.Error("failed to delete provider policy", slog.String("error", err.Error()))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	if removed == 0 {
+		err = models.NewAppError(models.NotFoundErrorCode, "provider policy not found")
+		return
+	}
+
+	log.Info("provider policy removed")
+
+	return
+}
+
+func (s *service) SetPolicyMode(ctx context.Context, allowlistOnly bool) (err error) {
+	log := s.logger.With(
+		"method", "SetPolicyMode",
+		"allowlist_only", allowlistOnly)
+
+	if err = s.params.SetParam(ctx, allowlistOnlyParam, strconv.FormatBool(allowlistOnly)); err != nil {
+		log.Error("failed to set policy mode", slog.String("error", err.Error()))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	log.Info("providers policy mode updated")
+
+	return
+}
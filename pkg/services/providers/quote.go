@@ -0,0 +1,157 @@
+package providers
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"mytonstorage-backend/pkg/models"
+	v1 "mytonstorage-backend/pkg/models/api/v1"
+)
+
+const (
+	defaultQuoteSpan     = 24 * 60 * 60
+	defaultQuoteReplicas = 3
+	maxQuoteDays         = 10 * 365
+	// Attached to the deploy message on top of the storage payment
+	contractInitFee = 50_000_000 // 0.05 TON
+
+	// Sizes of the serialized bag header: constructor, counters, fec_none and dir name length, then two indexes per file
+	bagHeaderFixedSize   = 4 + 20 + 4 + 4
+	bagHeaderPerFileSize = 8 + 8
+)
+
+// Quote estimates the full cost of storing data before it is uploaded
+func (s *service) Quote(ctx context.Context, req v1.QuoteRequest) (resp v1.QuoteResponse, err error) {
+	log := s.logger.With(
+		"method", "Quote",
+		"files", len(req.Files),
+		"total_size", req.TotalSize,
+		"days", req.Days)
+
+	if req.Days == 0 || req.Days > maxQuoteDays {
+		err = models.NewAppError(models.BadRequestErrorCode, "invalid days")
+		return
+	}
+
+	if len(req.Providers) > providersLimit {
+		err = models.NewAppError(models.BadRequestErrorCode, "too many providers requested")
+		return
+	}
+
+	bagSize, err := estimateBagSize(req.Files, req.TotalSize)
+	if err != nil {
+		return
+	}
+
+	span := req.Span
+	if span == 0 {
+		span = defaultQuoteSpan
+		if s.maxAllowedSpan > 0 && uint64(span) > s.maxAllowedSpan {
+			span = uint32(s.maxAllowedSpan)
+		}
+	}
+
+	pieceSize := bagPieceSize(bagSize)
+	// Providers store whole pieces, so the last one is paid in full
+	paidSize := (bagSize + uint64(pieceSize) - 1) / uint64(pieceSize) * uint64(pieceSize)
+
+	var offers []v1.ProviderOffer
+	if len(req.Providers) > 0 {
+		rates := s.FetchProvidersRatesBySize(ctx, req.Providers, paidSize, span)
+		offers = rates.Offers
+		resp.Declines = rates.Declines
+	} else {
+		replicas := req.Replicas
+		if replicas == 0 {
+			replicas = defaultQuoteReplicas
+		}
+
+		recommended, rErr := s.RecommendProviders(ctx, v1.RecommendRequest{
+			BagSize:  paidSize,
+			Span:     span,
+			Replicas: replicas,
+		})
+		if rErr != nil {
+			log.Info("failed to recommend providers", slog.String("error", rErr.Error()))
+			err = rErr
+			return
+		}
+
+		offers = recommended.Providers
+	}
+
+	resp.BagSize = bagSize
+	resp.PieceSize = pieceSize
+	resp.Days = req.Days
+	resp.Providers = make([]v1.ProviderQuote, 0, len(offers))
+	for _, o := range offers {
+		total := o.PricePerDay * uint64(req.Days)
+
+		resp.Providers = append(resp.Providers, v1.ProviderQuote{
+			Key:         o.Provider.Key,
+			Span:        o.OfferSpan,
+			PricePerDay: o.PricePerDay,
+			TotalPrice:  total,
+		})
+
+		resp.PricePerDay += o.PricePerDay
+		resp.TotalPrice += total
+	}
+
+	resp.InitAmount = resp.TotalPrice + contractInitFee
+
+	return
+}
+
+// estimateBagSize returns the size of a bag built from the manifest: serialized header plus files data.
+// Without a manifest the header is counted as for a single file.
+func estimateBagSize(files []v1.QuoteFile, totalSize uint64) (uint64, error) {
+	if len(files) == 0 {
+		if totalSize == 0 {
+			return 0, models.NewAppError(models.BadRequestErrorCode, "files or total_size is required")
+		}
+
+		return bagHeaderFixedSize + bagHeaderPerFileSize + totalSize, nil
+	}
+
+	// Uploads with directories use the first path element as the bag dir name
+	dirName := ""
+	if i := strings.Index(files[0].Path, "/"); i != -1 {
+		dirName = files[0].Path[:i]
+	}
+
+	size := uint64(bagHeaderFixedSize + len(dirName))
+	for _, f := range files {
+		if f.Path == "" {
+			return 0, models.NewAppError(models.BadRequestErrorCode, "empty file path")
+		}
+
+		name := f.Path
+		if dirName != "" {
+			name = strings.TrimPrefix(name, dirName+"/")
+		}
+
+		size += bagHeaderPerFileSize + uint64(len(name)) + f.Size
+	}
+
+	return size, nil
+}
+
+// bagPieceSize mirrors piece size selection of tonutils-storage for a new bag
+func bagPieceSize(size uint64) uint32 {
+	switch {
+	case size > 100<<30:
+		return 8 << 20
+	case size > 20<<30:
+		return 4 << 20
+	case size > 10<<30:
+		return 2 << 20
+	case size > 1<<30:
+		return 1 << 20
+	case size > 512<<20:
+		return 256 << 10
+	default:
+		return 128 << 10
+	}
+}
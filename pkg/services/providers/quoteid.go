@@ -0,0 +1,167 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"mytonstorage-backend/pkg/models"
+	v1 "mytonstorage-backend/pkg/models/api/v1"
+)
+
+// quoteSigningContext is prepended to signed quote payloads. Quotes are signed with the session keyring,
+// the context keeps a quote signature from being accepted as a session signature and the other way round.
+const quoteSigningContext = "quote:"
+
+// offersQuote fixes providers terms between the offers request and the contract transaction of the same wallet
+type offersQuote struct {
+	UserAddress string             `json:"user_address"`
+	BagID       string             `json:"bag_id,omitempty"`
+	BagSize     uint64             `json:"bag_size"`
+	Span        uint32             `json:"span"`
+	Providers   []v1.ProviderShort `json:"providers"`
+	ExpiresAt   int64              `json:"expires_at"`
+}
+
+// signQuote returns quote id in the "<key id>.<hex signature>:<payload>" form, same as session ids
+func (s *service) signQuote(q offersQuote) (id string, err error) {
+	data, err := json.Marshal(q)
+	if err != nil {
+		return
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	keyID, signature := s.signer.Sign([]byte(quoteSigningContext + payload))
+	id = fmt.Sprintf("%s.%x:%s", keyID, signature, payload)
+
+	return
+}
+
+func (s *service) parseQuote(id string) (q offersQuote, err error) {
+	signature, payload, ok := strings.Cut(id, ":")
+	if !ok {
+		err = fmt.Errorf("invalid quote format")
+		return
+	}
+
+	keyID, sigHex, ok := strings.Cut(signature, ".")
+	if !ok {
+		err = fmt.Errorf("invalid quote signature format")
+		return
+	}
+
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return
+	}
+
+	if !s.signer.Verify(keyID, []byte(quoteSigningContext+payload), sig) {
+		err = fmt.Errorf("invalid quote signature")
+		return
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &q)
+
+	return
+}
+
+// ProvidersFromQuote returns providers terms fixed by a quote for the contract init of the bag.
+// The bag size is taken from the node, the quote must be issued for the same bag, size, span and provider set.
+// Expired quote is not an error, valid is false then and the caller has to request fresh rates.
+func (s *service) ProvidersFromQuote(ctx context.Context, userAddress string, quoteID string, bagID string, span uint32, providers []string) (resp []v1.ProviderShort, valid bool, err error) {
+	log := s.logger.With(
+		"method", "ProvidersFromQuote",
+		"user_address", userAddress,
+		"bag_id", bagID,
+		"providers", providers)
+
+	if bagID == "" {
+		err = models.NewAppError(models.BadRequestErrorCode, "bag id is required")
+		return
+	}
+
+	bagSize, err := s.resolveBagSize(ctx, bagID, 0, log)
+	if err != nil {
+		return
+	}
+
+	return s.providersFromQuote(userAddress, quoteID, bagID, bagSize, span, providers, log)
+}
+
+// ProvidersFromContractQuote returns providers terms fixed by a quote for the providers update of the contract.
+// The bag and its size are taken from the contract record, not from the request.
+func (s *service) ProvidersFromContractQuote(ctx context.Context, userAddress string, quoteID string, contractAddr string, span uint32, providers []string) (resp []v1.ProviderShort, valid bool, err error) {
+	log := s.logger.With(
+		"method", "ProvidersFromContractQuote",
+		"user_address", userAddress,
+		"contract_address", contractAddr,
+		"providers", providers)
+
+	bags, err := s.files.GetBagsInfoShort(ctx, []string{contractAddr})
+	if err != nil {
+		log.Error("failed to get contract bag", slog.String("error", err.Error()))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	if len(bags) == 0 {
+		err = models.NewAppError(models.BadRequestErrorCode, "quote does not match request")
+		return
+	}
+
+	return s.providersFromQuote(userAddress, quoteID, bags[0].BagID, bags[0].Size, span, providers, log)
+}
+
+func (s *service) providersFromQuote(userAddress string, quoteID string, bagID string, bagSize uint64, span uint32, providers []string, log *slog.Logger) (resp []v1.ProviderShort, valid bool, err error) {
+	q, err := s.parseQuote(quoteID)
+	if err != nil {
+		log.Error("failed to parse quote", slog.String("error", err.Error()))
+		err = models.NewAppError(models.BadRequestErrorCode, "invalid quote")
+		return
+	}
+
+	if time.Now().Unix() > q.ExpiresAt {
+		log.Info("quote expired", slog.Int64("expires_at", q.ExpiresAt))
+		return
+	}
+
+	if !q.matches(userAddress, bagID, bagSize, span, providers) {
+		log.Error("quote does not match request")
+		err = models.NewAppError(models.BadRequestErrorCode, "quote does not match request")
+		return
+	}
+
+	return q.Providers, true, nil
+}
+
+// matches reports whether the quote was issued to this wallet for exactly this bag, size, span and provider set
+func (q offersQuote) matches(userAddress string, bagID string, bagSize uint64, span uint32, providers []string) bool {
+	if userAddress == "" || userAddress != q.UserAddress || bagID == "" || bagSize == 0 || !strings.EqualFold(bagID, q.BagID) || bagSize != q.BagSize ||
+		span != q.Span || len(providers) != len(q.Providers) {
+		return false
+	}
+
+	quoted := make(map[string]struct{}, len(q.Providers))
+	for _, p := range q.Providers {
+		quoted[strings.ToLower(p.Pubkey)] = struct{}{}
+	}
+
+	for _, p := range providers {
+		if _, ok := quoted[strings.ToLower(p)]; !ok {
+			return false
+		}
+		delete(quoted, strings.ToLower(p))
+	}
+
+	return true
+}
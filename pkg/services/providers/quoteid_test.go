@@ -0,0 +1,91 @@
+package providers
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "mytonstorage-backend/pkg/models/api/v1"
+	"mytonstorage-backend/pkg/services/auth"
+)
+
+func newQuoteService(t *testing.T) *service {
+	t.Helper()
+
+	keys, err := auth.NewKeyring("k1", ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &service{signer: keys, logger: slog.New(slog.DiscardHandler)}
+}
+
+const testWallet = "EQAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAM9c"
+
+func testQuote() offersQuote {
+	return offersQuote{
+		UserAddress: testWallet,
+		BagID:       "ABCDEF0123",
+		BagSize:     10 << 20,
+		Span:        86400,
+		Providers: []v1.ProviderShort{
+			{Pubkey: "AA11", PricePerMBDay: 10, MaxSpan: 86400},
+			{Pubkey: "bb22", PricePerMBDay: 20, MaxSpan: 86400},
+		},
+		ExpiresAt: time.Now().Add(time.Minute).Unix(),
+	}
+}
+
+// A signature over the bare payload, as sessions are signed, is not accepted as a quote
+func TestQuoteSigningContext(t *testing.T) {
+	s := newQuoteService(t)
+
+	id, err := s.signQuote(testQuote())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = s.parseQuote(id); err != nil {
+		t.Fatalf("parseQuote() error = %v", err)
+	}
+
+	_, payload, _ := strings.Cut(id, ":")
+	keyID, signature := s.signer.Sign([]byte(payload))
+	if _, err = s.parseQuote(fmt.Sprintf("%s.%x:%s", keyID, signature, payload)); err == nil {
+		t.Error("quote signed without context accepted")
+	}
+}
+
+func TestQuoteMatches(t *testing.T) {
+	q := testQuote()
+
+	tests := []struct {
+		name      string
+		wallet    string
+		bagID     string
+		bagSize   uint64
+		providers []string
+		want      bool
+	}{
+		{"exact", testWallet, "abcdef0123", 10 << 20, []string{"BB22", "aa11"}, true},
+		{"other wallet", "EQABAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAJYa", "ABCDEF0123", 10 << 20, []string{"AA11", "bb22"}, false},
+		{"no wallet", "", "ABCDEF0123", 10 << 20, []string{"AA11", "bb22"}, false},
+		{"no bag id", testWallet, "", 10 << 20, []string{"AA11", "bb22"}, false},
+		{"other bag", testWallet, "ABCDEF0124", 10 << 20, []string{"AA11", "bb22"}, false},
+		{"unknown size", testWallet, "ABCDEF0123", 0, []string{"AA11", "bb22"}, false},
+		{"other size", testWallet, "ABCDEF0123", 10<<20 + 1, []string{"AA11", "bb22"}, false},
+		{"subset", testWallet, "ABCDEF0123", 10 << 20, []string{"AA11"}, false},
+		{"duplicate", testWallet, "ABCDEF0123", 10 << 20, []string{"AA11", "aa11"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := q.matches(tt.wallet, tt.bagID, tt.bagSize, q.Span, tt.providers); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package providers
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+
+	"mytonstorage-backend/pkg/models"
+	v1 "mytonstorage-backend/pkg/models/api/v1"
+	"mytonstorage-backend/pkg/models/db"
+)
+
+const (
+	replacementTxAmount  = 50_000_000 // 0.05 TON to cover the edit message fees
+	maxReplicas          = 32
+	recommendCandidates  = 64
+	unknownReputation    = 0.5
+	recommendPriceWeight = 0.5
+)
+
+// RecommendProviders picks a provider set for the requested replication factor.
+// Candidates come from the registry, their current offers are requested through the regular rates flow
+// and ranked by price and reputation. When budget is set, providers that do not fit into it are skipped.
+func (s *service) RecommendProviders(ctx context.Context, req v1.RecommendRequest) (resp v1.RecommendResponse, err error) {
+	log := s.logger.With(
+		"method", "RecommendProviders",
+		"bag_id", req.BagID,
+		"bag_size", req.BagSize,
+		"span", req.Span,
+		"replicas", req.Replicas,
+		"budget", req.Budget)
+
+	if req.Replicas <= 0 || req.Replicas > maxReplicas {
+		err = models.NewAppError(models.BadRequestErrorCode, "invalid replicas count")
+		return
+	}
+
+	if req.Span == 0 || (s.maxAllowedSpan > 0 && uint64(req.Span) > s.maxAllowedSpan) {
+		err = models.NewAppError(models.BadRequestErrorCode, "invalid span")
+		return
+	}
+
+	if req.BagID == "" && req.BagSize == 0 {
+		err = models.NewAppError(models.BadRequestErrorCode, "bag_id or bag_size is required")
+		return
+	}
+
+	bagSize, err := s.resolveBagSize(ctx, req.BagID, req.BagSize, log)
+	if err != nil {
+		return
+	}
+
+	candidates, err := s.registry.GetRegistryProviders(ctx, db.ProvidersFilter{
+		Status:     v1.ProviderStatusAvailable,
+		MinSpaceMB: (bagSize + (1 << 20) - 1) >> 20,
+		Span:       req.Span,
+		SortBy:     "reputation",
+		Desc:       true,
+		Limit:      recommendCandidates,
+	})
+	if err != nil {
+		log.Error("failed to get candidates from registry", slog.String("error", err.Error()))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	pubkeys := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		pubkeys = append(pubkeys, c.Pubkey)
+	}
+
+	offers := s.FetchProvidersRatesBySize(ctx, pubkeys, bagSize, req.Span).Offers
+	if len(offers) < req.Replicas {
+		log.Info("not enough providers", slog.Int("offers", len(offers)))
+		err = models.NewAppError(models.NotFoundErrorCode, "not enough available providers")
+		return
+	}
+
+	rankOffers(offers)
+
+	var perDay uint64
+	for _, o := range offers {
+		if len(resp.Providers) == req.Replicas {
+			break
+		}
+
+		if req.Budget > 0 && totalPrice(perDay+o.PricePerDay, req.Span) > req.Budget {
+			continue
+		}
+
+		perDay += o.PricePerDay
+		resp.Providers = append(resp.Providers, o)
+	}
+
+	if len(resp.Providers) < req.Replicas {
+		err = models.NewAppError(models.BadRequestErrorCode, "not enough providers within budget")
+		return
+	}
+
+	resp.PricePerDay = perDay
+	resp.TotalPrice = totalPrice(perDay, req.Span)
+	resp.Span = req.Span
+
+	return
+}
+
+// PrepareProviderReplacement picks the best available provider not present in the contract
+// and builds a transaction that swaps it in instead of the replaced one, keeping the rest of the providers.
+func (s *service) PrepareProviderReplacement(
+	ctx context.Context,
+	contractAddr string,
+	bagSize uint64,
+	span uint32,
+	keep []v1.ProviderShort,
+	replaced string,
+) (replacement v1.ProviderOffer, tx v1.Transaction, err error) {
+	log := s.logger.With(
+		"method", "PrepareProviderReplacement",
+		"contract_address", contractAddr,
+		"replaced", replaced)
+
+	exclude := map[string]struct{}{strings.ToLower(replaced): {}}
+	for _, p := range keep {
+		exclude[strings.ToLower(p.Pubkey)] = struct{}{}
+	}
+
+	candidates, err := s.registry.GetRegistryProviders(ctx, db.ProvidersFilter{
+		Status:     v1.ProviderStatusAvailable,
+		MinSpaceMB: (bagSize + (1 << 20) - 1) >> 20,
+		Span:       span,
+		SortBy:     "reputation",
+		Desc:       true,
+		Limit:      recommendCandidates,
+	})
+	if err != nil {
+		log.Error("failed to get candidates from registry", slog.String("error", err.Error()))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	pubkeys := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		if _, ok := exclude[strings.ToLower(c.Pubkey)]; !ok {
+			pubkeys = append(pubkeys, c.Pubkey)
+		}
+	}
+
+	offers := s.FetchProvidersRatesBySize(ctx, pubkeys, bagSize, span).Offers
+	if len(offers) == 0 {
+		err = models.NewAppError(models.NotFoundErrorCode, "no replacement available")
+		return
+	}
+
+	rankOffers(offers)
+	replacement = offers[0]
+
+	providers := append(slices.Clone(keep), v1.ProviderShort{
+		Pubkey:        replacement.Provider.Key,
+		MaxSpan:       replacement.OfferSpan,
+		PricePerMBDay: replacement.PricePerMB,
+	})
+
+	tx, err = s.EditStorageContract(ctx, contractAddr, replacementTxAmount, providers)
+
+	return
+}
+
+// rankOffers sorts offers from best to worst, price is scored relative to the cheapest offer
+func rankOffers(offers []v1.ProviderOffer) {
+	var minPrice uint64
+	for _, o := range offers {
+		if minPrice == 0 || (o.PricePerDay > 0 && o.PricePerDay < minPrice) {
+			minPrice = o.PricePerDay
+		}
+	}
+
+	rank := func(o v1.ProviderOffer) float64 {
+		priceScore := 1.0
+		if o.PricePerDay > 0 && minPrice > 0 {
+			priceScore = float64(minPrice) / float64(o.PricePerDay)
+		}
+
+		reputation := unknownReputation
+		if o.Reputation != nil {
+			reputation = o.Reputation.Score
+		}
+
+		return recommendPriceWeight*priceScore + (1-recommendPriceWeight)*reputation
+	}
+
+	sort.SliceStable(offers, func(i, j int) bool {
+		return rank(offers[i]) > rank(offers[j])
+	})
+}
+
+func totalPrice(perDay uint64, span uint32) uint64 {
+	return perDay * uint64(span) / (24 * 60 * 60)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"math/big"
@@ -12,6 +13,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/tlb"
 	"github.com/xssnick/tonutils-go/tvm/cell"
@@ -19,46 +21,102 @@ import (
 	"github.com/xssnick/tonutils-storage-provider/pkg/transport"
 	"github.com/xssnick/tonutils-storage/provider"
 
+	providersclient "mytonstorage-backend/pkg/clients/providers"
 	tonstorage "mytonstorage-backend/pkg/clients/ton-storage"
 	"mytonstorage-backend/pkg/models"
 	v1 "mytonstorage-backend/pkg/models/api/v1"
+	"mytonstorage-backend/pkg/models/db"
 	"mytonstorage-backend/pkg/utils"
 )
 
 const (
 	providersLimit         = 256
 	providerRequestTimeout = 7 * time.Second
+	registryDefaultLimit   = 100
+	registryMaxLimit       = 1000
+	historyDefaultPeriod   = 7 * 24 * time.Hour
+	historyDefaultLimit    = 1000
+	historyMaxLimit        = 10000
+
+	// Decline reason of a provider that did not answer in time, whatever timeout was hit
+	declineTimeout = "long response time"
 )
 
 type files interface {
 	IsBagExpired(ctx context.Context, bagID string, userAddress string, sec uint64) (expired bool, err error)
+	GetBagsInfoShort(ctx context.Context, contracts []string) ([]db.BagDescription, error)
 }
 
 type storage interface {
 	GetBag(ctx context.Context, bagId string) (*tonstorage.BagDetailed, error)
 }
 
+type registry interface {
+	AddProviders(ctx context.Context, pubkeys []string) (int64, error)
+	UpdateProvidersRates(ctx context.Context, providers []db.RegistryProvider) error
+	MarkProvidersUnreachable(ctx context.Context, pubkeys []string) error
+	GetRegistryProviders(ctx context.Context, filter db.ProvidersFilter) (providers []db.RegistryProvider, err error)
+	GetProvidersReputation(ctx context.Context, pubkeys []string) (reputation []db.ProviderReputation, err error)
+	GetRatesHistory(ctx context.Context, pubkey string, from, to int64, limit int) (points []db.ProviderRatesPoint, err error)
+	GetUserProofIncidents(ctx context.Context, userAddress string) (incidents []db.ProofIncident, err error)
+	SetProviderPolicy(ctx context.Context, policy db.ProviderPolicy) error
+	DeleteProviderPolicy(ctx context.Context, pubkey string) (cnt int64, err error)
+	GetProvidersPolicy(ctx context.Context) (policy []db.ProviderPolicy, err error)
+}
+
+type signer interface {
+	Sign(data []byte) (id string, signature []byte)
+	Verify(id string, data, signature []byte) bool
+}
+
+type params interface {
+	GetParam(ctx context.Context, key string) (value string, err error)
+	SetParam(ctx context.Context, key string, value string) (err error)
+}
+
 type service struct {
 	files               files
 	storage             storage
-	provider            *transport.Client
+	registry            registry
+	params              params
+	signer              signer
+	provider            providersclient.Transport
 	maxAllowedSpan      uint64
 	unpaidFilesLifetime time.Duration
+	ratesWorkers        int
+	ratesDeadline       time.Duration
+	ratesDuration       *prometheus.HistogramVec
+	ratesCache          *ratesCache
+	quoteTTL            time.Duration
 	logger              *slog.Logger
 }
 
 type Providers interface {
-	FetchProvidersRates(ctx context.Context, req v1.OffersRequest) (resp v1.ProviderRatesResponse, err error)
+	FetchProvidersRates(ctx context.Context, userAddress string, req v1.OffersRequest) (resp v1.ProviderRatesResponse, err error)
 	FetchProvidersRatesBySize(ctx context.Context, providers []string, bagSize uint64, span uint32) (resp v1.ProviderRatesResponse)
+	RecommendProviders(ctx context.Context, req v1.RecommendRequest) (resp v1.RecommendResponse, err error)
+	Quote(ctx context.Context, req v1.QuoteRequest) (resp v1.QuoteResponse, err error)
+	PrepareProviderReplacement(ctx context.Context, contractAddr string, bagSize uint64, span uint32, keep []v1.ProviderShort, replaced string) (replacement v1.ProviderOffer, tx v1.Transaction, err error)
+	GetProofIncidents(ctx context.Context, userAddr string) (incidents []v1.ProofIncident, err error)
+	ProvidersFromQuote(ctx context.Context, userAddress string, quoteID string, bagID string, span uint32, providers []string) (resp []v1.ProviderShort, valid bool, err error)
+	ProvidersFromContractQuote(ctx context.Context, userAddress string, quoteID string, contractAddr string, span uint32, providers []string) (resp []v1.ProviderShort, valid bool, err error)
 	InitStorageContract(ctx context.Context, info v1.InitStorageContractRequest, providers []v1.ProviderShort) (resp v1.Transaction, err error)
 	EditStorageContract(ctx context.Context, address string, amount uint64, providers []v1.ProviderShort) (resp v1.Transaction, err error)
 
-	fetchProviderRates(ctx context.Context, providerKey string, bagSize uint64, span uint32) (offer *v1.ProviderOffer, reason string)
+	GetProviders(ctx context.Context, filter v1.ProvidersFilter) (resp v1.ProvidersListResponse, err error)
+	ImportProviders(ctx context.Context, req v1.ImportProvidersRequest) (resp v1.ImportProvidersResponse, err error)
+	GetRatesHistory(ctx context.Context, pubkey string, filter v1.RatesHistoryFilter) (resp v1.RatesHistoryResponse, err error)
+
+	GetProvidersPolicy(ctx context.Context) (resp v1.ProvidersPolicy, err error)
+	SetProviderPolicy(ctx context.Context, req v1.ProviderPolicy) (err error)
+	DeleteProviderPolicy(ctx context.Context, pubkey string) (err error)
+	SetPolicyMode(ctx context.Context, allowlistOnly bool) (err error)
 }
 
-func (s *service) FetchProvidersRates(ctx context.Context, req v1.OffersRequest) (resp v1.ProviderRatesResponse, err error) {
+func (s *service) FetchProvidersRates(ctx context.Context, userAddress string, req v1.OffersRequest) (resp v1.ProviderRatesResponse, err error) {
 	log := s.logger.With(
 		"method", "FetchProvidersRates",
+		"user_address", userAddress,
 		"bag_id", req.BagID,
 		"providers", req.Providers)
 
@@ -72,59 +130,206 @@ func (s *service) FetchProvidersRates(ctx context.Context, req v1.OffersRequest)
 		return
 	}
 
-	bagSize := req.BagSize
-	if bagSize == 0 {
-		details, bErr := s.storage.GetBag(ctx, req.BagID)
-		if bErr != nil {
-			log.Error("failed to get bag details", slog.String("error", bErr.Error()))
-			err = models.NewAppError(models.ServiceUnavailableCode, "failed to get bag details")
-			return
-		}
-
-		bagSize = details.BagSize
+	bagSize, err := s.resolveBagSize(ctx, req.BagID, req.BagSize, log)
+	if err != nil {
+		return
 	}
 
 	resp = s.FetchProvidersRatesBySize(ctx, req.Providers, bagSize, req.Span)
 
+	// A quote is bound to the wallet and the bag, offers requested only by size are not quoted
+	if len(resp.Offers) == 0 || req.BagID == "" || userAddress == "" {
+		return resp, nil
+	}
+
+	quote := offersQuote{
+		UserAddress: userAddress,
+		BagID:       strings.ToLower(req.BagID),
+		BagSize:     bagSize,
+		Span:        req.Span,
+		Providers:   make([]v1.ProviderShort, 0, len(resp.Offers)),
+		ExpiresAt:   time.Now().Add(s.quoteTTL).Unix(),
+	}
+	for _, o := range resp.Offers {
+		quote.Providers = append(quote.Providers, v1.ProviderShort{
+			Pubkey:        o.Provider.Key,
+			MaxSpan:       o.OfferSpan,
+			PricePerMBDay: o.PricePerMB,
+		})
+	}
+
+	quoteID, qErr := s.signQuote(quote)
+	if qErr != nil {
+		log.Error("failed to sign quote", slog.String("error", qErr.Error()))
+		return resp, nil
+	}
+
+	resp.QuoteID = quoteID
+	resp.QuoteExpiresAt = quote.ExpiresAt
+
 	return resp, nil
 }
 
+// resolveBagSize returns the size passed by the client or asks storage for it
+func (s *service) resolveBagSize(ctx context.Context, bagID string, bagSize uint64, log *slog.Logger) (uint64, error) {
+	if bagSize > 0 {
+		return bagSize, nil
+	}
+
+	details, err := s.storage.GetBag(ctx, bagID)
+	if err != nil {
+		log.Error("failed to get bag details", slog.String("error", err.Error()))
+		return 0, models.NewAppError(models.ServiceUnavailableCode, "failed to get bag details")
+	}
+
+	return details.BagSize, nil
+}
+
 func (s *service) FetchProvidersRatesBySize(ctx context.Context, providers []string, bagSize uint64, span uint32) (resp v1.ProviderRatesResponse) {
+	type rateResult struct {
+		offer  *v1.ProviderOffer
+		reason string
+		done   bool
+	}
+
+	// Providers rejected by the admin policy are declined without asking them for rates
+	candidates := make([]string, 0, len(providers))
+	policy, err := s.loadPolicy(ctx)
+	if err != nil {
+		s.logger.Error("failed to load providers policy", slog.String("error", err.Error()))
+	}
+
+	for _, provider := range providers {
+		reason := "provider policy unavailable"
+		if err == nil {
+			reason = policy.decline(provider)
+		}
+
+		if reason != "" {
+			resp.Declines = append(resp.Declines, v1.ProviderDecline{
+				ProviderKey: provider,
+				Reason:      reason,
+			})
+			continue
+		}
+
+		candidates = append(candidates, provider)
+	}
+
+	deadlineCtx, cancel := context.WithTimeout(ctx, s.ratesDeadline)
+	defer cancel()
+
 	wg := sync.WaitGroup{}
 	mu := sync.Mutex{}
+	sem := make(chan struct{}, s.ratesWorkers)
+	results := make([]rateResult, len(candidates))
 
-	for _, provider := range providers {
+	for i, provider := range candidates {
 		wg.Add(1)
 
-		func() {
+		go func() {
 			defer wg.Done()
 
-			timeoutCtx, cancel := context.WithTimeout(ctx, providerRequestTimeout)
-			defer cancel()
+			select {
+			case sem <- struct{}{}:
+			case <-deadlineCtx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			rate, reason := s.fetchProviderRates(deadlineCtx, provider, bagSize, span)
 
-			rate, reason := s.fetchProviderRates(timeoutCtx, provider, bagSize, span)
+			result := "offer"
 			if reason != "" {
-				mu.Lock()
-				resp.Declines = append(resp.Declines, v1.ProviderDecline{
-					ProviderKey: provider,
-					Reason:      reason,
-				})
-				mu.Unlock()
-
-				return
+				result = "decline"
 			}
+			s.ratesDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
 
 			mu.Lock()
-			resp.Offers = append(resp.Offers, *rate)
+			results[i] = rateResult{offer: rate, reason: reason, done: true}
 			mu.Unlock()
 		}()
 	}
 
-	wg.Wait()
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-deadlineCtx.Done():
+	}
+
+	// Providers still in progress after the deadline are declined, their late results are dropped
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i, provider := range candidates {
+		r := results[i]
+		switch {
+		case !r.done:
+			resp.Declines = append(resp.Declines, v1.ProviderDecline{
+				ProviderKey: provider,
+				Reason:      declineTimeout,
+			})
+		case r.reason != "":
+			resp.Declines = append(resp.Declines, v1.ProviderDecline{
+				ProviderKey: provider,
+				Reason:      r.reason,
+			})
+		default:
+			resp.Offers = append(resp.Offers, *r.offer)
+		}
+	}
+
+	s.attachReputation(ctx, resp.Offers)
 
 	return
 }
 
+func (s *service) attachReputation(ctx context.Context, offers []v1.ProviderOffer) {
+	if len(offers) == 0 {
+		return
+	}
+
+	pubkeys := make([]string, 0, len(offers))
+	for _, o := range offers {
+		pubkeys = append(pubkeys, o.Provider.Key)
+	}
+
+	reputation, err := s.registry.GetProvidersReputation(ctx, pubkeys)
+	if err != nil {
+		s.logger.Error("failed to get providers reputation", slog.String("error", err.Error()))
+		return
+	}
+
+	byKey := make(map[string]db.ProviderReputation, len(reputation))
+	for _, r := range reputation {
+		byKey[r.Pubkey] = r
+	}
+
+	for i := range offers {
+		if r, ok := byKey[strings.ToLower(offers[i].Provider.Key)]; ok {
+			offers[i].Reputation = toReputation(r)
+		}
+	}
+}
+
+func toReputation(r db.ProviderReputation) *v1.ProviderReputation {
+	return &v1.ProviderReputation{
+		Score:              r.Score,
+		NotifySuccessRate:  r.NotifySuccessRate,
+		AvgDownloadSeconds: r.AvgDownloadSeconds,
+		AbandonedRate:      r.AbandonedRate,
+		ProofLagSeconds:    r.ProofLagSeconds,
+		Samples:            r.Samples,
+		UpdatedAt:          r.UpdatedAt,
+	}
+}
+
 func (s *service) InitStorageContract(ctx context.Context, info v1.InitStorageContractRequest, providers []v1.ProviderShort) (resp v1.Transaction, err error) {
 	log := s.logger.With(
 		"method", "InitStorageContract",
@@ -142,6 +347,16 @@ func (s *service) InitStorageContract(ctx context.Context, info v1.InitStorageCo
 		return
 	}
 
+	if err = s.checkContractSpans(ctx, providers); err != nil {
+		log.Error("span policy violated", slog.String("error", err.Error()))
+		return
+	}
+
+	if err = s.checkContractPolicy(ctx, providers); err != nil {
+		log.Error("providers policy violated", slog.String("error", err.Error()))
+		return
+	}
+
 	ownerAddr, err := address.ParseAddr(info.OwnerAddress)
 	if err != nil {
 		log.Error("failed to parse owner address", slog.String("error", err.Error()))
@@ -255,6 +470,16 @@ func (s *service) EditStorageContract(ctx context.Context, contractAddr string,
 		return
 	}
 
+	if err = s.checkContractSpans(ctx, providers); err != nil {
+		log.Error("span policy violated", slog.String("error", err.Error()))
+		return
+	}
+
+	if err = s.checkContractPolicy(ctx, providers); err != nil {
+		log.Error("providers policy violated", slog.String("error", err.Error()))
+		return
+	}
+
 	providersDict := cell.NewDict(256)
 	for _, p := range providers {
 		d, dErr := hex.DecodeString(p.Pubkey)
@@ -313,28 +538,11 @@ func (s *service) fetchProviderRates(ctx context.Context, providerKey string, ba
 		return
 	}
 
-	var rates *transport.StorageRatesResponse
-	err = utils.TryNTimes(func() error {
-		timeoutCtx, cancel := context.WithTimeout(ctx, providerRequestTimeout)
-		defer cancel()
-		rates, err = s.provider.GetStorageRates(timeoutCtx, pk, bagSize)
-		return err
-	}, 3)
-	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			log.Error("provider rates request timed out", slog.String("error", err.Error()))
-			reason = "long response time"
-			return
-		}
-
-		log.Error("failed to fetch rates", slog.String("error", err.Error()))
-		reason = "can't fetch rates"
-		return
-	}
-
-	if rates == nil {
-		log.Error("provider returned empty rates")
-		reason = "not available"
+	// Providers are asked about the real size, the bucket only groups cached answers of bags with close sizes
+	rates, reason := s.ratesCache.get(ctx, providerKey, sizeBucket(bagSize), span, func(ctx context.Context) (*transport.StorageRatesResponse, string) {
+		return s.requestProviderRates(ctx, providerKey, pk, bagSize, log)
+	})
+	if reason != "" {
 		return
 	}
 
@@ -343,21 +551,23 @@ func (s *service) fetchProviderRates(ctx context.Context, providerKey string, ba
 		return
 	}
 
-	rates.MinSpan = span
-	rates.MaxSpan = span
-
 	if !rates.Available {
 		reason = "not available"
 		return
 	}
 
+	offerSpan, reason := s.providerSpan(span, rates.MinSpan, rates.MaxSpan)
+	if reason != "" {
+		return
+	}
+
 	p := provider.ProviderRates{
 		Available:        rates.Available,
 		RatePerMBDay:     tlb.FromNanoTON(new(big.Int).SetBytes(rates.RatePerMBDay)),
 		MinBounty:        tlb.FromNanoTON(new(big.Int).SetBytes(rates.MinBounty)),
 		SpaceAvailableMB: rates.SpaceAvailableMB,
-		MinSpan:          rates.MinSpan,
-		MaxSpan:          rates.MaxSpan,
+		MinSpan:          offerSpan,
+		MaxSpan:          offerSpan,
 
 		Size: bagSize,
 	}
@@ -382,13 +592,298 @@ func (s *service) fetchProviderRates(ctx context.Context, providerKey string, ba
 	return
 }
 
-func NewService(provider *transport.Client, files files, storage storage, maxAllowedSpanDays uint32, unpaidFilesLifetime time.Duration, logger *slog.Logger) Providers {
+// requestProviderRates asks provider for its rates over ADNL, the response is shared through ratesCache
+func (s *service) requestProviderRates(ctx context.Context, providerKey string, pk []byte, size uint64, log *slog.Logger) (rates *transport.StorageRatesResponse, reason string) {
+	defer func() {
+		s.saveToRegistry(ctx, providerKey, rates, reason, log)
+	}()
+
+	err := utils.TryNTimes(func() error {
+		timeoutCtx, cancel := context.WithTimeout(ctx, providerRequestTimeout)
+		defer cancel()
+		var rErr error
+		rates, rErr = s.provider.GetStorageRates(timeoutCtx, pk, size)
+		return rErr
+	}, 3)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			log.Error("provider rates request timed out", slog.String("error", err.Error()))
+			reason = declineTimeout
+			return
+		}
+
+		log.Error("failed to fetch rates", slog.String("error", err.Error()))
+		reason = "can't fetch rates"
+		return
+	}
+
+	if rates == nil {
+		log.Error("provider returned empty rates")
+		reason = "not available"
+		return
+	}
+
+	return
+}
+
+// saveToRegistry keeps the last known state of every provider we have talked to
+func (s *service) saveToRegistry(ctx context.Context, providerKey string, rates *transport.StorageRatesResponse, reason string, log *slog.Logger) {
+	if rates == nil {
+		if reason == "can't fetch rates" || reason == declineTimeout {
+			if err := s.registry.MarkProvidersUnreachable(ctx, []string{providerKey}); err != nil {
+				log.Error("failed to mark provider unreachable", slog.String("error", err.Error()))
+			}
+		}
+
+		return
+	}
+
+	status := v1.ProviderStatusAvailable
+	if !rates.Available {
+		status = v1.ProviderStatusNotAvailable
+	}
+
+	err := s.registry.UpdateProvidersRates(ctx, []db.RegistryProvider{{
+		Pubkey:           strings.ToLower(providerKey),
+		RatePerMBDay:     new(big.Int).SetBytes(rates.RatePerMBDay).Uint64(),
+		MinBounty:        new(big.Int).SetBytes(rates.MinBounty).Uint64(),
+		MinSpan:          rates.MinSpan,
+		MaxSpan:          rates.MaxSpan,
+		SpaceAvailableMB: rates.SpaceAvailableMB,
+		Status:           status,
+	}})
+	if err != nil {
+		log.Error("failed to save provider rates to registry", slog.String("error", err.Error()))
+	}
+}
+
+func (s *service) GetProviders(ctx context.Context, filter v1.ProvidersFilter) (resp v1.ProvidersListResponse, err error) {
+	log := s.logger.With(
+		"method", "GetProviders",
+		"filter", filter)
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = registryDefaultLimit
+	}
+	if limit > registryMaxLimit {
+		limit = registryMaxLimit
+	}
+
+	if filter.Offset < 0 {
+		err = models.NewAppError(models.BadRequestErrorCode, "invalid offset")
+		return
+	}
+
+	if filter.Order != "" && filter.Order != "asc" && filter.Order != "desc" {
+		err = models.NewAppError(models.BadRequestErrorCode, "invalid order")
+		return
+	}
+
+	providers, err := s.registry.GetRegistryProviders(ctx, db.ProvidersFilter{
+		Status:          filter.Status,
+		MinSpaceMB:      filter.MinSpaceMB,
+		MaxRatePerMBDay: filter.MaxRatePerMBDay,
+		Span:            filter.Span,
+		SortBy:          filter.SortBy,
+		Desc:            filter.Order == "desc",
+		Limit:           limit,
+		Offset:          filter.Offset,
+	})
+	if err != nil {
+		log.Error("failed to get providers from registry", slog.String("error", err.Error()))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	resp.Providers = make([]v1.RegistryProvider, 0, len(providers))
+	for _, p := range providers {
+		var reputation *v1.ProviderReputation
+		if p.Reputation != nil {
+			reputation = toReputation(*p.Reputation)
+		}
+
+		resp.Providers = append(resp.Providers, v1.RegistryProvider{
+			Pubkey:           p.Pubkey,
+			FirstSeenAt:      p.FirstSeenAt,
+			LastSeenAt:       p.LastSeenAt,
+			RatePerMBDay:     p.RatePerMBDay,
+			MinBounty:        p.MinBounty,
+			MinSpan:          p.MinSpan,
+			MaxSpan:          p.MaxSpan,
+			SpaceAvailableMB: p.SpaceAvailableMB,
+			Status:           p.Status,
+			Reputation:       reputation,
+		})
+	}
+
+	return
+}
+
+func (s *service) ImportProviders(ctx context.Context, req v1.ImportProvidersRequest) (resp v1.ImportProvidersResponse, err error) {
+	log := s.logger.With(
+		"method", "ImportProviders",
+		"count", len(req.Providers))
+
+	pubkeys := make([]string, 0, len(req.Providers))
+	for _, p := range req.Providers {
+		if _, hErr := utils.ToHashBytes(p); hErr != nil {
+			err = models.NewAppError(models.BadRequestErrorCode, "invalid provider pubkey: "+p)
+			return
+		}
+
+		pubkeys = append(pubkeys, strings.ToLower(p))
+	}
+
+	if len(pubkeys) == 0 {
+		return
+	}
+
+	resp.Added, err = s.registry.AddProviders(ctx, pubkeys)
+	if err != nil {
+		log.Error("failed to import providers", slog.String("error", err.Error()))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	log.Info("providers imported", slog.Int64("added", resp.Added))
+
+	return
+}
+
+func (s *service) GetRatesHistory(ctx context.Context, pubkey string, filter v1.RatesHistoryFilter) (resp v1.RatesHistoryResponse, err error) {
+	log := s.logger.With(
+		"method", "GetRatesHistory",
+		"pubkey", pubkey,
+		"filter", filter)
+
+	if _, hErr := utils.ToHashBytes(pubkey); hErr != nil {
+		err = models.NewAppError(models.BadRequestErrorCode, "invalid provider pubkey")
+		return
+	}
+
+	to := filter.To
+	if to <= 0 {
+		to = time.Now().Unix()
+	}
+
+	from := filter.From
+	if from <= 0 {
+		from = to - int64(historyDefaultPeriod.Seconds())
+	}
+
+	if from > to {
+		err = models.NewAppError(models.BadRequestErrorCode, "invalid period")
+		return
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = historyDefaultLimit
+	}
+	if limit > historyMaxLimit {
+		limit = historyMaxLimit
+	}
+
+	points, err := s.registry.GetRatesHistory(ctx, pubkey, from, to, limit)
+	if err != nil {
+		log.Error("failed to get rates history", slog.String("error", err.Error()))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	resp.Pubkey = strings.ToLower(pubkey)
+	resp.Points = make([]v1.ProviderRatesPoint, 0, len(points))
+	for _, p := range points {
+		resp.Points = append(resp.Points, v1.ProviderRatesPoint{
+			CheckedAt:        p.CheckedAt,
+			Reachable:        p.Reachable,
+			Available:        p.Available,
+			RatePerMBDay:     p.RatePerMBDay,
+			MinBounty:        p.MinBounty,
+			SpaceAvailableMB: p.SpaceAvailableMB,
+			LatencyMs:        p.LatencyMs,
+		})
+	}
+
+	return
+}
+
+func (s *service) GetProofIncidents(ctx context.Context, userAddr string) (incidents []v1.ProofIncident, err error) {
+	log := s.logger.With(
+		"method", "GetProofIncidents",
+		"user_address", userAddr)
+
+	list, err := s.registry.GetUserProofIncidents(ctx, userAddr)
+	if err != nil {
+		log.Error("failed to get proof incidents", slog.String("error", err.Error()))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	incidents = make([]v1.ProofIncident, 0, len(list))
+	for _, i := range list {
+		incident := v1.ProofIncident{
+			ID:                i.ID,
+			StorageContract:   i.StorageContract,
+			BagID:             i.BagID,
+			ProviderPubkey:    i.ProviderPubkey,
+			LastProofAt:       i.LastProofAt,
+			MaxSpan:           i.MaxSpan,
+			DetectedAt:        i.DetectedAt,
+			ReplacementPubkey: i.ReplacementPubkey,
+		}
+
+		if len(i.ReplacementTx) > 0 {
+			var tx v1.Transaction
+			if uErr := json.Unmarshal(i.ReplacementTx, &tx); uErr != nil {
+				log.Error("failed to decode replacement transaction", slog.Int64("id", i.ID), slog.String("error", uErr.Error()))
+			} else {
+				incident.Transaction = &tx
+			}
+		}
+
+		incidents = append(incidents, incident)
+	}
+
+	return
+}
+
+func NewService(
+	provider providersclient.Transport,
+	files files,
+	storage storage,
+	registry registry,
+	params params,
+	signer signer,
+	maxAllowedSpanDays uint32,
+	unpaidFilesLifetime time.Duration,
+	ratesWorkers int,
+	ratesDeadline time.Duration,
+	ratesDuration *prometheus.HistogramVec,
+	ratesCacheTTL time.Duration,
+	declinesCacheTTL time.Duration,
+	quoteTTL time.Duration,
+	logger *slog.Logger,
+) Providers {
+	if ratesWorkers <= 0 {
+		ratesWorkers = 1
+	}
+
 	return &service{
 		provider:            provider,
 		maxAllowedSpan:      uint64(maxAllowedSpanDays) * 24 * 60 * 60,
 		unpaidFilesLifetime: unpaidFilesLifetime,
+		ratesWorkers:        ratesWorkers,
+		ratesDeadline:       ratesDeadline,
+		ratesDuration:       ratesDuration,
+		ratesCache:          newRatesCache(ratesCacheTTL, declinesCacheTTL),
 		files:               files,
 		storage:             storage,
+		registry:            registry,
+		params:              params,
+		signer:              signer,
+		quoteTTL:            quoteTTL,
 		logger:              logger,
 	}
 }
@@ -0,0 +1,79 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+
+	"mytonstorage-backend/pkg/models"
+	v1 "mytonstorage-backend/pkg/models/api/v1"
+	"mytonstorage-backend/pkg/models/db"
+)
+
+// providerSpan applies the span policy to a single provider.
+// A span above the provider maximum is clamped down to it, proofs only become more frequent.
+// A span below the provider minimum or above the server maximum can't be offered.
+func (s *service) providerSpan(span, minSpan, maxSpan uint32) (effective uint32, reason string) {
+	if span == 0 {
+		return 0, "span is not set"
+	}
+
+	if s.maxAllowedSpan > 0 && uint64(span) > s.maxAllowedSpan {
+		return 0, fmt.Sprintf("span exceeds server maximum of %d seconds", s.maxAllowedSpan)
+	}
+
+	if span < minSpan {
+		return 0, fmt.Sprintf("span is below provider minimum of %d seconds", minSpan)
+	}
+
+	if maxSpan > 0 && span > maxSpan {
+		return maxSpan, ""
+	}
+
+	return span, ""
+}
+
+// checkContractSpans validates spans of providers that are going to be written to a contract.
+// Spans are checked against the limits providers advertised the last time their rates were fetched,
+// providers unknown to the registry are only checked against the server maximum.
+func (s *service) checkContractSpans(ctx context.Context, providers []v1.ProviderShort) error {
+	pubkeys := make([]string, 0, len(providers))
+	for _, p := range providers {
+		pubkeys = append(pubkeys, p.Pubkey)
+	}
+
+	known, err := s.registry.GetRegistryProviders(ctx, db.ProvidersFilter{
+		Pubkeys: pubkeys,
+		Limit:   len(pubkeys),
+	})
+	if err != nil {
+		s.logger.Error("failed to load providers span limits", slog.String("error", err.Error()))
+		return models.NewAppError(models.InternalServerErrorCode, "")
+	}
+
+	limits := make(map[string]db.RegistryProvider, len(known))
+	for _, p := range known {
+		limits[p.Pubkey] = p
+	}
+
+	for _, p := range providers {
+		if p.MaxSpan > math.MaxUint32 {
+			return models.NewAppError(models.BadRequestErrorCode, "span is too large for provider "+p.Pubkey)
+		}
+
+		span := uint32(p.MaxSpan)
+		l := limits[strings.ToLower(p.Pubkey)]
+		effective, reason := s.providerSpan(span, l.MinSpan, l.MaxSpan)
+		if reason == "" && effective != span {
+			reason = fmt.Sprintf("span exceeds provider maximum of %d seconds", l.MaxSpan)
+		}
+
+		if reason != "" {
+			return models.NewAppError(models.BadRequestErrorCode, p.Pubkey+": "+reason)
+		}
+	}
+
+	return nil
+}
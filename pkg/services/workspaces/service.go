@@ -0,0 +1,405 @@
+package workspaces
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/xssnick/tonutils-go/address"
+	"golang.org/x/exp/utf8string"
+
+	"mytonstorage-backend/pkg/models"
+	v1 "mytonstorage-backend/pkg/models/api/v1"
+	"mytonstorage-backend/pkg/models/db"
+	"mytonstorage-backend/pkg/utils"
+)
+
+const (
+	RoleOwner    = v1.WorkspaceRoleOwner
+	RoleUploader = v1.WorkspaceRoleUploader
+	RoleViewer   = v1.WorkspaceRoleViewer
+
+	maxNameLength = 128
+)
+
+// roleLevels orders roles, a higher level includes permissions of all lower ones
+var roleLevels = map[string]int{
+	RoleViewer:   1,
+	RoleUploader: 2,
+	RoleOwner:    3,
+}
+
+type workspacesDb interface {
+	CreateWorkspace(ctx context.Context, name, ownerAddress string) (id int64, err error)
+	GetUserWorkspaces(ctx context.Context, userAddress string) (workspaces []db.Workspace, err error)
+	GetMemberRole(ctx context.Context, workspaceID int64, userAddress string) (role string, err error)
+	GetMembers(ctx context.Context, workspaceID int64) (members []db.WorkspaceMember, err error)
+	InviteMember(ctx context.Context, member db.WorkspaceMember) error
+	RemoveMember(ctx context.Context, workspaceID int64, userAddress string) (int64, error)
+
+	AttachBag(ctx context.Context, workspaceID int64, bagID, userAddress string) (int64, error)
+	GetWorkspaceBags(ctx context.Context, workspaceID int64) (bags []db.WorkspaceBag, err error)
+	RemoveWorkspaceBag(ctx context.Context, workspaceID int64, bagID string) (int64, error)
+	MarkWorkspaceBagAsPaid(ctx context.Context, workspaceID int64, bagID, storageContract string) (int64, error)
+	IsWorkspaceContract(ctx context.Context, workspaceID int64, storageContract string) (bool, error)
+}
+
+type service struct {
+	workspaces workspacesDb
+	logger     *slog.Logger
+}
+
+type Workspaces interface {
+	CreateWorkspace(ctx context.Context, userAddr string, req v1.CreateWorkspaceRequest) (info v1.WorkspaceInfo, err error)
+	GetUserWorkspaces(ctx context.Context, userAddr string) (list []v1.WorkspaceInfo, err error)
+	GetMembers(ctx context.Context, workspaceID int64, userAddr string) (members []v1.WorkspaceMember, err error)
+	InviteMember(ctx context.Context, workspaceID int64, userAddr string, req v1.InviteMemberRequest) error
+	RemoveMember(ctx context.Context, workspaceID int64, userAddr, memberAddr string) error
+
+	CheckAccess(ctx context.Context, workspaceID int64, userAddr string, role string) error
+	CheckContractAccess(ctx context.Context, workspaceID int64, userAddr, contractAddr string) error
+	AttachBag(ctx context.Context, workspaceID int64, bagID, userAddr string) error
+	GetBags(ctx context.Context, workspaceID int64, userAddr string) (bags []v1.WorkspaceBag, err error)
+	DeleteBag(ctx context.Context, workspaceID int64, bagID, userAddr string) error
+	MarkBagAsPaid(ctx context.Context, workspaceID int64, userAddr string, req v1.PaidBagRequest) error
+}
+
+func (s *service) CreateWorkspace(ctx context.Context, userAddr string, req v1.CreateWorkspaceRequest) (info v1.WorkspaceInfo, err error) {
+	log := s.logger.With(
+		slog.String("method", "CreateWorkspace"),
+		slog.String("user_address", userAddr),
+	)
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		err = models.NewAppError(models.BadRequestErrorCode, "workspace name is required")
+		return
+	}
+
+	if n := utf8string.NewString(name); n.RuneCount() > maxNameLength {
+		name = n.Slice(0, maxNameLength)
+	}
+
+	id, err := s.workspaces.CreateWorkspace(ctx, name, userAddr)
+	if err != nil {
+		log.Error("Failed to create workspace", slog.Any("error", err))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	info = v1.WorkspaceInfo{
+		ID:           id,
+		Name:         name,
+		OwnerAddress: userAddr,
+		Role:         RoleOwner,
+	}
+
+	log.Info("Workspace created", slog.Int64("workspace_id", id))
+
+	return
+}
+
+func (s *service) GetUserWorkspaces(ctx context.Context, userAddr string) (list []v1.WorkspaceInfo, err error) {
+	log := s.logger.With(
+		slog.String("method", "GetUserWorkspaces"),
+		slog.String("user_address", userAddr),
+	)
+
+	workspaces, err := s.workspaces.GetUserWorkspaces(ctx, userAddr)
+	if err != nil {
+		log.Error("Failed to get workspaces", slog.Any("error", err))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	list = make([]v1.WorkspaceInfo, 0, len(workspaces))
+	for _, w := range workspaces {
+		list = append(list, v1.WorkspaceInfo{
+			ID:           w.ID,
+			Name:         w.Name,
+			OwnerAddress: w.OwnerAddress,
+			Role:         w.Role,
+			CreatedAt:    w.CreatedAt,
+		})
+	}
+
+	return
+}
+
+func (s *service) GetMembers(ctx context.Context, workspaceID int64, userAddr string) (members []v1.WorkspaceMember, err error) {
+	log := s.logger.With(
+		slog.String("method", "GetMembers"),
+		slog.Int64("workspace_id", workspaceID),
+	)
+
+	if err = s.CheckAccess(ctx, workspaceID, userAddr, RoleViewer); err != nil {
+		return
+	}
+
+	list, err := s.workspaces.GetMembers(ctx, workspaceID)
+	if err != nil {
+		log.Error("Failed to get members", slog.Any("error", err))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	members = make([]v1.WorkspaceMember, 0, len(list))
+	for _, m := range list {
+		members = append(members, v1.WorkspaceMember{
+			Address:    m.UserAddress,
+			Role:       m.Role,
+			InvitedBy:  m.InvitedBy,
+			InvitedAt:  m.InvitedAt,
+			AcceptedAt: m.AcceptedAt,
+		})
+	}
+
+	return
+}
+
+func (s *service) InviteMember(ctx context.Context, workspaceID int64, userAddr string, req v1.InviteMemberRequest) (err error) {
+	log := s.logger.With(
+		slog.String("method", "InviteMember"),
+		slog.Int64("workspace_id", workspaceID),
+		slog.String("invitee", req.Address),
+	)
+
+	if err = s.CheckAccess(ctx, workspaceID, userAddr, RoleOwner); err != nil {
+		return
+	}
+
+	// Only one owner per workspace, ownership is not transferable through invitations
+	if req.Role != RoleUploader && req.Role != RoleViewer {
+		return models.NewAppError(models.BadRequestErrorCode, "invalid role")
+	}
+
+	invitee, err := utils.NormalizeUserAddress(req.Address)
+	if err != nil {
+		return models.NewAppError(models.BadRequestErrorCode, "invalid address")
+	}
+
+	if invitee == userAddr {
+		return models.NewAppError(models.BadRequestErrorCode, "can't invite yourself")
+	}
+
+	err = s.workspaces.InviteMember(ctx, db.WorkspaceMember{
+		WorkspaceID: workspaceID,
+		UserAddress: invitee,
+		Role:        req.Role,
+		InvitedBy:   userAddr,
+	})
+	if err != nil {
+		log.Error("Failed to invite member", slog.Any("error", err))
+		return models.NewAppError(models.InternalServerErrorCode, "")
+	}
+
+	log.Info("Member invited", slog.String("role", req.Role))
+
+	return nil
+}
+
+func (s *service) RemoveMember(ctx context.Context, workspaceID int64, userAddr, memberAddr string) (err error) {
+	log := s.logger.With(
+		slog.String("method", "RemoveMember"),
+		slog.Int64("workspace_id", workspaceID),
+		slog.String("member", memberAddr),
+	)
+
+	if err = s.CheckAccess(ctx, workspaceID, userAddr, RoleOwner); err != nil {
+		return
+	}
+
+	member, err := utils.NormalizeUserAddress(memberAddr)
+	if err != nil {
+		return models.NewAppError(models.BadRequestErrorCode, "invalid address")
+	}
+
+	cnt, err := s.workspaces.RemoveMember(ctx, workspaceID, member)
+	if err != nil {
+		log.Error("Failed to remove member", slog.Any("error", err))
+		return models.NewAppError(models.InternalServerErrorCode, "")
+	}
+
+	if cnt == 0 {
+		return models.NewAppError(models.NotFoundErrorCode, "member not found")
+	}
+
+	log.Info("Member removed")
+
+	return nil
+}
+
+func (s *service) CheckAccess(ctx context.Context, workspaceID int64, userAddr string, role string) error {
+	log := s.logger.With(
+		slog.String("method", "CheckAccess"),
+		slog.Int64("workspace_id", workspaceID),
+		slog.String("user_address", userAddr),
+	)
+
+	memberRole, err := s.workspaces.GetMemberRole(ctx, workspaceID, userAddr)
+	if err != nil {
+		log.Error("Failed to get member role", slog.Any("error", err))
+		return models.NewAppError(models.InternalServerErrorCode, "")
+	}
+
+	if memberRole == "" {
+		return models.NewAppError(models.NotFoundErrorCode, "workspace not found")
+	}
+
+	if roleLevels[memberRole] < roleLevels[role] {
+		return models.NewAppError(models.ForbiddenErrorCode, "not enough permissions")
+	}
+
+	return nil
+}
+
+func (s *service) CheckContractAccess(ctx context.Context, workspaceID int64, userAddr, contractAddr string) (err error) {
+	log := s.logger.With(
+		slog.String("method", "CheckContractAccess"),
+		slog.Int64("workspace_id", workspaceID),
+		slog.String("contract_address", contractAddr),
+	)
+
+	if err = s.CheckAccess(ctx, workspaceID, userAddr, RoleUploader); err != nil {
+		return
+	}
+
+	addr, err := address.ParseAddr(contractAddr)
+	if err != nil {
+		return models.NewAppError(models.BadRequestErrorCode, "invalid contract address")
+	}
+
+	exists, err := s.workspaces.IsWorkspaceContract(ctx, workspaceID, addr.String())
+	if err != nil {
+		log.Error("Failed to check workspace contract", slog.Any("error", err))
+		return models.NewAppError(models.InternalServerErrorCode, "")
+	}
+
+	if !exists {
+		return models.NewAppError(models.NotFoundErrorCode, "contract not found in workspace")
+	}
+
+	return nil
+}
+
+func (s *service) AttachBag(ctx context.Context, workspaceID int64, bagID, userAddr string) (err error) {
+	log := s.logger.With(
+		slog.String("method", "AttachBag"),
+		slog.Int64("workspace_id", workspaceID),
+		slog.String("bag_id", bagID),
+	)
+
+	if err = s.CheckAccess(ctx, workspaceID, userAddr, RoleUploader); err != nil {
+		return
+	}
+
+	cnt, err := s.workspaces.AttachBag(ctx, workspaceID, bagID, userAddr)
+	if err != nil {
+		log.Error("Failed to attach bag to workspace", slog.Any("error", err))
+		return models.NewAppError(models.InternalServerErrorCode, "")
+	}
+
+	if cnt == 0 {
+		return models.NewAppError(models.NotFoundErrorCode, "bag not found")
+	}
+
+	return nil
+}
+
+func (s *service) GetBags(ctx context.Context, workspaceID int64, userAddr string) (bags []v1.WorkspaceBag, err error) {
+	log := s.logger.With(
+		slog.String("method", "GetBags"),
+		slog.Int64("workspace_id", workspaceID),
+	)
+
+	if err = s.CheckAccess(ctx, workspaceID, userAddr, RoleViewer); err != nil {
+		return
+	}
+
+	list, err := s.workspaces.GetWorkspaceBags(ctx, workspaceID)
+	if err != nil {
+		log.Error("Failed to get workspace bags", slog.Any("error", err))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	bags = make([]v1.WorkspaceBag, 0, len(list))
+	for _, b := range list {
+		bags = append(bags, v1.WorkspaceBag{
+			BagID:           b.BagID,
+			UploadedBy:      b.UserAddress,
+			ContractAddress: b.StorageContract,
+			Description:     b.Description,
+			Size:            b.Size,
+			CreatedAt:       b.CreatedAt,
+		})
+	}
+
+	return
+}
+
+func (s *service) DeleteBag(ctx context.Context, workspaceID int64, bagID, userAddr string) (err error) {
+	log := s.logger.With(
+		slog.String("method", "DeleteBag"),
+		slog.Int64("workspace_id", workspaceID),
+		slog.String("bag_id", bagID),
+	)
+
+	if err = s.CheckAccess(ctx, workspaceID, userAddr, RoleUploader); err != nil {
+		return
+	}
+
+	cnt, err := s.workspaces.RemoveWorkspaceBag(ctx, workspaceID, bagID)
+	if err != nil {
+		log.Error("Failed to remove workspace bag", slog.Any("error", err))
+		return models.NewAppError(models.InternalServerErrorCode, "")
+	}
+
+	if cnt == 0 {
+		return models.NewAppError(models.NotFoundErrorCode, "bag not found")
+	}
+
+	// NOTE: File will be removed automatically by RemoveUnpaidFiles worker
+	log.Info("Workspace bag marked to be deleted", slog.String("user_address", userAddr))
+
+	return nil
+}
+
+func (s *service) MarkBagAsPaid(ctx context.Context, workspaceID int64, userAddr string, req v1.PaidBagRequest) (err error) {
+	log := s.logger.With(
+		slog.String("method", "MarkBagAsPaid"),
+		slog.Int64("workspace_id", workspaceID),
+		slog.String("bag_id", req.BagID),
+	)
+
+	if err = s.CheckAccess(ctx, workspaceID, userAddr, RoleUploader); err != nil {
+		return
+	}
+
+	addr, err := address.ParseAddr(req.StorageContract)
+	if err != nil {
+		log.Error("Failed to parse storage contract address", "error", err)
+		return models.NewAppError(models.BadRequestErrorCode, "invalid contract address")
+	}
+
+	cnt, err := s.workspaces.MarkWorkspaceBagAsPaid(ctx, workspaceID, req.BagID, addr.String())
+	if err != nil {
+		log.Error("Failed to mark workspace bag as paid", slog.Any("error", err))
+		return models.NewAppError(models.InternalServerErrorCode, "")
+	}
+
+	if cnt == 0 {
+		return models.NewAppError(models.NotFoundErrorCode, "bag not found")
+	}
+
+	log.Info("Workspace bag marked as paid", slog.String("user_address", userAddr))
+
+	return nil
+}
+
+func NewService(workspaces workspacesDb, logger *slog.Logger) Workspaces {
+	return &service{
+		workspaces: workspaces,
+		logger:     logger,
+	}
+}
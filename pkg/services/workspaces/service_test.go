@@ -0,0 +1,79 @@
+package workspaces
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/xssnick/tonutils-go/address"
+
+	"mytonstorage-backend/pkg/models"
+	v1 "mytonstorage-backend/pkg/models/api/v1"
+	"mytonstorage-backend/pkg/models/db"
+)
+
+type memoryMembers struct {
+	workspacesDb
+	roles map[string]string
+}
+
+func (m *memoryMembers) GetMemberRole(ctx context.Context, workspaceID int64, userAddress string) (string, error) {
+	return m.roles[userAddress], nil
+}
+
+func (m *memoryMembers) InviteMember(ctx context.Context, member db.WorkspaceMember) error {
+	m.roles[member.UserAddress] = member.Role
+	return nil
+}
+
+func (m *memoryMembers) RemoveMember(ctx context.Context, workspaceID int64, userAddress string) (int64, error) {
+	if _, ok := m.roles[userAddress]; !ok {
+		return 0, nil
+	}
+	delete(m.roles, userAddress)
+	return 1, nil
+}
+
+// sessionAddress returns the address the way the session of the wallet carries it after a TonConnect login
+func sessionAddress(t *testing.T, wallet *address.Address) string {
+	t.Helper()
+
+	a, err := address.ParseRawAddr(wallet.StringRaw())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.String()
+}
+
+// Members are invited and removed by any address form and end up stored in the session form,
+// so invitations are accepted at login and the owner can't invite their own wallet
+func TestMemberAddressesMatchSessions(t *testing.T) {
+	owner := address.NewAddress(0, 0, make([]byte, 32))
+	memberData := make([]byte, 32)
+	memberData[0] = 1
+	member := address.NewAddress(0, 0, memberData)
+
+	ownerSession := sessionAddress(t, owner)
+	repo := &memoryMembers{roles: map[string]string{ownerSession: RoleOwner}}
+	s := &service{workspaces: repo, logger: slog.New(slog.DiscardHandler)}
+	ctx := context.Background()
+
+	err := s.InviteMember(ctx, 1, ownerSession, v1.InviteMemberRequest{Address: owner.Bounce(false).String(), Role: RoleViewer})
+	var appErr *models.AppError
+	if !errors.As(err, &appErr) || appErr.Code != models.BadRequestErrorCode {
+		t.Fatalf("self invite error = %v, want bad request", err)
+	}
+
+	if err = s.InviteMember(ctx, 1, ownerSession, v1.InviteMemberRequest{Address: member.Bounce(false).Testnet(true).String(), Role: RoleViewer}); err != nil {
+		t.Fatal(err)
+	}
+
+	if role := repo.roles[sessionAddress(t, member)]; role != RoleViewer {
+		t.Fatalf("invitation is not stored under the member session address, roles: %v", repo.roles)
+	}
+
+	if err = s.RemoveMember(ctx, 1, ownerSession, member.StringRaw()); err != nil {
+		t.Fatalf("RemoveMember() error = %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"github.com/xssnick/tonutils-go/address"
+)
+
+// UserAddress formats a wallet address the way sessions carry it and the database stores it:
+// user-friendly, bounceable, mainnet ("EQ...")
+func UserAddress(a *address.Address) string {
+	return a.Bounce(true).Testnet(false).String()
+}
+
+// NormalizeUserAddress brings raw and user-friendly wallet addresses to the form used in sessions
+func NormalizeUserAddress(addr string) (string, error) {
+	a, err := address.ParseAddr(addr)
+	if err != nil {
+		a, err = address.ParseRawAddr(addr)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return UserAddress(a), nil
+}
@@ -7,6 +7,8 @@ import (
 )
 
 type repository interface {
+	CleanOldRatesHistory(ctx context.Context, days int) (int64, error)
+	CleanOldProofAudits(ctx context.Context, days int) (int64, error)
 }
 
 type cleanerWorker struct {
@@ -30,6 +32,20 @@ func (w *cleanerWorker) CleanupOldData(ctx context.Context) (interval time.Durat
 
 	interval = successInterval
 
+	if removed, err := w.repo.CleanOldRatesHistory(ctx, w.days); err != nil {
+		log.Error("failed to clean old rates history", slog.Int("days", w.days), slog.String("err", err.Error()))
+		interval = failureInterval
+	} else if removed > 0 {
+		log.Info("cleaned old rates history", slog.Int64("removed", removed))
+	}
+
+	if removed, err := w.repo.CleanOldProofAudits(ctx, w.days); err != nil {
+		log.Error("failed to clean old proof audits", slog.Int("days", w.days), slog.String("err", err.Error()))
+		interval = failureInterval
+	} else if removed > 0 {
+		log.Info("cleaned old proof audits", slog.Int64("removed", removed))
+	}
+
 	// if removed, err := w.repo.CleanOldProvidersHistory(ctx, w.days); err != nil {
 	// 	log.Error("failed to clean old providers history", slog.Int("days", w.days), slog.String("err", err.Error()))
 	// 	interval = failureInterval
@@ -0,0 +1,329 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"mytonstorage-backend/pkg/models"
+	v1 "mytonstorage-backend/pkg/models/api/v1"
+)
+
+const (
+	// pausedParam keeps names of paused workers as a json array, so pauses survive restarts and apply to all instances
+	pausedParam = "workers_paused"
+	// Paused state is reread from system.params not more often than this
+	pausedCacheTTL = 5 * time.Second
+)
+
+type params interface {
+	GetParam(ctx context.Context, key string) (value string, err error)
+	SetParam(ctx context.Context, key string, value string) (err error)
+}
+
+// handle controls a single worker loop and keeps its run state
+type handle struct {
+	name    string
+	fn      workerFunc
+	trigger chan struct{}
+
+	mu          sync.Mutex
+	leader      bool
+	leaderSince time.Time
+	running     bool
+	forced      bool
+	lastRunAt   time.Time
+	lastSuccess time.Time
+	lastError   string
+	lastErrorAt time.Time
+	nextRunAt   time.Time
+}
+
+// begin records leadership of the instance and reports whether the worker should run now.
+// A triggered run is made even when the worker is paused.
+func (h *handle) begin(leading, paused bool) (run bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if leading && !h.leader {
+		h.leaderSince = time.Now()
+	}
+	h.leader = leading
+	run = leading && (!paused || h.forced)
+	h.forced = false
+	h.running = run
+
+	return
+}
+
+func (h *handle) finish(startedAt time.Time, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.running = false
+	h.lastRunAt = startedAt
+	if err != nil {
+		h.lastError = err.Error()
+		h.lastErrorAt = time.Now()
+	} else {
+		h.lastSuccess = time.Now()
+	}
+}
+
+func (h *handle) scheduled(at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextRunAt = at
+}
+
+// wake interrupts the wait before the next run, forced runs the worker even if it is paused
+func (h *handle) wake(forced bool) {
+	h.mu.Lock()
+	if forced {
+		h.forced = true
+	}
+	h.mu.Unlock()
+
+	select {
+	case h.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (h *handle) status(paused bool) (s v1.WorkerStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s = v1.WorkerStatus{
+		Name:   h.name,
+		Paused: paused,
+		Leader: h.leader,
+	}
+
+	switch {
+	case h.running:
+		s.Status = v1.WorkerStatusRunning
+	case paused:
+		s.Status = v1.WorkerStatusPaused
+	case !h.leader:
+		s.Status = v1.WorkerStatusStandby
+	default:
+		s.Status = v1.WorkerStatusIdle
+	}
+
+	if !h.lastRunAt.IsZero() {
+		s.LastRunAt = h.lastRunAt.Unix()
+	}
+	if !h.lastSuccess.IsZero() {
+		s.LastSuccessAt = h.lastSuccess.Unix()
+	}
+	if !h.lastErrorAt.IsZero() {
+		s.LastError = h.lastError
+		s.LastErrorAt = h.lastErrorAt.Unix()
+	}
+	if h.leader {
+		s.LeaderSince = h.leaderSince.Unix()
+		if !h.nextRunAt.IsZero() {
+			s.NextRunAt = h.nextRunAt.Unix()
+		}
+	}
+
+	return
+}
+
+func (w *worker) register(name string, fn workerFunc) *handle {
+	h := &handle{
+		name:    name,
+		fn:      fn,
+		trigger: make(chan struct{}, 1),
+	}
+
+	w.handlesMu.Lock()
+	w.handles = append(w.handles, h)
+	w.handlesMu.Unlock()
+
+	// Every registered worker is run, its loop is done when run returns
+	w.running.Add(1)
+
+	return h
+}
+
+func (w *worker) handle(name string) (h *handle, err error) {
+	w.handlesMu.RLock()
+	defer w.handlesMu.RUnlock()
+
+	for _, h = range w.handles {
+		if h.name == name {
+			return
+		}
+	}
+
+	err = models.NewAppError(models.NotFoundErrorCode, "worker not found")
+
+	return nil, err
+}
+
+// isPaused uses the cached paused state, if reloading fails the last known state is returned along with the error
+func (w *worker) isPaused(ctx context.Context, name string) (paused bool, err error) {
+	w.pausedMu.Lock()
+	defer w.pausedMu.Unlock()
+
+	if time.Since(w.pausedLoadedAt) > pausedCacheTTL {
+		var list map[string]struct{}
+		if list, err = w.loadPaused(ctx); err == nil {
+			w.paused = list
+			w.pausedLoadedAt = time.Now()
+		}
+	}
+
+	_, paused = w.paused[name]
+
+	return
+}
+
+func (w *worker) loadPaused(ctx context.Context) (paused map[string]struct{}, err error) {
+	value, err := w.params.GetParam(ctx, pausedParam)
+	if err != nil {
+		return
+	}
+
+	var names []string
+	if value != "" {
+		if err = json.Unmarshal([]byte(value), &names); err != nil {
+			return
+		}
+	}
+
+	paused = make(map[string]struct{}, len(names))
+	for _, name := range names {
+		paused[name] = struct{}{}
+	}
+
+	return
+}
+
+func (w *worker) setPaused(ctx context.Context, name string, pause bool) (err error) {
+	w.pausedMu.Lock()
+	defer w.pausedMu.Unlock()
+
+	paused, err := w.loadPaused(ctx)
+	if err != nil {
+		return
+	}
+
+	if pause {
+		paused[name] = struct{}{}
+	} else {
+		delete(paused, name)
+	}
+
+	names := make([]string, 0, len(paused))
+	for n := range paused {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+
+	value, err := json.Marshal(names)
+	if err != nil {
+		return
+	}
+
+	if err = w.params.SetParam(ctx, pausedParam, string(value)); err != nil {
+		return
+	}
+
+	w.paused = paused
+	w.pausedLoadedAt = time.Now()
+
+	return
+}
+
+func (w *worker) GetWorkers(ctx context.Context) (workers []v1.WorkerStatus, err error) {
+	w.pausedMu.Lock()
+	paused, err := w.loadPaused(ctx)
+	if err == nil {
+		w.paused = paused
+		w.pausedLoadedAt = time.Now()
+	}
+	w.pausedMu.Unlock()
+
+	if err != nil {
+		w.logger.Error("failed to load paused workers", slog.String("error", err.Error()))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	w.handlesMu.RLock()
+	defer w.handlesMu.RUnlock()
+
+	workers = make([]v1.WorkerStatus, 0, len(w.handles))
+	for _, h := range w.handles {
+		_, p := paused[h.name]
+		workers = append(workers, h.status(p))
+	}
+
+	return
+}
+
+func (w *worker) PauseWorker(ctx context.Context, name string) (err error) {
+	return w.changePaused(ctx, name, true)
+}
+
+func (w *worker) ResumeWorker(ctx context.Context, name string) (err error) {
+	return w.changePaused(ctx, name, false)
+}
+
+func (w *worker) changePaused(ctx context.Context, name string, pause bool) (err error) {
+	log := w.logger.With(
+		slog.String("method", "changePaused"),
+		slog.String("worker", name),
+		slog.Bool("pause", pause),
+	)
+
+	h, err := w.handle(name)
+	if err != nil {
+		return
+	}
+
+	if err = w.setPaused(ctx, name, pause); err != nil {
+		log.Error("failed to save paused workers", slog.String("error", err.Error()))
+		err = models.NewAppError(models.InternalServerErrorCode, "")
+		return
+	}
+
+	if !pause {
+		h.wake(false)
+	}
+
+	log.Info("worker paused state updated")
+
+	return
+}
+
+// TriggerWorker runs the worker right away, even a paused one.
+// Only the instance leading the worker can run it, others return a conflict.
+func (w *worker) TriggerWorker(ctx context.Context, name string) (err error) {
+	h, err := w.handle(name)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	leader := h.leader
+	h.mu.Unlock()
+
+	if !leader {
+		err = models.NewAppError(models.ConflictErrorCode, "worker is run by another instance")
+		return
+	}
+
+	h.wake(true)
+
+	w.logger.Info("worker run triggered", slog.String("worker", name))
+
+	return
+}
@@ -0,0 +1,95 @@
+package filesworker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// providerLimiter bounds in flight requests and request rate per provider.
+// It is shared by all workers, so a provider with many bags is not flooded by parallel checks.
+type providerLimiter struct {
+	mu          sync.Mutex
+	concurrency int
+	interval    time.Duration
+	providers   map[string]*providerSlot
+}
+
+type providerSlot struct {
+	sem  chan struct{}
+	next time.Time
+	// users are requests holding or waiting for the slot, guarded by the limiter mutex
+	users int
+}
+
+// acquire waits for a free slot of the provider, release must be called when the request is done
+func (l *providerLimiter) acquire(ctx context.Context, provider string) (release func(), err error) {
+	l.mu.Lock()
+	slot, ok := l.providers[provider]
+	if !ok {
+		l.prune(time.Now())
+		slot = &providerSlot{sem: make(chan struct{}, l.concurrency)}
+		l.providers[provider] = slot
+	}
+	slot.users++
+	l.mu.Unlock()
+
+	leave := func() {
+		l.mu.Lock()
+		slot.users--
+		l.mu.Unlock()
+	}
+
+	select {
+	case slot.sem <- struct{}{}:
+	case <-ctx.Done():
+		leave()
+		return nil, ctx.Err()
+	}
+
+	release = func() {
+		<-slot.sem
+		leave()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	at := slot.next
+	if at.Before(now) {
+		at = now
+	}
+	slot.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait := time.Until(at); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	return release, nil
+}
+
+// prune drops slots nobody uses whose rate interval has passed, a new slot of the provider behaves the same.
+// It runs whenever a slot is added, so the map only holds recently requested providers.
+func (l *providerLimiter) prune(now time.Time) {
+	for provider, slot := range l.providers {
+		if slot.users == 0 && !slot.next.After(now) {
+			delete(l.providers, provider)
+		}
+	}
+}
+
+func newProviderLimiter(concurrency int, interval time.Duration) *providerLimiter {
+	return &providerLimiter{
+		concurrency: max(concurrency, 1),
+		interval:    interval,
+		providers:   make(map[string]*providerSlot),
+	}
+}
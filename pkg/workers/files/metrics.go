@@ -80,6 +80,17 @@ func (m *metricsMiddleware) CollectContractProvidersToNotify(ctx context.Context
 	return m.worker.CollectContractProvidersToNotify(ctx)
 }
 
+func (m *metricsMiddleware) CollectBacklog(ctx context.Context) (interval time.Duration, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"CollectBacklog", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.worker.CollectBacklog(ctx)
+}
+
 func NewMetrics(reqCount *prometheus.CounterVec, reqDuration *prometheus.HistogramVec, worker Worker) Worker {
 	return &metricsMiddleware{
 		reqCount:    reqCount,
@@ -0,0 +1,463 @@
+package filesworker
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xssnick/tonutils-go/address"
+
+	providersclient "mytonstorage-backend/pkg/clients/providers"
+	tonclient "mytonstorage-backend/pkg/clients/ton"
+	tonstorage "mytonstorage-backend/pkg/clients/ton-storage"
+	"mytonstorage-backend/pkg/models/db"
+)
+
+// memoryDb keeps bag relations and provider notifications the way files and providers repositories do
+type memoryDb struct {
+	mu            sync.Mutex
+	relations     map[string]*memoryRelation
+	notifications []*memoryNotification
+	audits        []db.ProofAudit
+}
+
+type memoryRelation struct {
+	contract string
+	size     uint64
+	state    string
+	attempts int
+}
+
+type memoryNotification struct {
+	db.ProviderNotification
+	notified bool
+}
+
+func newMemoryDb() *memoryDb {
+	return &memoryDb{relations: make(map[string]*memoryRelation)}
+}
+
+func (m *memoryDb) state(bagID string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.relations[bagID].state
+}
+
+func (m *memoryDb) RemoveUnusedBags(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func (m *memoryDb) RemoveUnpaidBagsRelations(ctx context.Context, sec uint64) ([]string, error) {
+	return nil, nil
+}
+
+func (m *memoryDb) GetRetentionCandidates(ctx context.Context, limit int, sec uint64, maxNotifyAttempts int, maxDownloadChecks int) (candidates []db.RetentionCandidate, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	byBag := make(map[string]*db.RetentionCandidate)
+	finished := make(map[string]bool)
+	for _, n := range m.notifications {
+		c, ok := byBag[n.BagID]
+		if !ok {
+			c = &db.RetentionCandidate{BagID: n.BagID}
+			byBag[n.BagID] = c
+			finished[n.BagID] = true
+		}
+
+		c.Providers++
+		if n.notified {
+			c.Notified++
+		}
+		if n.Downloaded == n.Size {
+			c.Replicas++
+		}
+
+		finished[n.BagID] = finished[n.BagID] && ((!n.notified && n.NotifyAttempts >= maxNotifyAttempts) ||
+			(n.notified && n.DownloadChecks >= maxDownloadChecks) ||
+			n.Downloaded == n.Size)
+	}
+
+	for bagID, c := range byBag {
+		if finished[bagID] {
+			candidates = append(candidates, *c)
+		}
+	}
+
+	return
+}
+
+func (m *memoryDb) SaveRetentionDecisions(ctx context.Context, decisions []db.RetentionDecision) error {
+	return nil
+}
+
+func (m *memoryDb) ReleaseBags(ctx context.Context, bagIDs []string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.notifications = slices.DeleteFunc(m.notifications, func(n *memoryNotification) bool {
+		return slices.Contains(bagIDs, n.BagID)
+	})
+
+	return bagIDs, nil
+}
+
+func (m *memoryDb) GetNotifyInfo(ctx context.Context, limit int, notifyAttempts int) (resp []db.BagStorageContract, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for bagID, r := range m.relations {
+		if r.state == db.BagStatePaid && r.attempts < notifyAttempts {
+			resp = append(resp, db.BagStorageContract{BagID: bagID, StorageContract: r.contract, FilesSize: r.size, NotifyAttempts: r.attempts})
+		}
+	}
+
+	return
+}
+
+func (m *memoryDb) IncreaseAttempts(ctx context.Context, bags []db.BagStorageContract) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, b := range bags {
+		m.relations[b.BagID].attempts++
+	}
+
+	return nil
+}
+
+// TransitBags applies only transitions allowed by the lifecycle, as the repository does
+func (m *memoryDb) TransitBags(ctx context.Context, transitions []db.BagTransition) (cnt int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	edges := db.BagStateEdges()
+	for _, t := range transitions {
+		r, ok := m.relations[t.BagID]
+		if !ok || (t.StorageContract != "" && t.StorageContract != r.contract) {
+			continue
+		}
+
+		if slices.Contains(edges, db.BagStateEdge{From: r.state, To: t.State}) {
+			r.state = t.State
+			cnt++
+		}
+	}
+
+	return
+}
+
+func (m *memoryDb) GetBacklog(ctx context.Context, maxNotifyAttempts int, maxDownloadChecks int) (db.Backlog, error) {
+	return db.Backlog{}, nil
+}
+
+func (m *memoryDb) AddProviderToNotifyQueue(ctx context.Context, notifications []db.ProviderNotification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, n := range notifications {
+		m.notifications = append(m.notifications, &memoryNotification{ProviderNotification: n})
+	}
+
+	return nil
+}
+
+func (m *memoryDb) GetProvidersInProgress(ctx context.Context, limit int, maxDownloadChecks int) (notifications []db.ProviderNotification, err error) {
+	return m.filter(func(n *memoryNotification) bool {
+		return n.notified && n.Size > n.Downloaded && n.DownloadChecks <= maxDownloadChecks
+	}), nil
+}
+
+func (m *memoryDb) GetProvidersToNotify(ctx context.Context, limit int, notifyAttempts int) (notifications []db.ProviderNotification, err error) {
+	return m.filter(func(n *memoryNotification) bool {
+		return !n.notified && n.NotifyAttempts <= notifyAttempts
+	}), nil
+}
+
+func (m *memoryDb) filter(match func(n *memoryNotification) bool) (notifications []db.ProviderNotification) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, n := range m.notifications {
+		if match(n) {
+			notifications = append(notifications, n.ProviderNotification)
+		}
+	}
+
+	return
+}
+
+func (m *memoryDb) update(notifications []db.ProviderNotification, apply func(n *memoryNotification, u db.ProviderNotification)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range notifications {
+		for _, n := range m.notifications {
+			if n.StorageContract == u.StorageContract && n.ProviderPubkey == u.ProviderPubkey {
+				apply(n, u)
+			}
+		}
+	}
+}
+
+func (m *memoryDb) IncreaseDownloadChecks(ctx context.Context, notifications []db.ProviderNotification) error {
+	m.update(notifications, func(n *memoryNotification, u db.ProviderNotification) {
+		n.DownloadChecks++
+		n.Downloaded = u.Downloaded
+	})
+
+	return nil
+}
+
+func (m *memoryDb) IncreaseNotifyAttempts(ctx context.Context, notifications []db.ProviderNotification) error {
+	m.update(notifications, func(n *memoryNotification, u db.ProviderNotification) {
+		n.NotifyAttempts++
+	})
+
+	return nil
+}
+
+func (m *memoryDb) MarkAsNotified(ctx context.Context, notifications []db.ProviderNotification) error {
+	m.update(notifications, func(n *memoryNotification, u db.ProviderNotification) {
+		n.NotifyAttempts++
+		n.notified = true
+	})
+
+	return nil
+}
+
+func (m *memoryDb) AddProviders(ctx context.Context, pubkeys []string) (int64, error) {
+	return int64(len(pubkeys)), nil
+}
+
+func (m *memoryDb) GetProvidersPolicy(ctx context.Context) ([]db.ProviderPolicy, error) {
+	return nil, nil
+}
+
+func (m *memoryDb) AddProofAudits(ctx context.Context, audits []db.ProofAudit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.audits = append(m.audits, audits...)
+
+	return nil
+}
+
+// memoryNode is the local storage node keeping bags generated by the simulator
+type memoryNode struct {
+	mu      sync.Mutex
+	bags    map[string]*tonstorage.BagDetailed
+	removed []string
+}
+
+func (n *memoryNode) GetBag(ctx context.Context, bagID string) (*tonstorage.BagDetailed, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.bags[bagID], nil
+}
+
+func (n *memoryNode) RemoveBag(ctx context.Context, bagID string, withFiles bool) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.bags, bagID)
+	n.removed = append(n.removed, bagID)
+
+	return nil
+}
+
+// memoryContracts returns providers registered in contracts
+type memoryContracts map[string][]tonclient.Provider
+
+func (c memoryContracts) GetProvidersInfo(ctx context.Context, addrs []string) (contractsProviders []tonclient.StorageContractProviders, err error) {
+	for _, addr := range addrs {
+		contractsProviders = append(contractsProviders, tonclient.StorageContractProviders{Address: addr, Providers: c[addr]})
+	}
+
+	return
+}
+
+type pipeline struct {
+	db        *memoryDb
+	node      *memoryNode
+	contracts memoryContracts
+	sim       *providersclient.Simulator
+	worker    *filesWorker
+}
+
+func newPipeline() *pipeline {
+	p := &pipeline{
+		db:        newMemoryDb(),
+		node:      &memoryNode{bags: make(map[string]*tonstorage.BagDetailed)},
+		contracts: make(memoryContracts),
+		sim:       providersclient.NewSimulator(1),
+	}
+
+	p.worker = NewWorker(
+		p.db,
+		p.db,
+		p.node,
+		p.sim,
+		p.contracts,
+		0,
+		0,
+		RetryPolicy{ResolveAttempts: 1, NotifyAttempts: 1, DownloadChecks: 1},
+		RetentionPolicy{MinReplicas: 1},
+		prometheus.NewCounterVec(prometheus.CounterOpts{Name: "proof_checks"}, []string{"provider", "result"}),
+		prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "backlog"}, []string{"queue"}),
+		4,
+		1,
+		0,
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+	).(*filesWorker)
+
+	return p
+}
+
+// upload stages a bag on the node, the bag content is the one simulated providers are going to store
+func (p *pipeline) upload(t *testing.T, size uint64) (bagID string, contract *address.Address) {
+	t.Helper()
+
+	bagHash := make([]byte, 32)
+	bagHash[0] = byte(len(p.db.relations) + 1)
+	bagID = hex.EncodeToString(bagHash)
+	contract = address.NewAddress(0, 0, bagHash)
+
+	bag := p.sim.AddContract(contract, size)
+	p.node.bags[bagID] = &tonstorage.BagDetailed{
+		Bag:          tonstorage.Bag{BagID: bagID, Size: size, InfoLoaded: true},
+		BagPiecesNum: bag.PiecesNum,
+		PieceSize:    bag.PieceSize,
+		BagSize:      bag.Size,
+		MerkleHash:   hex.EncodeToString(bag.MerkleHash),
+	}
+	p.db.relations[bagID] = &memoryRelation{size: size, state: db.BagStateUploaded}
+
+	return
+}
+
+// pay deploys the contract with providers, as MarkBagAsPaid does once the user sends the contract
+func (p *pipeline) pay(t *testing.T, bagID string, contract *address.Address, providers ...providersclient.SimulatedProvider) {
+	t.Helper()
+
+	for i, sp := range providers {
+		seed := make([]byte, ed25519.SeedSize)
+		seed[0], seed[1] = byte(len(p.contracts)+1), byte(i+1)
+		pubkey := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+
+		p.sim.AddProvider(pubkey, sp)
+		p.contracts[contract.String()] = append(p.contracts[contract.String()], tonclient.Provider{Key: string(pubkey)})
+	}
+
+	p.db.relations[bagID].contract = contract.String()
+	if _, err := p.db.TransitBags(context.Background(), []db.BagTransition{{BagID: bagID, State: db.BagStatePaid}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Every step of the pipeline is run once, a single attempt is allowed for each of them,
+// so failed bags are released by the retention policy right away.
+func TestPipelineWithSimulatedProviders(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   providersclient.SimulatedProvider
+		unloaded   bool
+		notified   string
+		downloaded string
+		released   string
+		audit      string
+	}{
+		{
+			name:       "replicated",
+			provider:   providersclient.SimulatedProvider{},
+			notified:   db.BagStateDownloading,
+			downloaded: db.BagStateReplicated,
+			released:   db.BagStateReleased,
+		},
+		{
+			name:       "invalid proofs",
+			provider:   providersclient.SimulatedProvider{InvalidProofRate: 1},
+			notified:   db.BagStateNotifying,
+			downloaded: db.BagStateNotifying,
+			released:   db.BagStateNotifyFailed,
+			audit:      "failed to parse proof boc",
+		},
+		{
+			name:       "bag info not loaded on node",
+			provider:   providersclient.SimulatedProvider{},
+			unloaded:   true,
+			notified:   db.BagStateNotifying,
+			downloaded: db.BagStateNotifying,
+			released:   db.BagStateNotifyFailed,
+			audit:      "unverified",
+		},
+		{
+			name:       "unavailable provider",
+			provider:   providersclient.SimulatedProvider{FailureRate: 1},
+			notified:   db.BagStateNotifying,
+			downloaded: db.BagStateNotifying,
+			released:   db.BagStateNotifyFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPipeline()
+			w := p.worker
+
+			bagID, contract := p.upload(t, 5<<20+17)
+			if got := p.db.state(bagID); got != db.BagStateUploaded {
+				t.Fatalf("upload: bag state %q, want %q", got, db.BagStateUploaded)
+			}
+			if tt.unloaded {
+				p.node.bags[bagID].InfoLoaded = false
+			}
+
+			p.pay(t, bagID, contract, tt.provider)
+			if got := p.db.state(bagID); got != db.BagStatePaid {
+				t.Fatalf("pay: bag state %q, want %q", got, db.BagStatePaid)
+			}
+
+			steps := []struct {
+				name string
+				fn   func(ctx context.Context) (time.Duration, error)
+				want string
+			}{
+				{"resolve", w.CollectContractProvidersToNotify, db.BagStateProvidersResolved},
+				{"notify", w.TriggerProvidersDownload, tt.notified},
+				{"download", w.DownloadChecker, tt.downloaded},
+				{"cleanup", w.RemoveNotifiedFiles, tt.released},
+			}
+
+			for _, s := range steps {
+				if _, err := s.fn(context.Background()); err != nil {
+					t.Fatalf("%s: %v", s.name, err)
+				}
+
+				if got := p.db.state(bagID); got != s.want {
+					t.Fatalf("%s: bag state %q, want %q", s.name, got, s.want)
+				}
+			}
+
+			if !slices.Contains(p.node.removed, bagID) {
+				t.Fatal("bag is not removed from the node")
+			}
+
+			for _, a := range p.db.audits {
+				if a.Valid != (tt.audit == "") || !strings.Contains(a.Error, tt.audit) {
+					t.Fatalf("proof audit valid %v, error %q, want %q", a.Valid, a.Error, tt.audit)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package filesworker
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+// verifyPieceProof checks a provider proof the same way tonutils-storage checks pieces from peers:
+// the proof must be a merkle proof of the bag merkle tree and must contain the unpruned branch of the piece.
+func verifyPieceProof(proof []byte, merkleHash []byte, piecesNum uint32, piece uint32) error {
+	if piece >= piecesNum {
+		return fmt.Errorf("piece is out of range %d/%d", piece, piecesNum)
+	}
+
+	root, err := cell.FromBOC(proof)
+	if err != nil {
+		return fmt.Errorf("failed to parse proof boc: %w", err)
+	}
+
+	if err = cell.CheckProof(root, merkleHash); err != nil {
+		return fmt.Errorf("proof does not match merkle hash: %w", err)
+	}
+
+	tree, err := root.PeekRef(0)
+	if err != nil {
+		return err
+	}
+
+	depth := 0
+	if piecesNum > 1 {
+		depth = bits.Len32(piecesNum - 1)
+	}
+
+	for i := depth - 1; i >= 0; i-- {
+		ref := 0
+		if piece&(1<<i) != 0 {
+			ref = 1
+		}
+
+		if tree, err = tree.PeekRef(ref); err != nil {
+			return fmt.Errorf("no branch for piece %d: %w", piece, err)
+		}
+	}
+
+	if tree.GetType() != cell.OrdinaryCellType || len(tree.ToRawUnsafe().Data) != 32 {
+		return fmt.Errorf("branch of piece %d is pruned", piece)
+	}
+
+	return nil
+}
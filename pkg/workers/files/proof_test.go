@@ -0,0 +1,120 @@
+package filesworker
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	leveldbstorage "github.com/syndtr/goleveldb/leveldb/storage"
+	storagedb "github.com/xssnick/tonutils-storage/db"
+	tstorage "github.com/xssnick/tonutils-storage/storage"
+)
+
+// newTestTorrent creates a bag the same way the storage daemon does, so proofs are exactly the ones providers return
+func newTestTorrent(t *testing.T, size int, pieceSize int) *tstorage.Torrent {
+	t.Helper()
+
+	dir := t.TempDir()
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	if err := os.WriteFile(filepath.Join(dir, "data.bin"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ldb, err := leveldb.Open(leveldbstorage.NewMemStorage(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ldb.Close() })
+
+	st, err := storagedb.NewStorage(ldb, nil, pieceSize, true, true, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := st.GetAllFilesRefsInDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := &tstorage.TorrentHeader{
+		DirNameSize: uint32(len(filepath.Base(dir))),
+		DirName:     []byte(filepath.Base(dir)),
+	}
+
+	torrent, err := tstorage.CreateTorrentWithInitialHeader(context.Background(), filepath.Dir(dir), "test", header, st, nil, files, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return torrent
+}
+
+func TestVerifyPieceProof(t *testing.T) {
+	// 21 pieces with the header, the merkle tree is padded up to 32 leaves
+	torrent := newTestTorrent(t, 20<<10, 1<<10)
+	info := torrent.Info
+	piecesNum := info.PiecesNum()
+	if piecesNum < 3 {
+		t.Fatalf("bag has %d pieces, the test needs at least 3", piecesNum)
+	}
+
+	proof := func(piece uint32) []byte {
+		p, err := torrent.GetPieceProof(piece)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	last := piecesNum - 1
+	otherHash := bytes.Clone(info.RootHash)
+	otherHash[0] ^= 0xff
+
+	tests := []struct {
+		name       string
+		proof      []byte
+		merkleHash []byte
+		piece      uint32
+		valid      bool
+	}{
+		{"first piece", proof(0), info.RootHash, 0, true},
+		{"middle piece", proof(piecesNum / 2), info.RootHash, piecesNum / 2, true},
+		{"last piece", proof(last), info.RootHash, last, true},
+		{"proof of another piece", proof(0), info.RootHash, 2, false},
+		{"proof of another bag", proof(0), otherHash, 0, false},
+		{"piece out of range", proof(last), info.RootHash, piecesNum, false},
+		{"garbage", []byte("not a boc"), info.RootHash, 0, false},
+		{"empty", nil, info.RootHash, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyPieceProof(tt.proof, tt.merkleHash, piecesNum, tt.piece)
+			if (err == nil) != tt.valid {
+				t.Errorf("verifyPieceProof() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+// A bag of a single piece has no branches, the proof is the root itself
+func TestVerifyPieceProofSinglePiece(t *testing.T) {
+	torrent := newTestTorrent(t, 100, 1<<10)
+	if n := torrent.Info.PiecesNum(); n != 1 {
+		t.Fatalf("bag has %d pieces, want 1", n)
+	}
+
+	proof, err := torrent.GetPieceProof(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = verifyPieceProof(proof, torrent.Info.RootHash, 1, 0); err != nil {
+		t.Errorf("verifyPieceProof() error = %v", err)
+	}
+}
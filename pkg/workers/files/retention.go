@@ -0,0 +1,65 @@
+package filesworker
+
+import (
+	"fmt"
+	"time"
+
+	"mytonstorage-backend/pkg/models/db"
+)
+
+// RetentionPolicy decides when staged data of a bag with finished provider notifications can be removed from the node
+type RetentionPolicy struct {
+	// MinReplicas providers must download the bag fully, capped by the number of providers of the bag
+	MinReplicas int
+	// MinProofs valid storage proofs must be received for the bag
+	MinProofs int
+	// FailedHold keeps a bag that did not meet the requirements for this time after the last provider result
+	FailedHold time.Duration
+}
+
+func (p RetentionPolicy) decide(c db.RetentionCandidate, now time.Time) db.RetentionDecision {
+	d := db.RetentionDecision{
+		BagID:    c.BagID,
+		Decision: db.RetentionHold,
+		Replicas: c.Replicas,
+		Proofs:   c.Proofs,
+	}
+
+	minReplicas := min(p.MinReplicas, c.Providers)
+	replicated := fmt.Sprintf("%d/%d replicas, %d/%d proofs", c.Replicas, minReplicas, c.Proofs, p.MinProofs)
+	holdUntil := time.Unix(c.FinishedAt, 0).Add(p.FailedHold)
+
+	switch {
+	case c.Pinned:
+		d.Reason = "pinned by operator"
+	case c.Replicas >= minReplicas && c.Proofs >= p.MinProofs:
+		d.Decision = db.RetentionRelease
+		d.Reason = "replicated, " + replicated
+	case !now.Before(holdUntil):
+		d.Decision = db.RetentionRelease
+		d.Reason = "replication failed, hold expired, " + replicated
+	default:
+		d.Reason = fmt.Sprintf("replication failed, held until %s, %s", holdUntil.UTC().Format(time.RFC3339), replicated)
+	}
+
+	return d
+}
+
+// releaseTransition returns the final state of a bag whose staged data is removed from the node
+func releaseTransition(c db.RetentionCandidate) db.BagTransition {
+	t := db.BagTransition{BagID: c.BagID}
+
+	switch {
+	case c.Replicas > 0:
+		t.State = db.BagStateReleased
+		t.Reason = "replicated, node copy removed"
+	case c.Notified > 0:
+		t.State = db.BagStateDownloadFailed
+		t.Reason = "providers failed download checks"
+	default:
+		t.State = db.BagStateNotifyFailed
+		t.Reason = "providers failed to start download"
+	}
+
+	return t
+}
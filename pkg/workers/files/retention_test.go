@@ -0,0 +1,34 @@
+package filesworker
+
+import (
+	"testing"
+	"time"
+
+	"mytonstorage-backend/pkg/models/db"
+)
+
+func TestRetentionPolicyDecide(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	policy := RetentionPolicy{MinReplicas: 2, MinProofs: 1, FailedHold: time.Hour}
+
+	tests := []struct {
+		name      string
+		candidate db.RetentionCandidate
+		decision  string
+	}{
+		{"replicated", db.RetentionCandidate{Providers: 3, Replicas: 2, Proofs: 1, FinishedAt: now.Unix()}, db.RetentionRelease},
+		{"single provider is enough when it is the only one", db.RetentionCandidate{Providers: 1, Replicas: 1, Proofs: 1, FinishedAt: now.Unix()}, db.RetentionRelease},
+		{"no proofs yet", db.RetentionCandidate{Providers: 2, Replicas: 2, FinishedAt: now.Unix()}, db.RetentionHold},
+		{"failed within hold", db.RetentionCandidate{Providers: 2, Replicas: 1, Proofs: 1, FinishedAt: now.Add(-time.Hour + time.Second).Unix()}, db.RetentionHold},
+		{"failed after hold", db.RetentionCandidate{Providers: 2, FinishedAt: now.Add(-time.Hour).Unix()}, db.RetentionRelease},
+		{"pinned", db.RetentionCandidate{Providers: 2, Replicas: 2, Proofs: 1, Pinned: true, FinishedAt: now.Add(-2 * time.Hour).Unix()}, db.RetentionHold},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := policy.decide(tt.candidate, now); d.Decision != tt.decision {
+				t.Errorf("decision = %q (%s), want %q", d.Decision, d.Reason, tt.decision)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package filesworker
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy limits attempts of every notification pipeline step and spaces them with exponential backoff
+type RetryPolicy struct {
+	ResolveAttempts int
+	NotifyAttempts  int
+	DownloadChecks  int
+
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// BackoffJitter spreads the delay by ± this fraction, so items failed together are not retried together
+	BackoffJitter float64
+}
+
+// nextAttempt returns unix time of the next attempt after the given number of already made attempts
+func (p RetryPolicy) nextAttempt(attempts int) int64 {
+	delay := float64(p.BackoffBase) * math.Pow(2, float64(max(attempts, 0)))
+	if p.BackoffMax > 0 {
+		delay = math.Min(delay, float64(p.BackoffMax))
+	}
+
+	if p.BackoffJitter > 0 {
+		delay *= 1 + p.BackoffJitter*(2*rand.Float64()-1)
+	}
+
+	return time.Now().Add(time.Duration(delay)).Unix()
+}
@@ -0,0 +1,25 @@
+package filesworker
+
+import (
+	"testing"
+	"time"
+)
+
+// The delay never exceeds the cap, whatever the number of attempts, and jitter stays within its fraction
+func TestRetryPolicyNextAttempt(t *testing.T) {
+	p := RetryPolicy{BackoffBase: time.Minute, BackoffMax: time.Hour, BackoffJitter: 0.1}
+
+	for _, attempts := range []int{0, 3, 1000} {
+		delay := min(time.Minute<<min(attempts, 10), time.Hour)
+		for range 100 {
+			before := time.Now()
+			got := p.nextAttempt(attempts)
+
+			lo := before.Add(delay*9/10).Unix() - 1
+			hi := time.Now().Add(delay * 11 / 10).Unix()
+			if got < lo || got > hi {
+				t.Fatalf("nextAttempt(%d) = %d, want within [%d, %d]", attempts, got, lo, hi)
+			}
+		}
+	}
+}
@@ -5,29 +5,32 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log/slog"
-	"math"
 	"math/rand"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/xssnick/tonutils-go/address"
-	"github.com/xssnick/tonutils-storage-provider/pkg/transport"
 
+	providersclient "mytonstorage-backend/pkg/clients/providers"
 	tonclient "mytonstorage-backend/pkg/clients/ton"
+	tonstorage "mytonstorage-backend/pkg/clients/ton-storage"
+	v1 "mytonstorage-backend/pkg/models/api/v1"
 	"mytonstorage-backend/pkg/models/db"
 )
 
-const (
-	maxNotifyAttempts = 10
-)
-
 type filesDb interface {
 	RemoveUnusedBags(ctx context.Context) (removed []string, err error)
 	RemoveUnpaidBagsRelations(ctx context.Context, sec uint64) (bagids []string, err error)
-	RemoveNotifiedBags(ctx context.Context, limit int, sec uint64, maxNotifyAttempts int, maxDownloadChecks int) (removed []string, err error)
+	GetRetentionCandidates(ctx context.Context, limit int, sec uint64, maxNotifyAttempts int, maxDownloadChecks int) (candidates []db.RetentionCandidate, err error)
+	SaveRetentionDecisions(ctx context.Context, decisions []db.RetentionDecision) error
+	ReleaseBags(ctx context.Context, bagIDs []string) (released []string, err error)
 	GetNotifyInfo(ctx context.Context, limit int, notifyAttempts int) (resp []db.BagStorageContract, err error)
 	IncreaseAttempts(ctx context.Context, bags []db.BagStorageContract) error
+	TransitBags(ctx context.Context, transitions []db.BagTransition) (int64, error)
+	GetBacklog(ctx context.Context, maxNotifyAttempts int, maxDownloadChecks int) (backlog db.Backlog, err error)
 }
 
 type providersDb interface {
@@ -37,9 +40,13 @@ type providersDb interface {
 	IncreaseDownloadChecks(ctx context.Context, notifications []db.ProviderNotification) error
 	IncreaseNotifyAttempts(ctx context.Context, notifications []db.ProviderNotification) error
 	MarkAsNotified(ctx context.Context, notifications []db.ProviderNotification) error
+	AddProviders(ctx context.Context, pubkeys []string) (int64, error)
+	GetProvidersPolicy(ctx context.Context) (policy []db.ProviderPolicy, err error)
+	AddProofAudits(ctx context.Context, audits []db.ProofAudit) error
 }
 
 type storage interface {
+	GetBag(ctx context.Context, bagId string) (*tonstorage.BagDetailed, error)
 	RemoveBag(ctx context.Context, bagId string, withFiles bool) error
 }
 
@@ -51,10 +58,16 @@ type filesWorker struct {
 	filesDb             filesDb
 	providersDb         providersDb
 	tonstorage          storage
-	provider            *transport.Client
+	provider            providersclient.Transport
 	contractsClient     contractsClient
 	unpaidFilesLifetime time.Duration
 	paidFilesLifetime   time.Duration
+	retry               RetryPolicy
+	retention           RetentionPolicy
+	proofChecks         *prometheus.CounterVec
+	backlog             *prometheus.GaugeVec
+	checkWorkers        int
+	limiter             *providerLimiter
 	logger              *slog.Logger
 }
 
@@ -67,6 +80,8 @@ type Worker interface {
 	DownloadChecker(ctx context.Context) (interval time.Duration, err error)
 
 	CollectContractProvidersToNotify(ctx context.Context) (interval time.Duration, err error)
+
+	CollectBacklog(ctx context.Context) (interval time.Duration, err error)
 }
 
 // This worker check table bags and if some bag have no users(in bag_users) it will be removed from db and from disk.
@@ -131,35 +146,80 @@ func (w *filesWorker) MarkToRemoveUnpaidFiles(ctx context.Context) (interval tim
 }
 
 /*
-RemoveNotifiedFiles removes:
+RemoveNotifiedFiles removes staged data of bags whose provider notifications are all finished:
 
 (
 failed to notify after N attempts
 OR failed download check after N attempts
 OR fully downloaded
 )
-AND older than 1 hour
+AND older than paidFilesLifetime
 
-Remove only if all same bagid can be deleted
+Every such bag is passed to the retention policy, which releases it or holds it on disk.
+The decision is kept in files.bag_retention and logged when it changes.
 */
 func (w *filesWorker) RemoveNotifiedFiles(ctx context.Context) (interval time.Duration, err error) {
 	const (
-		failureInterval   = 5 * time.Second
-		successInterval   = 1 * time.Minute
-		batch             = 20
-		maxDownloadChecks = 10
+		failureInterval = 5 * time.Second
+		successInterval = 1 * time.Minute
+		batch           = 20
 	)
 
 	log := w.logger.With("worker", "RemoveNotifiedFiles")
 
 	interval = successInterval
 
-	removed, err := w.filesDb.RemoveNotifiedBags(ctx, batch, uint64(w.paidFilesLifetime.Seconds()), maxNotifyAttempts, maxDownloadChecks)
+	candidates, err := w.filesDb.GetRetentionCandidates(ctx, batch, uint64(w.paidFilesLifetime.Seconds()), w.retry.NotifyAttempts, w.retry.DownloadChecks)
+	if err != nil {
+		interval = failureInterval
+		return
+	}
+
+	if len(candidates) == 0 {
+		return
+	}
+
+	now := time.Now()
+	decisions := make([]db.RetentionDecision, 0, len(candidates))
+	var release []string
+	for _, c := range candidates {
+		d := w.retention.decide(c, now)
+		decisions = append(decisions, d)
+
+		if d.Decision != c.PrevDecision || d.Reason != c.PrevReason {
+			log.Info("retention decision", "bag_id", c.BagID, "decision", d.Decision, "reason", d.Reason)
+		}
+
+		if d.Decision == db.RetentionRelease {
+			release = append(release, c.BagID)
+		}
+	}
+
+	if err = w.filesDb.SaveRetentionDecisions(ctx, decisions); err != nil {
+		interval = failureInterval
+		return
+	}
+
+	removed, err := w.filesDb.ReleaseBags(ctx, release)
 	if err != nil {
 		interval = failureInterval
 		return
 	}
 
+	byID := make(map[string]db.RetentionCandidate, len(candidates))
+	for _, c := range candidates {
+		byID[c.BagID] = c
+	}
+
+	transitions := make([]db.BagTransition, 0, len(removed))
+	for _, bagID := range removed {
+		transitions = append(transitions, releaseTransition(byID[bagID]))
+	}
+
+	if _, tErr := w.filesDb.TransitBags(ctx, transitions); tErr != nil {
+		log.Error("failed to update bags state", "error", tErr.Error())
+	}
+
 	for _, bagID := range removed {
 		log.Info("removing notified bag from disk", "bag_id", bagID)
 		err = w.tonstorage.RemoveBag(ctx, bagID, true)
@@ -181,14 +241,37 @@ func (w *filesWorker) RemoveNotifiedFiles(ctx context.Context) (interval time.Du
 	return
 }
 
+// CollectBacklog exports the number of items waiting in each step of the pipeline
+func (w *filesWorker) CollectBacklog(ctx context.Context) (interval time.Duration, err error) {
+	const (
+		failureInterval = 5 * time.Second
+		successInterval = 30 * time.Second
+	)
+
+	interval = successInterval
+
+	backlog, err := w.filesDb.GetBacklog(ctx, w.retry.NotifyAttempts, w.retry.DownloadChecks)
+	if err != nil {
+		interval = failureInterval
+		return
+	}
+
+	w.backlog.WithLabelValues("unpaid_bags").Set(float64(backlog.UnpaidBags))
+	w.backlog.WithLabelValues("contracts_to_resolve").Set(float64(backlog.ContractsToResolve))
+	w.backlog.WithLabelValues("notifications_pending").Set(float64(backlog.NotificationsPending))
+	w.backlog.WithLabelValues("downloads_in_progress").Set(float64(backlog.DownloadsInProgress))
+	w.backlog.WithLabelValues("removals_pending").Set(float64(backlog.RemovalsPending))
+
+	return
+}
+
 func (w *filesWorker) CollectContractProvidersToNotify(ctx context.Context) (interval time.Duration, err error) {
 	const (
-		failureInterval                = 5 * time.Second
-		successInterval                = 1 * time.Second
-		nothingToUpdateInterval        = 1 * time.Minute
-		batch                          = 10
-		fetchContractProvidersAttempts = 10
-		fetchProvidersTimeout          = batch * 10 * time.Second
+		failureInterval         = 5 * time.Second
+		successInterval         = 1 * time.Second
+		nothingToUpdateInterval = 1 * time.Minute
+		batch                   = 10
+		fetchProvidersTimeout   = batch * 10 * time.Second
 	)
 
 	log := w.logger.With("worker", "CollectContractProvidersToNotify")
@@ -196,7 +279,7 @@ func (w *filesWorker) CollectContractProvidersToNotify(ctx context.Context) (int
 	interval = successInterval
 
 	// Get all bags that need to be downloaded by providers
-	contractsToNotify, err := w.filesDb.GetNotifyInfo(ctx, batch, fetchContractProvidersAttempts)
+	contractsToNotify, err := w.filesDb.GetNotifyInfo(ctx, batch, w.retry.ResolveAttempts)
 	if err != nil {
 		err = fmt.Errorf("failed to get notify info: %w", err)
 		interval = failureInterval
@@ -215,7 +298,7 @@ func (w *filesWorker) CollectContractProvidersToNotify(ctx context.Context) (int
 	// Defer increasing attempts if there's an error
 	defer func() {
 		if err != nil {
-			_ = w.filesDb.IncreaseAttempts(ctx, contractsToNotify)
+			w.failContractsResolve(ctx, contractsToNotify)
 		}
 	}()
 
@@ -229,7 +312,23 @@ func (w *filesWorker) CollectContractProvidersToNotify(ctx context.Context) (int
 		return
 	}
 
+	policy, err := w.providersDb.GetProvidersPolicy(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to get providers policy: %w", err)
+		interval = failureInterval
+		return
+	}
+
+	blocked := make(map[string]struct{})
+	for _, p := range policy {
+		if p.Status == v1.ProviderPolicyBlocked {
+			blocked[p.Pubkey] = struct{}{}
+		}
+	}
+
 	var providersToNotify []db.ProviderNotification
+	var skipped []string
+	resolved := make(map[string]struct{}, len(contractsToNotify))
 	for _, contract := range contractsProviders {
 		sliceIndex := slices.IndexFunc(contractsToNotify, func(item db.BagStorageContract) bool {
 			return item.StorageContract == contract.Address
@@ -240,6 +339,10 @@ func (w *filesWorker) CollectContractProvidersToNotify(ctx context.Context) (int
 
 		for _, provider := range contract.Providers {
 			pk := hex.EncodeToString([]byte(provider.Key))
+			if _, ok := blocked[pk]; ok {
+				skipped = append(skipped, pk)
+				continue
+			}
 
 			providersToNotify = append(providersToNotify, db.ProviderNotification{
 				BagID:           contractsToNotify[sliceIndex].BagID,
@@ -247,11 +350,26 @@ func (w *filesWorker) CollectContractProvidersToNotify(ctx context.Context) (int
 				ProviderPubkey:  pk,
 				Size:            contractsToNotify[sliceIndex].FilesSize,
 			})
+			resolved[contract.Address] = struct{}{}
 		}
 	}
 
+	var unresolved []db.BagStorageContract
+	for _, c := range contractsToNotify {
+		if _, ok := resolved[c.StorageContract]; !ok {
+			unresolved = append(unresolved, c)
+		}
+	}
+
+	if len(skipped) > 0 {
+		log.Info("skipped blocked providers", "providers", skipped)
+	}
+
+	if len(unresolved) > 0 {
+		w.failContractsResolve(ctx, unresolved)
+	}
+
 	if len(providersToNotify) == 0 {
-		_ = w.filesDb.IncreaseAttempts(ctx, contractsToNotify)
 		interval = nothingToUpdateInterval
 		return
 	}
@@ -268,9 +386,68 @@ func (w *filesWorker) CollectContractProvidersToNotify(ctx context.Context) (int
 		log.Info("contract relations added to notify queue", "count", len(providersToNotify))
 	}
 
+	transitions := make([]db.BagTransition, 0, len(resolved))
+	for _, c := range contractsToNotify {
+		if _, ok := resolved[c.StorageContract]; ok {
+			transitions = append(transitions, db.BagTransition{
+				BagID:           c.BagID,
+				StorageContract: c.StorageContract,
+				State:           db.BagStateProvidersResolved,
+				Reason:          "providers added to notify queue",
+			})
+		}
+	}
+
+	if _, tErr := w.filesDb.TransitBags(ctx, transitions); tErr != nil {
+		log.Error("failed to update bags state", "error", tErr.Error())
+	}
+
+	// Every provider seen in a contract becomes known to the registry
+	pubkeys := make([]string, 0, len(providersToNotify))
+	for _, p := range providersToNotify {
+		pubkeys = append(pubkeys, p.ProviderPubkey)
+	}
+
+	if added, aErr := w.providersDb.AddProviders(ctx, pubkeys); aErr != nil {
+		log.Error("failed to add providers to registry", "error", aErr.Error())
+	} else if added > 0 {
+		log.Info("new providers added to registry", "count", added)
+	}
+
 	return
 }
 
+// failContractsResolve counts a failed providers fetch and postpones the next one,
+// contracts out of attempts are moved to resolve_failed
+func (w *filesWorker) failContractsResolve(ctx context.Context, contracts []db.BagStorageContract) {
+	log := w.logger.With("worker", "failContractsResolve")
+
+	for i := range contracts {
+		contracts[i].NextAttemptAt = w.retry.nextAttempt(contracts[i].NotifyAttempts)
+	}
+
+	if err := w.filesDb.IncreaseAttempts(ctx, contracts); err != nil {
+		log.Error("failed to increase attempts", "error", err.Error())
+		return
+	}
+
+	var transitions []db.BagTransition
+	for _, c := range contracts {
+		if c.NotifyAttempts+1 >= w.retry.ResolveAttempts {
+			transitions = append(transitions, db.BagTransition{
+				BagID:           c.BagID,
+				StorageContract: c.StorageContract,
+				State:           db.BagStateResolveFailed,
+				Reason:          fmt.Sprintf("no providers found after %d attempts", w.retry.ResolveAttempts),
+			})
+		}
+	}
+
+	if _, err := w.filesDb.TransitBags(ctx, transitions); err != nil {
+		log.Error("failed to update bags state", "error", err.Error())
+	}
+}
+
 func (w *filesWorker) TriggerProvidersDownload(ctx context.Context) (interval time.Duration, err error) {
 	const (
 		failureInterval         = 5 * time.Second
@@ -283,7 +460,7 @@ func (w *filesWorker) TriggerProvidersDownload(ctx context.Context) (interval ti
 
 	interval = successInterval
 
-	providersToNotify, err := w.providersDb.GetProvidersToNotify(ctx, batch, maxNotifyAttempts)
+	providersToNotify, err := w.providersDb.GetProvidersToNotify(ctx, batch, w.retry.NotifyAttempts)
 	if err != nil {
 		err = fmt.Errorf("failed to get providers to notify: %w", err)
 		interval = failureInterval
@@ -296,6 +473,17 @@ func (w *filesWorker) TriggerProvidersDownload(ctx context.Context) (interval ti
 
 	notified, failed := w.checkProvidersStorageInfo(ctx, providersToNotify)
 
+	// Failed providers are retried with backoff, notified ones get the first download check after the base delay
+	for i := range providersToNotify {
+		providersToNotify[i].NextAttemptAt = w.retry.nextAttempt(providersToNotify[i].NotifyAttempts)
+	}
+	for i := range failed {
+		failed[i].NextAttemptAt = w.retry.nextAttempt(failed[i].NotifyAttempts)
+	}
+	for i := range notified {
+		notified[i].NextAttemptAt = w.retry.nextAttempt(0)
+	}
+
 	// Defer increasing attempts if there's an error
 	defer func() {
 		if err != nil {
@@ -306,6 +494,8 @@ func (w *filesWorker) TriggerProvidersDownload(ctx context.Context) (interval ti
 	if len(failed) > 0 {
 		_ = w.providersDb.IncreaseNotifyAttempts(ctx, failed)
 		log.Warn("Some providers failed notification check", "failed_count", len(failed))
+
+		w.transitContracts(ctx, failed, db.BagStateNotifying, "provider did not start download yet")
 	}
 
 	if len(notified) > 0 {
@@ -317,6 +507,8 @@ func (w *filesWorker) TriggerProvidersDownload(ctx context.Context) (interval ti
 		}
 
 		log.Info("Providers successfully checked and marked as notified", "count", len(notified))
+
+		w.transitContracts(ctx, notified, db.BagStateDownloading, "provider started download")
 	}
 
 	return
@@ -328,14 +520,13 @@ func (w *filesWorker) DownloadChecker(ctx context.Context) (interval time.Durati
 		successInterval         = 1 * time.Second
 		nothingToUpdateInterval = 1 * time.Minute
 		batch                   = 20
-		maxDownloadChecks       = 10
 	)
 
 	log := w.logger.With("worker", "DownloadChecker")
 
 	interval = successInterval
 
-	providersToCheck, err := w.providersDb.GetProvidersInProgress(ctx, batch, maxDownloadChecks)
+	providersToCheck, err := w.providersDb.GetProvidersInProgress(ctx, batch, w.retry.DownloadChecks)
 	if err != nil {
 		err = fmt.Errorf("failed to get providers to notify: %w", err)
 		interval = failureInterval
@@ -348,6 +539,13 @@ func (w *filesWorker) DownloadChecker(ctx context.Context) (interval time.Durati
 
 	checked, failed := w.checkProvidersStorageInfo(ctx, providersToCheck)
 
+	for i := range failed {
+		failed[i].NextAttemptAt = w.retry.nextAttempt(failed[i].DownloadChecks)
+	}
+	for i := range checked {
+		checked[i].NextAttemptAt = w.retry.nextAttempt(checked[i].DownloadChecks)
+	}
+
 	if len(failed) > 0 {
 		_ = w.providersDb.IncreaseDownloadChecks(ctx, failed)
 		log.Info("Some providers failed download check", "failed_count", len(failed))
@@ -362,18 +560,59 @@ func (w *filesWorker) DownloadChecker(ctx context.Context) (interval time.Durati
 		}
 
 		log.Info("Providers successfully checked for download", "count", len(checked))
+
+		var downloaded []db.ProviderNotification
+		for _, c := range checked {
+			if c.Downloaded >= c.Size {
+				downloaded = append(downloaded, c)
+			}
+		}
+
+		// One full copy on a provider is enough to consider the bag replicated
+		w.transitContracts(ctx, downloaded, db.BagStateReplicated, "provider downloaded the bag")
 	}
 
 	return
 }
 
+func (w *filesWorker) transitContracts(ctx context.Context, notifications []db.ProviderNotification, state, reason string) {
+	transitions := make([]db.BagTransition, 0, len(notifications))
+	for _, n := range notifications {
+		transitions = append(transitions, db.BagTransition{
+			BagID:           n.BagID,
+			StorageContract: n.StorageContract,
+			State:           state,
+			Reason:          reason,
+		})
+	}
+
+	if _, err := w.filesDb.TransitBags(ctx, transitions); err != nil {
+		w.logger.Error("failed to update bags state", "state", state, "error", err.Error())
+	}
+}
+
+// checkProvidersStorageInfo requests storage info from providers concurrently, within per provider limits
 func (w *filesWorker) checkProvidersStorageInfo(ctx context.Context, providers []db.ProviderNotification) (checked []db.ProviderNotification, failed []db.ProviderNotification) {
 	log := w.logger.With("worker", "checkProvidersStorageInfo")
 
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	type check struct {
+		provider db.ProviderNotification
+		contract *address.Address
+
+		started bool
+		audit   *db.ProofAudit
+		err     error
+	}
+
+	// Proofs are verified against the bag kept on our node, every bag is loaded once by the first check that needs it
+	type bagInfo struct {
+		once sync.Once
+		bag  *tonstorage.BagDetailed
+	}
 
+	bags := make(map[string]*bagInfo)
+	checks := make([]*check, 0, len(providers))
 	for _, provider := range providers {
-		toProof := r.Uint64() % uint64(math.Max(float64(provider.Size), 1))
 		sc, cErr := address.ParseAddr(provider.StorageContract)
 		if cErr != nil {
 			log.Error("failed to parse storage contract address",
@@ -382,49 +621,184 @@ func (w *filesWorker) checkProvidersStorageInfo(ctx context.Context, providers [
 			continue
 		}
 
-		fErr := func() error {
-			timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-			defer cancel()
+		if _, ok := bags[provider.BagID]; !ok {
+			bags[provider.BagID] = &bagInfo{}
+		}
 
-			providerKey, dErr := hex.DecodeString(provider.ProviderPubkey)
-			if dErr != nil {
-				log.Error("failed to decode provider pubkey",
-					"error", dErr.Error(),
-					"provider_pubkey", provider.ProviderPubkey)
-				return dErr
-			}
+		checks = append(checks, &check{provider: provider, contract: sc})
+	}
 
-			info, rErr := w.provider.RequestStorageInfo(timeoutCtx, providerKey, sc, toProof)
-			if rErr != nil {
-				log.Error("failed to notify provider",
-					"error", rErr.Error(),
-					"provider_pubkey", provider.ProviderPubkey)
-				return rErr
-			}
+	sem := make(chan struct{}, w.checkWorkers)
+	wg := sync.WaitGroup{}
+loop:
+	for _, c := range checks {
+		// Checks not started before the iteration is canceled are left for the next one
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
 
-			if info.Status == "error" {
-				log.Error("provider returned error status",
-					"error", info.Reason,
-					"provider_pubkey", provider.ProviderPubkey)
-				return fmt.Errorf("%s", info.Reason)
-			}
+		c.started = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
 
-			if len(info.Proof) > 0 {
-				provider.Downloaded = info.Downloaded
-				checked = append(checked, provider)
-				return nil
-			}
+			b := bags[c.provider.BagID]
+			b.once.Do(func() {
+				b.bag = w.loadBag(ctx, c.provider.BagID)
+			})
 
-			log.Error("provider returned empty proof",
-				"provider_pubkey", provider.ProviderPubkey,
-				"info", info)
-			return fmt.Errorf("empty proof")
+			c.provider, c.audit, c.err = w.checkProviderStorageInfo(ctx, c.provider, c.contract, b.bag)
 		}()
-		if fErr != nil {
-			failed = append(failed, provider)
+	}
+	wg.Wait()
+
+	var audits []db.ProofAudit
+	for _, c := range checks {
+		if !c.started {
+			continue
+		}
+
+		if c.audit != nil {
+			audits = append(audits, *c.audit)
+		}
+
+		if c.err != nil {
+			failed = append(failed, c.provider)
+			continue
+		}
+
+		checked = append(checked, c.provider)
+	}
+
+	if len(audits) > 0 {
+		if aErr := w.providersDb.AddProofAudits(ctx, audits); aErr != nil {
+			log.Error("failed to save proof audits", "error", aErr.Error())
+		}
+	}
+
+	return
+}
+
+// loadBag returns the bag kept on our node, nil if its info is not loaded and proofs can't be verified
+func (w *filesWorker) loadBag(ctx context.Context, bagID string) *tonstorage.BagDetailed {
+	bag, err := w.tonstorage.GetBag(ctx, bagID)
+	if err != nil {
+		w.logger.Warn("failed to get bag to verify proofs", "bag_id", bagID, "error", err.Error())
+		return nil
+	}
+
+	if bag == nil || !bag.InfoLoaded || bag.PieceSize == 0 || bag.BagPiecesNum == 0 || bag.BagSize == 0 {
+		return nil
+	}
+
+	return bag
+}
+
+// checkProviderStorageInfo requests storage info with a proof of a random byte of the bag.
+// Without the bag on our node the proof can't be verified, it is recorded as unverified and the check fails.
+func (w *filesWorker) checkProviderStorageInfo(ctx context.Context, provider db.ProviderNotification, sc *address.Address, bag *tonstorage.BagDetailed) (res db.ProviderNotification, audit *db.ProofAudit, err error) {
+	log := w.logger.With("worker", "checkProvidersStorageInfo")
+
+	res = provider
+
+	toProof := rand.Uint64() % max(provider.Size, 1)
+	if bag != nil {
+		toProof = rand.Uint64() % bag.BagSize
+	}
+
+	providerKey, err := hex.DecodeString(provider.ProviderPubkey)
+	if err != nil {
+		log.Error("failed to decode provider pubkey",
+			"error", err.Error(),
+			"provider_pubkey", provider.ProviderPubkey)
+		return
+	}
+
+	release, err := w.limiter.acquire(ctx, strings.ToLower(provider.ProviderPubkey))
+	if err != nil {
+		return
+	}
+	defer release()
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	info, err := w.provider.RequestStorageInfo(timeoutCtx, providerKey, sc, toProof)
+	if err != nil {
+		log.Error("failed to notify provider",
+			"error", err.Error(),
+			"provider_pubkey", provider.ProviderPubkey)
+		return
+	}
+
+	if info.Status == "error" {
+		log.Error("provider returned error status",
+			"error", info.Reason,
+			"provider_pubkey", provider.ProviderPubkey)
+		err = fmt.Errorf("%s", info.Reason)
+		return
+	}
+
+	if len(info.Proof) == 0 {
+		log.Error("provider returned empty proof",
+			"provider_pubkey", provider.ProviderPubkey,
+			"info", info)
+		err = fmt.Errorf("empty proof")
+		return
+	}
+
+	var a db.ProofAudit
+	a, err = w.auditProof(provider, bag, toProof, info.Proof)
+	audit = &a
+	if err != nil {
+		log.Error("provider proof is not accepted",
+			"error", err.Error(),
+			"provider_pubkey", provider.ProviderPubkey,
+			"bag_id", provider.BagID)
+		return
+	}
+
+	res.Downloaded = info.Downloaded
+
+	return
+}
+
+// auditProof verifies the proof against the bag on our node, a nil bag gives an unverified result
+func (w *filesWorker) auditProof(provider db.ProviderNotification, bag *tonstorage.BagDetailed, toProof uint64, proof []byte) (audit db.ProofAudit, err error) {
+	audit = db.ProofAudit{
+		ProviderPubkey:  provider.ProviderPubkey,
+		StorageContract: provider.StorageContract,
+		BagID:           provider.BagID,
+		ByteToProof:     toProof,
+	}
+
+	result := "valid"
+	switch {
+	case bag == nil:
+		result = "unverified"
+		err = fmt.Errorf("unverified: bag info is not loaded on the node")
+	default:
+		audit.Piece = uint32(toProof / uint64(bag.PieceSize))
+
+		var merkleHash []byte
+		if merkleHash, err = hex.DecodeString(bag.MerkleHash); err == nil {
+			err = verifyPieceProof(proof, merkleHash, bag.BagPiecesNum, audit.Piece)
+		}
+		if err != nil {
+			result = "invalid"
 		}
 	}
 
+	audit.Valid = err == nil
+	if err != nil {
+		audit.Error = err.Error()
+	}
+
+	w.proofChecks.WithLabelValues(strings.ToLower(provider.ProviderPubkey), result).Inc()
+
 	return
 }
 
@@ -432,10 +806,17 @@ func NewWorker(
 	filesDb filesDb,
 	providersDb providersDb,
 	tonstorage storage,
-	provider *transport.Client,
+	provider providersclient.Transport,
 	contractsClient contractsClient,
 	unpaidFilesLifetime time.Duration,
 	paidFilesLifetime time.Duration,
+	retry RetryPolicy,
+	retention RetentionPolicy,
+	proofChecks *prometheus.CounterVec,
+	backlog *prometheus.GaugeVec,
+	checkWorkers int,
+	providerConcurrency int,
+	providerInterval time.Duration,
 	logger *slog.Logger,
 ) Worker {
 	return &filesWorker{
@@ -446,6 +827,12 @@ func NewWorker(
 		contractsClient:     contractsClient,
 		unpaidFilesLifetime: unpaidFilesLifetime,
 		paidFilesLifetime:   paidFilesLifetime,
+		retry:               retry,
+		retention:           retention,
+		proofChecks:         proofChecks,
+		backlog:             backlog,
+		checkWorkers:        max(checkWorkers, 1),
+		limiter:             newProviderLimiter(providerConcurrency, providerInterval),
 		logger:              logger,
 	}
 }
@@ -0,0 +1,56 @@
+package providersworker
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type metricsMiddleware struct {
+	reqCount    *prometheus.CounterVec
+	reqDuration *prometheus.HistogramVec
+	worker      Worker
+}
+
+func (m *metricsMiddleware) UpdateReputation(ctx context.Context) (interval time.Duration, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"UpdateReputation", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.worker.UpdateReputation(ctx)
+}
+
+func (m *metricsMiddleware) ProbeProviders(ctx context.Context) (interval time.Duration, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"ProbeProviders", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.worker.ProbeProviders(ctx)
+}
+
+func (m *metricsMiddleware) MonitorProofs(ctx context.Context) (interval time.Duration, err error) {
+	defer func(s time.Time) {
+		labels := []string{
+			"MonitorProofs", strconv.FormatBool(err != nil),
+		}
+		m.reqCount.WithLabelValues(labels...).Add(1)
+		m.reqDuration.WithLabelValues(labels...).Observe(time.Since(s).Seconds())
+	}(time.Now())
+	return m.worker.MonitorProofs(ctx)
+}
+
+func NewMetrics(reqCount *prometheus.CounterVec, reqDuration *prometheus.HistogramVec, worker Worker) Worker {
+	return &metricsMiddleware{
+		reqCount:    reqCount,
+		reqDuration: reqDuration,
+		worker:      worker,
+	}
+}
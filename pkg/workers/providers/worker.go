@@ -0,0 +1,545 @@
+package providersworker
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	providersclient "mytonstorage-backend/pkg/clients/providers"
+	tonclient "mytonstorage-backend/pkg/clients/ton"
+	v1 "mytonstorage-backend/pkg/models/api/v1"
+	"mytonstorage-backend/pkg/models/db"
+)
+
+const (
+	reputationWindowDays = 30
+	proofContractsBatch  = 50
+
+	probeWorkers        = 16
+	probeRequestTimeout = 10 * time.Second
+
+	proofContractsPageSize = 100
+	replacementsBatch      = 20
+
+	// Download time and proof lag at which the corresponding score component drops to zero
+	worstDownloadTime = 24 * time.Hour
+	worstProofLag     = 24 * time.Hour
+)
+
+// Weights of reputation components, components without data are excluded and the rest are rescaled
+const (
+	notifyWeight    = 0.3
+	abandonedWeight = 0.3
+	downloadWeight  = 0.2
+	proofWeight     = 0.2
+)
+
+type providersDb interface {
+	GetProvidersStats(ctx context.Context, days int, maxNotifyAttempts int, maxDownloadChecks int) (stats []db.ProviderStats, err error)
+	GetDownloadedContracts(ctx context.Context, days int, limit int) (contracts []string, err error)
+	UpdateProvidersReputation(ctx context.Context, reputation []db.ProviderReputation) error
+
+	GetRegistryPubkeys(ctx context.Context) (pubkeys []string, err error)
+	UpdateProvidersRates(ctx context.Context, providers []db.RegistryProvider) error
+	MarkProvidersUnreachable(ctx context.Context, pubkeys []string) error
+	AddRatesHistory(ctx context.Context, points []db.ProviderRatesPoint) error
+
+	AddProofIncidents(ctx context.Context, incidents []db.ProofIncident) error
+	ResolveProofIncidents(ctx context.Context, contracts []string, open []db.ProofIncident) (int64, error)
+	GetIncidentsToReplace(ctx context.Context, limit int) (incidents []db.ProofIncident, err error)
+	SetIncidentReplacement(ctx context.Context, id int64, pubkey string, tx []byte) error
+}
+
+type filesDb interface {
+	GetPaidContracts(ctx context.Context, days int) ([]db.PaidContract, error)
+}
+
+type replacer interface {
+	PrepareProviderReplacement(ctx context.Context, contractAddr string, bagSize uint64, span uint32, keep []v1.ProviderShort, replaced string) (replacement v1.ProviderOffer, tx v1.Transaction, err error)
+}
+
+type contractsClient interface {
+	GetProvidersInfo(ctx context.Context, addrs []string) (contractsProviders []tonclient.StorageContractProviders, err error)
+}
+
+type providersWorker struct {
+	providersDb      providersDb
+	filesDb          filesDb
+	contractsClient  contractsClient
+	replacer         replacer
+	provider         providersclient.Transport
+	probeInterval    time.Duration
+	probeBagSize     uint64
+	proofGracePeriod time.Duration
+	historyDays      int
+	maxNotify        int
+	maxDownload      int
+	logger           *slog.Logger
+}
+
+type Worker interface {
+	UpdateReputation(ctx context.Context) (interval time.Duration, err error)
+	ProbeProviders(ctx context.Context) (interval time.Duration, err error)
+	MonitorProofs(ctx context.Context) (interval time.Duration, err error)
+}
+
+// UpdateReputation recomputes providers reputation from notifications history and on-chain proofs freshness
+func (w *providersWorker) UpdateReputation(ctx context.Context) (interval time.Duration, err error) {
+	const (
+		failureInterval = 1 * time.Minute
+		successInterval = 15 * time.Minute
+	)
+
+	log := w.logger.With("worker", "UpdateReputation")
+
+	interval = successInterval
+
+	stats, err := w.providersDb.GetProvidersStats(ctx, reputationWindowDays, w.maxNotify, w.maxDownload)
+	if err != nil {
+		err = fmt.Errorf("failed to get providers stats: %w", err)
+		interval = failureInterval
+		return
+	}
+
+	if len(stats) == 0 {
+		return
+	}
+
+	proofLags := w.collectProofLags(ctx, log)
+
+	reputation := make([]db.ProviderReputation, 0, len(stats))
+	for _, s := range stats {
+		if lag, ok := proofLags[s.Pubkey]; ok {
+			s.ProofLagSeconds = &lag
+		}
+
+		reputation = append(reputation, reputationFromStats(s))
+	}
+
+	err = w.providersDb.UpdateProvidersReputation(ctx, reputation)
+	if err != nil {
+		err = fmt.Errorf("failed to update providers reputation: %w", err)
+		interval = failureInterval
+		return
+	}
+
+	log.Info("providers reputation updated", "count", len(reputation))
+
+	return
+}
+
+// collectProofLags returns average proof overdue per provider: time since the last proof beyond the max span
+func (w *providersWorker) collectProofLags(ctx context.Context, log *slog.Logger) map[string]int64 {
+	contracts, err := w.providersDb.GetDownloadedContracts(ctx, reputationWindowDays, proofContractsBatch)
+	if err != nil {
+		log.Error("failed to get contracts to check proofs", "error", err.Error())
+		return nil
+	}
+
+	if len(contracts) == 0 {
+		return nil
+	}
+
+	contractsProviders, err := w.contractsClient.GetProvidersInfo(ctx, contracts)
+	if err != nil {
+		log.Error("failed to get providers info", "error", err.Error())
+		return nil
+	}
+
+	type lagSum struct {
+		total int64
+		count int64
+	}
+
+	now := time.Now()
+	sums := make(map[string]lagSum)
+	for _, contract := range contractsProviders {
+		for _, p := range contract.Providers {
+			if p.LastProofTime.IsZero() {
+				continue
+			}
+
+			lag := now.Sub(p.LastProofTime) - time.Duration(p.MaxSpan)*time.Second
+			if lag < 0 {
+				lag = 0
+			}
+
+			pk := strings.ToLower(hex.EncodeToString([]byte(p.Key)))
+			s := sums[pk]
+			s.total += int64(lag.Seconds())
+			s.count++
+			sums[pk] = s
+		}
+	}
+
+	lags := make(map[string]int64, len(sums))
+	for pk, s := range sums {
+		lags[pk] = s.total / s.count
+	}
+
+	return lags
+}
+
+// ProbeProviders requests rates of every registered provider for the reference bag size
+// and stores them as a time series, the registry is refreshed with the same data.
+func (w *providersWorker) ProbeProviders(ctx context.Context) (interval time.Duration, err error) {
+	const (
+		failureInterval = 1 * time.Minute
+	)
+
+	log := w.logger.With("worker", "ProbeProviders")
+
+	interval = w.probeInterval
+
+	pubkeys, err := w.providersDb.GetRegistryPubkeys(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to get registry providers: %w", err)
+		interval = failureInterval
+		return
+	}
+
+	if len(pubkeys) == 0 {
+		return
+	}
+
+	points := make([]db.ProviderRatesPoint, len(pubkeys))
+	registry := make([]*db.RegistryProvider, len(pubkeys))
+	sem := make(chan struct{}, probeWorkers)
+	wg := sync.WaitGroup{}
+	for i, pubkey := range pubkeys {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			points[i], registry[i] = w.probeProvider(ctx, pubkey, log)
+		}()
+	}
+	wg.Wait()
+
+	var rates []db.RegistryProvider
+	var unreachable []string
+	for i, p := range points {
+		if registry[i] == nil {
+			unreachable = append(unreachable, p.Pubkey)
+			continue
+		}
+
+		rates = append(rates, *registry[i])
+	}
+
+	err = w.providersDb.AddRatesHistory(ctx, points)
+	if err != nil {
+		err = fmt.Errorf("failed to save rates history: %w", err)
+		interval = failureInterval
+		return
+	}
+
+	if len(rates) > 0 {
+		if uErr := w.providersDb.UpdateProvidersRates(ctx, rates); uErr != nil {
+			log.Error("failed to update registry rates", "error", uErr.Error())
+		}
+	}
+
+	if len(unreachable) > 0 {
+		if uErr := w.providersDb.MarkProvidersUnreachable(ctx, unreachable); uErr != nil {
+			log.Error("failed to mark providers unreachable", "error", uErr.Error())
+		}
+	}
+
+	log.Info("providers probed", "count", len(points), "unreachable", len(unreachable))
+
+	return
+}
+
+func (w *providersWorker) probeProvider(ctx context.Context, pubkey string, log *slog.Logger) (point db.ProviderRatesPoint, registry *db.RegistryProvider) {
+	point = db.ProviderRatesPoint{
+		Pubkey:    pubkey,
+		CheckedAt: time.Now().Unix(),
+	}
+
+	pk, err := hex.DecodeString(pubkey)
+	if err != nil {
+		log.Error("invalid provider pubkey", "provider_pubkey", pubkey, "error", err.Error())
+		return
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, probeRequestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	rates, err := w.provider.GetStorageRates(timeoutCtx, pk, w.probeBagSize)
+	point.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		log.Debug("failed to get provider rates", "provider_pubkey", pubkey, "error", err.Error())
+		return
+	}
+
+	point.Reachable = true
+	point.Available = rates.Available
+	point.RatePerMBDay = new(big.Int).SetBytes(rates.RatePerMBDay).Uint64()
+	point.MinBounty = new(big.Int).SetBytes(rates.MinBounty).Uint64()
+	point.SpaceAvailableMB = rates.SpaceAvailableMB
+
+	status := v1.ProviderStatusAvailable
+	if !rates.Available {
+		status = v1.ProviderStatusNotAvailable
+	}
+
+	registry = &db.RegistryProvider{
+		Pubkey:           pubkey,
+		RatePerMBDay:     point.RatePerMBDay,
+		MinBounty:        point.MinBounty,
+		MinSpan:          rates.MinSpan,
+		MaxSpan:          rates.MaxSpan,
+		SpaceAvailableMB: rates.SpaceAvailableMB,
+		Status:           status,
+	}
+
+	return
+}
+
+// MonitorProofs reads providers of every paid contract and records an incident for each provider
+// whose last proof is older than its max span plus the grace period. For every new incident
+// a replacement transaction is prepared, so the contract owner only has to sign it.
+func (w *providersWorker) MonitorProofs(ctx context.Context) (interval time.Duration, err error) {
+	const (
+		failureInterval = 1 * time.Minute
+		successInterval = 30 * time.Minute
+	)
+
+	log := w.logger.With("worker", "MonitorProofs")
+
+	interval = successInterval
+
+	paid, err := w.filesDb.GetPaidContracts(ctx, w.historyDays)
+	if err != nil {
+		err = fmt.Errorf("failed to get paid contracts: %w", err)
+		interval = failureInterval
+		return
+	}
+
+	if len(paid) == 0 {
+		return
+	}
+
+	contracts := make(map[string]tonclient.StorageContractProviders, len(paid))
+	var flagged []db.ProofIncident
+	for page := range slices.Chunk(paid, proofContractsPageSize) {
+		addrs := make([]string, 0, len(page))
+		for _, c := range page {
+			addrs = append(addrs, c.StorageContract)
+		}
+
+		info, iErr := w.contractsClient.GetProvidersInfo(ctx, addrs)
+		if iErr != nil {
+			log.Error("failed to get providers info", "error", iErr.Error())
+			continue
+		}
+
+		for _, contract := range info {
+			i := slices.IndexFunc(page, func(c db.PaidContract) bool {
+				return c.StorageContract == contract.Address
+			})
+			if i == -1 {
+				continue
+			}
+
+			contracts[contract.Address] = contract
+			flagged = append(flagged, w.overdueProviders(page[i], contract)...)
+		}
+	}
+
+	checked := make([]string, 0, len(contracts))
+	for addr := range contracts {
+		checked = append(checked, addr)
+	}
+
+	if len(flagged) > 0 {
+		err = w.providersDb.AddProofIncidents(ctx, flagged)
+		if err != nil {
+			err = fmt.Errorf("failed to add proof incidents: %w", err)
+			interval = failureInterval
+			return
+		}
+
+		log.Warn("providers with overdue proofs found", "count", len(flagged))
+	}
+
+	if len(checked) > 0 {
+		resolved, rErr := w.providersDb.ResolveProofIncidents(ctx, checked, flagged)
+		if rErr != nil {
+			log.Error("failed to resolve proof incidents", "error", rErr.Error())
+		} else if resolved > 0 {
+			log.Info("proof incidents resolved", "count", resolved)
+		}
+	}
+
+	w.prepareReplacements(ctx, contracts, log)
+
+	return
+}
+
+func (w *providersWorker) overdueProviders(paid db.PaidContract, contract tonclient.StorageContractProviders) (incidents []db.ProofIncident) {
+	for _, p := range contract.Providers {
+		if !w.isOverdue(p) {
+			continue
+		}
+
+		incidents = append(incidents, db.ProofIncident{
+			StorageContract: contract.Address,
+			ProviderPubkey:  hex.EncodeToString([]byte(p.Key)),
+			BagID:           paid.BagID,
+			UserAddress:     paid.UserAddress,
+			BagSize:         paid.Size,
+			LastProofAt:     p.LastProofTime.Unix(),
+			MaxSpan:         p.MaxSpan,
+		})
+	}
+
+	return
+}
+
+func (w *providersWorker) prepareReplacements(ctx context.Context, contracts map[string]tonclient.StorageContractProviders, log *slog.Logger) {
+	incidents, err := w.providersDb.GetIncidentsToReplace(ctx, replacementsBatch)
+	if err != nil {
+		log.Error("failed to get incidents to replace", "error", err.Error())
+		return
+	}
+
+	for _, incident := range incidents {
+		contract, ok := contracts[incident.StorageContract]
+		if !ok {
+			continue
+		}
+
+		// Keep providers that are still proving, overdue ones are dropped together
+		keep := make([]v1.ProviderShort, 0, len(contract.Providers))
+		for _, p := range contract.Providers {
+			if w.isOverdue(p) {
+				continue
+			}
+
+			keep = append(keep, v1.ProviderShort{
+				Pubkey:        hex.EncodeToString([]byte(p.Key)),
+				PricePerMBDay: p.RatePerMBDay,
+				MaxSpan:       uint64(p.MaxSpan),
+			})
+		}
+
+		replacement, tx, rErr := w.replacer.PrepareProviderReplacement(ctx, incident.StorageContract, incident.BagSize, incident.MaxSpan, keep, incident.ProviderPubkey)
+		if rErr != nil {
+			log.Info("no replacement prepared", "storage_contract", incident.StorageContract, "provider_pubkey", incident.ProviderPubkey, "error", rErr.Error())
+			continue
+		}
+
+		data, mErr := json.Marshal(tx)
+		if mErr != nil {
+			log.Error("failed to encode replacement transaction", "error", mErr.Error())
+			continue
+		}
+
+		if sErr := w.providersDb.SetIncidentReplacement(ctx, incident.ID, replacement.Provider.Key, data); sErr != nil {
+			log.Error("failed to save replacement", "id", incident.ID, "error", sErr.Error())
+			continue
+		}
+
+		log.Info("replacement prepared",
+			"storage_contract", incident.StorageContract,
+			"user_address", incident.UserAddress,
+			"provider_pubkey", incident.ProviderPubkey,
+			"replacement_pubkey", replacement.Provider.Key)
+	}
+}
+
+func (w *providersWorker) isOverdue(p tonclient.Provider) bool {
+	// Provider has not proved anything yet, it is still downloading
+	if p.LastProofTime.IsZero() {
+		return false
+	}
+
+	return time.Since(p.LastProofTime) > time.Duration(p.MaxSpan)*time.Second+w.proofGracePeriod
+}
+
+func reputationFromStats(s db.ProviderStats) db.ProviderReputation {
+	r := db.ProviderReputation{
+		Pubkey:  s.Pubkey,
+		Samples: s.Total,
+	}
+
+	var score, weights float64
+
+	if resolved := s.Notified + s.NotifyFailed; resolved > 0 {
+		rate := float64(s.Notified) / float64(resolved)
+		r.NotifySuccessRate = &rate
+		score += notifyWeight * rate
+		weights += notifyWeight
+	}
+
+	if finished := s.Downloaded + s.Abandoned; finished > 0 {
+		rate := float64(s.Abandoned) / float64(finished)
+		r.AbandonedRate = &rate
+		score += abandonedWeight * (1 - rate)
+		weights += abandonedWeight
+	}
+
+	if s.Downloaded > 0 && s.AvgDownloadSeconds > 0 {
+		avg := s.AvgDownloadSeconds
+		r.AvgDownloadSeconds = &avg
+		score += downloadWeight * (1 - math.Min(float64(avg)/worstDownloadTime.Seconds(), 1))
+		weights += downloadWeight
+	}
+
+	if s.ProofLagSeconds != nil {
+		lag := *s.ProofLagSeconds
+		r.ProofLagSeconds = &lag
+		score += proofWeight * (1 - math.Min(float64(lag)/worstProofLag.Seconds(), 1))
+		weights += proofWeight
+	}
+
+	if weights > 0 {
+		r.Score = math.Round(score/weights*1000) / 1000
+	}
+
+	return r
+}
+
+func NewWorker(
+	providersDb providersDb,
+	filesDb filesDb,
+	contractsClient contractsClient,
+	replacer replacer,
+	provider providersclient.Transport,
+	probeInterval time.Duration,
+	probeBagSize uint64,
+	proofGracePeriod time.Duration,
+	historyDays int,
+	maxNotifyAttempts int,
+	maxDownloadChecks int,
+	logger *slog.Logger,
+) Worker {
+	return &providersWorker{
+		providersDb:      providersDb,
+		filesDb:          filesDb,
+		contractsClient:  contractsClient,
+		replacer:         replacer,
+		provider:         provider,
+		probeInterval:    probeInterval,
+		probeBagSize:     probeBagSize,
+		proofGracePeriod: proofGracePeriod,
+		historyDays:      historyDays,
+		maxNotify:        maxNotifyAttempts,
+		maxDownload:      maxDownloadChecks,
+		logger:           logger,
+	}
+}
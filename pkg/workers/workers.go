@@ -3,42 +3,77 @@ package workers
 import (
 	"context"
 	"log/slog"
+	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+
+	v1 "mytonstorage-backend/pkg/models/api/v1"
 	"mytonstorage-backend/pkg/workers/cleaner"
 	filesworker "mytonstorage-backend/pkg/workers/files"
+	providersworker "mytonstorage-backend/pkg/workers/providers"
 )
 
+// Instances that are not leaders for a worker check again after this interval
+const standbyInterval = 15 * time.Second
+
 type workerFunc = func(ctx context.Context) (interval time.Duration, err error)
 
+type leader interface {
+	Lead(ctx context.Context, name string) (lease context.Context, leading bool, err error)
+}
+
 type worker struct {
-	files   filesworker.Worker
-	cleaner cleaner.Worker
-	logger  *slog.Logger
+	files       filesworker.Worker
+	providers   providersworker.Worker
+	cleaner     cleaner.Worker
+	leader      leader
+	params      params
+	lastSuccess *prometheus.GaugeVec
+	logger      *slog.Logger
+
+	handlesMu sync.RWMutex
+	handles   []*handle
+
+	// stop ends worker loops after the current iteration, running tracks loops that have not returned yet
+	stop     chan struct{}
+	stopOnce sync.Once
+	running  sync.WaitGroup
+
+	pausedMu       sync.Mutex
+	paused         map[string]struct{}
+	pausedLoadedAt time.Time
 }
 
 type Workers interface {
 	Start(ctx context.Context) (err error)
+	Stop(ctx context.Context) (err error)
+	GetWorkers(ctx context.Context) (workers []v1.WorkerStatus, err error)
+	PauseWorker(ctx context.Context, name string) (err error)
+	ResumeWorker(ctx context.Context, name string) (err error)
+	TriggerWorker(ctx context.Context, name string) (err error)
 }
 
 func (w *worker) Start(ctx context.Context) (err error) {
-	go w.run(ctx, "CleanupOldData", w.cleaner.CleanupOldData)
+	go w.run(ctx, w.register("CleanupOldData", w.cleaner.CleanupOldData))
 
-	go w.run(ctx, "MarkToRemoveUnpaidFiles", w.files.MarkToRemoveUnpaidFiles)
-	go w.run(ctx, "RemoveUnpaidFiles", w.files.RemoveUnpaidFiles)
+	go w.run(ctx, w.register("MarkToRemoveUnpaidFiles", w.files.MarkToRemoveUnpaidFiles))
+	go w.run(ctx, w.register("RemoveUnpaidFiles", w.files.RemoveUnpaidFiles))
 
 	/*
 		Note: Первым отрабатывает CollectContractProvidersToNotify. Он дергает гет методы новых контрактов что бы получить список провайдеров
-		Если удалось получить список провайдеров, то выставляет files.bag_users.notify_attempts = -1 и добавляет запись в providers.notifications
-		Если возникла ошибка инкрементит счетчик попыток и возвращается в других итерациях
+		Если удалось получить список провайдеров, то добавляет запись в providers.notifications и переводит bag_users.state в providers_resolved
+		Если возникла ошибка инкрементит счетчик попыток и возвращается в других итерациях, после N попыток state = resolve_failed
 
 		Далее TriggerProvidersDownload собирает записи из providers.notifications
 		В случае ошибки та же логика с попытками инкремента ошибок
-		Если провайдер ответил что начал скачивание, то выставляет providers.notifications.notified = true
+		Повторная попытка для каждой записи откладывается через next_attempt_at с экспоненциальной задержкой, воркеры берут только записи у которых она наступила
+		Если провайдер ответил что начал скачивание, то выставляет providers.notifications.notified = true и state = downloading
 
 		Третьим идет DownloadChecker, который проверяет статус скачивания у провайдеров
 		Используется тот же метод для проверки статуса что и в TriggerProvidersDownload
 		В случае успеха обновляет количество скачанных байт в providers.notifications.downloaded = c.downloaded
+		Когда хотя бы один провайдер скачал bag целиком, state = replicated
 
 		RemoveNotifiedFiles удаляет файлы
 		Старше paidFilesLifetime часов
@@ -48,49 +83,149 @@ func (w *worker) Start(ctx context.Context) (err error) {
 		Либо файлы провалившие DownloadChecker более N раз
 		Либо файлы которые полностью скачаны (downloaded = size)
 		)
+		Но перед удалением каждый такой bag проверяет retention policy: нужно минимум N провайдеров скачавших bag целиком
+		и N валидных пруфов, иначе bag держится еще FailedHold. Запиненные оператором bags не удаляются.
+		Решение по каждому bag сохраняется в files.bag_retention
 	*/
-	go w.run(ctx, "CollectContractProvidersToNotify", w.files.CollectContractProvidersToNotify)
-	go w.run(ctx, "TriggerProvidersDownload", w.files.TriggerProvidersDownload)
-	go w.run(ctx, "DownloadChecker", w.files.DownloadChecker)
-	go w.run(ctx, "RemoveNotifiedFiles", w.files.RemoveNotifiedFiles)
+	go w.run(ctx, w.register("CollectContractProvidersToNotify", w.files.CollectContractProvidersToNotify))
+	go w.run(ctx, w.register("TriggerProvidersDownload", w.files.TriggerProvidersDownload))
+	go w.run(ctx, w.register("DownloadChecker", w.files.DownloadChecker))
+	go w.run(ctx, w.register("RemoveNotifiedFiles", w.files.RemoveNotifiedFiles))
+
+	go w.run(ctx, w.register("UpdateReputation", w.providers.UpdateReputation))
+	go w.run(ctx, w.register("ProbeProviders", w.providers.ProbeProviders))
+	go w.run(ctx, w.register("MonitorProofs", w.providers.MonitorProofs))
+
+	go w.run(ctx, w.register("CollectBacklog", w.files.CollectBacklog))
 
 	return nil
 }
 
-func (w *worker) run(ctx context.Context, name string, f workerFunc) {
-	logger := w.logger.With(slog.String("run_worker", name))
+// Stop lets running iterations finish and waits for all workers until ctx is done.
+// Iterations still running after that are interrupted only when the context passed to Start is canceled.
+func (w *worker) Stop(ctx context.Context) (err error) {
+	w.stopOnce.Do(func() { close(w.stop) })
+
+	stopped := make(chan struct{})
+	go func() {
+		w.running.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		w.logger.Info("all workers stopped")
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	return
+}
+
+func (w *worker) run(ctx context.Context, h *handle) {
+	defer w.running.Done()
+
+	logger := w.logger.With(slog.String("run_worker", h.name))
+
+	// Every worker runs on a single instance at a time, others stay in standby
+	isLeader := false
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-w.stop:
+			return
 		default:
-			interval, err := f(ctx)
-			if err != nil {
-				logger.Error(err.Error())
+			interval := standbyInterval
+
+			lease, leading, lErr := w.leader.Lead(ctx, h.name)
+			if lErr != nil {
+				logger.Error("failed to check worker leadership", slog.String("error", lErr.Error()))
+			}
+			if leading != isLeader {
+				isLeader = leading
+				logger.Info("worker leadership changed", slog.Bool("leader", leading))
+			}
+
+			paused, pErr := w.isPaused(ctx, h.name)
+			if pErr != nil {
+				logger.Error("failed to load paused workers", slog.String("error", pErr.Error()))
+			}
+
+			if h.begin(leading, paused) {
+				var err error
+				startedAt := time.Now()
+				interval, err = w.runLeading(ctx, lease, h)
+				if err != nil {
+					logger.Error(err.Error())
+				} else {
+					w.lastSuccess.WithLabelValues(h.name).SetToCurrentTime()
+				}
+				h.finish(startedAt, err)
+
+				if paused {
+					interval = standbyInterval
+				}
 			}
 			if interval <= 0 {
 				interval = time.Second
 			}
+			h.scheduled(time.Now().Add(interval))
+
 			t := time.NewTimer(interval)
 			select {
 			case <-ctx.Done():
 				t.Stop()
 				return
+			case <-w.stop:
+				t.Stop()
+				return
 			case <-t.C:
+			case <-h.trigger:
+				t.Stop()
 			}
 		}
 	}
 }
 
+// runLeading runs an iteration that is canceled as soon as the leadership is lost,
+// so the worker never keeps going while another instance already runs it
+func (w *worker) runLeading(ctx context.Context, lease context.Context, h *handle) (interval time.Duration, err error) {
+	runCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	stop := context.AfterFunc(lease, func() {
+		cancel(context.Cause(lease))
+	})
+	defer stop()
+
+	interval, err = h.fn(runCtx)
+	if lost := context.Cause(lease); lost != nil && ctx.Err() == nil {
+		w.logger.Warn("worker iteration canceled, leadership lost", slog.String("run_worker", h.name), slog.String("error", lost.Error()))
+		interval = standbyInterval
+	}
+
+	return
+}
+
 func NewWorkers(
 	files filesworker.Worker,
+	providers providersworker.Worker,
 	cleaner cleaner.Worker,
+	leader leader,
+	params params,
+	lastSuccess *prometheus.GaugeVec,
 	logger *slog.Logger,
 ) Workers {
 	return &worker{
-		files:   files,
-		cleaner: cleaner,
-		logger:  logger,
+		files:       files,
+		providers:   providers,
+		cleaner:     cleaner,
+		leader:      leader,
+		params:      params,
+		lastSuccess: lastSuccess,
+		stop:        make(chan struct{}),
+		logger:      logger,
 	}
 }